@@ -10,13 +10,31 @@ import (
 	"shopify-manager/pkg/config"
 	"shopify-manager/pkg/constants"
 	"shopify-manager/pkg/flow"
+	"shopify-manager/pkg/infrastructure/progress"
 	"shopify-manager/pkg/infrastructure/util"
+	"shopify-manager/pkg/notice"
+	"shopify-manager/pkg/output"
+	"shopify-manager/pkg/snapshot"
+	"shopify-manager/pkg/storage"
 )
 
 const LogFile = "./info.log"
 
+// ローカル以外の保存先で、実行毎のログを書き出すディレクトリ
+const LogDir = "logs"
+
 func main() {
 	flowType := flag.String("flow", constants.FLOW_TYPE_CREATE_INSTANCE, "flow type")
+	configPath := flag.String("config", config.CONFIG_FILE_PATH, "config file path")
+	query := flag.String("query", "", "order search query instead of input excel / detect-duplicates: target orders (e.g. \"financial_status=authorized test=true created=yesterday\")")
+	storeName := flag.String("store", "", "store name in config.toml [Stores] (default: defaultStore)")
+	execute := flag.Bool("execute", false, "auto-cancel / serve: cancel matched orders instead of writing a proposal / revert: restore orders instead of only checking / lottery: cancel losers")
+	from := flag.String("from", "", "audit-report / revert: start date (YYYY-MM-DD)")
+	to := flag.String("to", "", "audit-report / revert: end date (YYYY-MM-DD)")
+	order := flag.String("order", "", "audit-report / revert: order number or order id / show-snapshot: order number")
+	force := flag.Bool("force", false, "cancel / create-draft-orders even if [Safety] limits are exceeded")
+	yes := flag.Bool("yes", false, "skip the typed confirmation before cancelling / creating draft orders")
+	outputFormat := flag.String("output", output.FORMAT_TEXT, "result format: text or json (JSON lines to stdout, logs to stderr)")
 	flag.Parse()
 
 	logfile, err := os.OpenFile(LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
@@ -26,19 +44,106 @@ func main() {
 		return
 	}
 	defer logfile.Close()
-	log.SetOutput(io.MultiWriter(logfile, os.Stdout))
 	log.SetFlags(log.Ldate | log.Ltime)
 
-	config, err := config.LoadConfig()
+	err = output.SetFormat(*outputFormat)
+	if err != nil {
+		log.SetOutput(io.MultiWriter(logfile, os.Stderr))
+		log.Printf("ERROR: %s\n", err.Error())
+		os.Exit(2)
+	}
+
+	// JSON 出力モードでは標準出力を結果専用にし、ログは標準エラー出力に出す
+	// コンソールへのログはプログレスバーの表示中にバーの行を崩さないように出す
+	console := progress.Console(os.Stdout)
+	if output.IsJSON() {
+		console = progress.Console(os.Stderr)
+	}
+	log.SetOutput(io.MultiWriter(logfile, console))
+
+	// 設定は [Storage] を決めるために読むため、既定の保存先 (作業ディレクトリ) から読む
+	configData, err := storage.ReadFile(*configPath)
 	if err != nil {
 		log.Printf("コンフィグファイルのロードに失敗しました : %s", err.Error())
-		util.WaitEnter()
+		output.Finish(*flowType, err)
+		waitEnter()
+		return
+	}
+	config, err := config.ParseConfig(*configPath, configData)
+	if err != nil {
+		log.Printf("コンフィグファイルのロードに失敗しました : %s", err.Error())
+		output.Finish(*flowType, err)
+		waitEnter()
+		return
+	}
+
+	for _, warning := range config.Warnings() {
+		log.Printf("WARN : %s\n", warning)
+	}
+
+	config.Safety.Force = *force
+	config.Safety.AssumeYes = *yes
+
+	snapshot.Configure(config.Snapshot)
+
+	err = storage.Configure(config.Storage)
+	if err != nil {
+		log.Printf("入出力の保存先の設定に失敗しました : %s", err.Error())
+		output.Finish(*flowType, err)
+		waitEnter()
+		return
+	}
+
+	// ローカル以外の保存先では、ローカルの info.log に加えて保存先の logs/ に実行毎のログを書き出す
+	if !storage.IsLocal() {
+		logWriter := storage.NewLogWriter(LogDir)
+		defer logWriter.Close()
+		log.SetOutput(io.MultiWriter(logfile, console, logWriter))
+	}
+
+	err = notice.Register(config.CustomerNotice)
+	if err != nil {
+		log.Printf("顧客へのお知らせのテンプレートのロードに失敗しました : %s", err.Error())
+		output.Finish(*flowType, err)
+		waitEnter()
 		return
 	}
 
 	if *flowType == constants.FLOW_TYPE_CREATE_INSTANCE {
-		flow.CancelOrders(config)
+		flow.CancelOrders(config, *storeName, *query)
+	} else if *flowType == constants.FLOW_TYPE_CANCEL_LINE_ITEMS {
+		flow.CancelLineItems(config, *storeName)
+	} else if *flowType == constants.FLOW_TYPE_AUTO_CANCEL {
+		flow.AutoCancel(config, *storeName, *execute)
+	} else if *flowType == constants.FLOW_TYPE_CONFIG_CHECK {
+		flow.ConfigCheck(config, *configPath, *storeName)
+	} else if *flowType == constants.FLOW_TYPE_AUDIT_REPORT {
+		flow.AuditReport(config, *from, *to, *order)
+	} else if *flowType == constants.FLOW_TYPE_SERVE {
+		flow.Serve(config, *execute)
+	} else if *flowType == constants.FLOW_TYPE_API {
+		flow.Api(config)
+	} else if *flowType == constants.FLOW_TYPE_SCHEDULE {
+		flow.Schedule(config)
+	} else if *flowType == constants.FLOW_TYPE_SHOW_SNAPSHOT {
+		flow.ShowSnapshot(config, *storeName, *order)
+	} else if *flowType == constants.FLOW_TYPE_REVERT {
+		flow.Revert(config, *storeName, *from, *to, *order, *execute)
+	} else if *flowType == constants.FLOW_TYPE_CREATE_DRAFT_ORDERS {
+		flow.CreateDraftOrders(config, *storeName)
+	} else if *flowType == constants.FLOW_TYPE_LOTTERY {
+		flow.Lottery(config, *storeName, *execute)
+	} else if *flowType == constants.FLOW_TYPE_DETECT_DUPLICATES {
+		flow.DetectDuplicates(config, *storeName, *query)
 	}
 
+	waitEnter()
+}
+
+// waitEnter はコンソールを閉じる前にエンターを待つ。スクリプトから使う JSON 出力モードでは待たない。
+func waitEnter() {
+	if output.IsJSON() {
+		return
+	}
 	util.WaitEnter()
 }
@@ -0,0 +1 @@
+main.exe -flow serve
\ No newline at end of file
@@ -0,0 +1 @@
+main.exe -flow revert -order %1
\ No newline at end of file
@@ -0,0 +1 @@
+main.exe -flow show-snapshot -order %1
\ No newline at end of file
@@ -0,0 +1 @@
+main.exe -flow api
\ No newline at end of file
@@ -0,0 +1 @@
+main.exe -flow create-draft-orders
\ No newline at end of file
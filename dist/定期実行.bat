@@ -0,0 +1 @@
+main.exe -flow schedule
\ No newline at end of file
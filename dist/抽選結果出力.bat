@@ -0,0 +1 @@
+main.exe -flow lottery
\ No newline at end of file
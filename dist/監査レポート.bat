@@ -0,0 +1 @@
+main.exe -flow audit-report -from %1 -to %2
\ No newline at end of file
@@ -0,0 +1 @@
+main.exe -flow auto-cancel
\ No newline at end of file
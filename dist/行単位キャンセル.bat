@@ -0,0 +1 @@
+main.exe -flow cancel-line-items
\ No newline at end of file
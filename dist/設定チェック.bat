@@ -0,0 +1 @@
+main.exe -flow config-check
\ No newline at end of file
@@ -0,0 +1 @@
+main.exe -flow detect-duplicates
\ No newline at end of file
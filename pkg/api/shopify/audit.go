@@ -0,0 +1,81 @@
+package shopify
+
+import (
+	"fmt"
+	"log"
+
+	"shopify-manager/pkg/audit"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/infrastructure/http"
+)
+
+const AUDIT_ACTION_VOID = "void"
+const AUDIT_ACTION_CAPTURE = "capture"
+const AUDIT_ACTION_CANCEL = "cancel"
+const AUDIT_ACTION_REFUND = "refund"
+const AUDIT_ACTION_ADJUST_INVENTORY = "adjust-inventory"
+const AUDIT_ACTION_REOPEN = "reopen"
+const AUDIT_ACTION_CREATE_DRAFT_ORDER = "create-draft-order"
+const AUDIT_ACTION_SEND_DRAFT_ORDER_INVOICE = "send-draft-order-invoice"
+const AUDIT_ACTION_COMPLETE_DRAFT_ORDER = "complete-draft-order"
+
+// postWithAudit は変更を伴う POST を行い、結果を監査ファイルに記録する。
+// オーダー単位ではない操作 (在庫の調整など) では order に nil を渡す。
+func postWithAudit(url string, reqJsonBytes []byte, header map[string]string, store *config.Store, action string, order *Order, transactionId int64) ([]byte, error) {
+	err := checkAudit(action)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.PostWithResponse(url, reqJsonBytes, header)
+	err = recordAudit(store, action, order, transactionId, reqJsonBytes, res, err)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// checkAudit は監査ファイルに記録できることを確認する。記録できない場合は変更を行わずにエラーを返す。
+func checkAudit(action string) error {
+	err := audit.Check()
+	if err != nil {
+		return fmt.Errorf("監査ファイルに記録できないため %s を中止しました : %s", action, err.Error())
+	}
+	return nil
+}
+
+// recordAudit は変更の結果を記録し、変更のエラーまたは記録のエラーを返す。
+func recordAudit(store *config.Store, action string, order *Order, transactionId int64, reqJsonBytes []byte, res *http.Response, err error) error {
+	return writeAudit(audit.Entry{Store: store.Name, Action: action, TransactionID: transactionId, RequestBody: string(reqJsonBytes)}, order, res, err)
+}
+
+// recordDraftOrderAudit は下書き注文の操作を記録する。
+// order は取り消しで作成した場合の元のオーダーで、無い場合は nil。
+func recordDraftOrderAudit(store *config.Store, action string, order *Order, draftOrderId int64, reqJsonBytes []byte, res *http.Response, err error) error {
+	return writeAudit(audit.Entry{Store: store.Name, Action: action, DraftOrderID: draftOrderId, RequestBody: string(reqJsonBytes)}, order, res, err)
+}
+
+// writeAudit は変更の結果を記録する。変更が成功して記録に失敗した場合は、変更済みであることを示すエラーを返して
+// 実行を失敗にする (以降の変更は checkAudit で中止される)。
+func writeAudit(entry audit.Entry, order *Order, res *http.Response, err error) error {
+	if order != nil {
+		entry.OrderID = order.ID
+		entry.OrderNumber = order.OrderNumber
+	}
+	if res != nil {
+		entry.Status = res.StatusCode
+		entry.RequestID = res.Header.Get("X-Request-Id")
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	auditErr := audit.Record(entry)
+	if auditErr != nil {
+		log.Printf("ERROR : failed to record audit. action '%s' orderId '%d'. %s\n", entry.Action, entry.OrderID, auditErr.Error())
+		if err == nil {
+			return fmt.Errorf("%s は Shopify に反映されましたが、監査ファイルに記録できませんでした : %s", entry.Action, auditErr.Error())
+		}
+	}
+	return err
+}
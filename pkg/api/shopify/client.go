@@ -0,0 +1,119 @@
+package shopify
+
+import (
+	"fmt"
+
+	"shopify-manager/pkg/config"
+)
+
+const BACKEND_REST = "rest"
+const BACKEND_GRAPHQL = "graphql"
+
+// Client はオーダー操作で利用する Shopify Admin API の呼び出し。
+// ストア設定の backend で REST / GraphQL を切り替える。
+type Client interface {
+	GetOrder(orderNumber int) (*Order, error)
+	SearchOrders(query *OrderQuery) ([]Order, error)
+	GetAuthorizationTransactionId(orderId int64) (int64, error)
+	VoidTransaction(order *Order, transactionId int64) error
+	CaptureTransaction(order *Order, transactionId int64, amount string) error
+	CancelOrder(order *Order) error
+	CalculateRefund(order *Order, refundLineItems []RefundLineItemRequest) (*CalculateRefundResponse, error)
+	CreateRefund(order *Order, calculated *CalculateRefundResponse) (*Refund, error)
+	GetShop() (*Shop, error)
+	GetAccessScopes() ([]string, error)
+	GetVariant(variantId int64) (*Variant, error)
+	FindVariantBySku(sku string) (*Variant, error)
+	FindDraftOrdersByTag(tag string) ([]DraftOrder, error)
+	CreateDraftOrder(draftOrder *DraftOrder) (*DraftOrder, error)
+	SendDraftOrderInvoice(draftOrder *DraftOrder) error
+	CompleteDraftOrder(draftOrder *DraftOrder, paymentPending bool) (*DraftOrder, error)
+}
+
+func NewClient(store *config.Store) (Client, error) {
+	switch store.Backend {
+	case "", BACKEND_REST:
+		return &restClient{store: store}, nil
+	case BACKEND_GRAPHQL:
+		return &graphqlClient{store: store}, nil
+	default:
+		return nil, fmt.Errorf("未対応の backend です : %s", store.Backend)
+	}
+}
+
+type restClient struct {
+	store *config.Store
+}
+
+func (c *restClient) GetOrder(orderNumber int) (*Order, error) {
+	return getOrder(orderNumber, c.store)
+}
+
+func (c *restClient) SearchOrders(query *OrderQuery) ([]Order, error) {
+	return searchOrders(query, c.store)
+}
+
+func (c *restClient) GetAuthorizationTransactionId(orderId int64) (int64, error) {
+	return getTransactionId(orderId, c.store)
+}
+
+func (c *restClient) VoidTransaction(order *Order, transactionId int64) error {
+	_, err := disabeAuthorization(order, transactionId, c.store)
+	return err
+}
+
+func (c *restClient) CaptureTransaction(order *Order, transactionId int64, amount string) error {
+	_, err := captureTransaction(order, transactionId, amount, c.store)
+	return err
+}
+
+func (c *restClient) CancelOrder(order *Order) error {
+	_, err := cancelOrder(order, c.store)
+	return err
+}
+
+func (c *restClient) CalculateRefund(order *Order, refundLineItems []RefundLineItemRequest) (*CalculateRefundResponse, error) {
+	return calculateRefund(order.ID, refundLineItems, c.store)
+}
+
+func (c *restClient) CreateRefund(order *Order, calculated *CalculateRefundResponse) (*Refund, error) {
+	createRefundRes, err := createRefund(order, calculated, c.store)
+	if err != nil {
+		return nil, err
+	}
+	return &createRefundRes.Refund, nil
+}
+
+func (c *restClient) GetShop() (*Shop, error) {
+	return getShop(c.store)
+}
+
+func (c *restClient) GetAccessScopes() ([]string, error) {
+	return getAccessScopes(c.store)
+}
+
+func (c *restClient) GetVariant(variantId int64) (*Variant, error) {
+	return getVariant(variantId, c.store)
+}
+
+// FindVariantBySku は REST では SKU で検索できないため GraphQL を使う。
+func (c *restClient) FindVariantBySku(sku string) (*Variant, error) {
+	return (&graphqlClient{store: c.store}).FindVariantBySku(sku)
+}
+
+// FindDraftOrdersByTag は REST ではタグで検索できないため GraphQL を使う。
+func (c *restClient) FindDraftOrdersByTag(tag string) ([]DraftOrder, error) {
+	return (&graphqlClient{store: c.store}).FindDraftOrdersByTag(tag)
+}
+
+func (c *restClient) CreateDraftOrder(draftOrder *DraftOrder) (*DraftOrder, error) {
+	return createDraftOrder(draftOrder, nil, c.store)
+}
+
+func (c *restClient) SendDraftOrderInvoice(draftOrder *DraftOrder) error {
+	return sendDraftOrderInvoice(draftOrder, c.store)
+}
+
+func (c *restClient) CompleteDraftOrder(draftOrder *DraftOrder, paymentPending bool) (*DraftOrder, error) {
+	return completeDraftOrder(draftOrder, paymentPending, c.store)
+}
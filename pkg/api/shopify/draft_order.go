@@ -0,0 +1,629 @@
+package shopify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/infrastructure/http"
+	"shopify-manager/pkg/infrastructure/util"
+	"shopify-manager/pkg/infrastructure/worker"
+	"shopify-manager/pkg/output"
+)
+
+// 下書き注文の作成後に行う処理 (入力エクセルの H 列)
+const DRAFT_ORDER_ACTION_NONE = ""
+const DRAFT_ORDER_ACTION_INVOICE = "invoice"
+const DRAFT_ORDER_ACTION_PAID = "paid"
+const DRAFT_ORDER_ACTION_PENDING = "pending"
+
+// 割引の value_type
+const DISCOUNT_VALUE_TYPE_FIXED_AMOUNT = "fixed_amount"
+const DISCOUNT_VALUE_TYPE_PERCENTAGE = "percentage"
+
+// 配送料だけ指定された場合の配送名
+const DEFAULT_SHIPPING_TITLE = "送料"
+
+// DRAFT_ORDER_GROUP_TAG_PREFIX は下書き注文に付けるグループのタグの接頭辞。
+// 再実行時はこのタグで作成済みの下書き注文を探し、二重に作らない。
+const DRAFT_ORDER_GROUP_TAG_PREFIX = "draft-group:"
+
+// 下書き注文の status
+const DRAFT_ORDER_STATUS_OPEN = "open"
+const DRAFT_ORDER_STATUS_COMPLETED = "completed"
+
+type DraftOrderRequest struct {
+	DraftOrder DraftOrder `json:"draft_order"`
+}
+
+type DraftOrder struct {
+	ID              int64                   `json:"id,omitempty"`
+	OrderID         int64                   `json:"order_id,omitempty"`
+	Name            string                  `json:"name,omitempty"`
+	Status          string                  `json:"status,omitempty"`
+	InvoiceURL      string                  `json:"invoice_url,omitempty"`
+	Email           string                  `json:"email,omitempty"`
+	Customer        *DraftOrderCustomer     `json:"customer,omitempty"`
+	LineItems       []DraftOrderLineItem    `json:"line_items"`
+	ShippingAddress *Address                `json:"shipping_address,omitempty"`
+	BillingAddress  *Address                `json:"billing_address,omitempty"`
+	ShippingLine    *DraftOrderShippingLine `json:"shipping_line,omitempty"`
+	AppliedDiscount *DraftOrderDiscount     `json:"applied_discount,omitempty"`
+	NoteAttributes  []NoteAttribute         `json:"note_attributes,omitempty"`
+	Note            string                  `json:"note,omitempty"`
+	Tags            string                  `json:"tags,omitempty"`
+}
+
+type DraftOrderCustomer struct {
+	ID int64 `json:"id"`
+}
+
+// DraftOrderLineItem は variant_id を指定するか、削除された商品は title / price のカスタム商品にする。
+// variant_id を指定した商品の価格は変えられないため、価格の上書きは AppliedDiscount の値引きで行う。
+type DraftOrderLineItem struct {
+	VariantID       int64               `json:"variant_id,omitempty"`
+	Title           string              `json:"title,omitempty"`
+	Price           string              `json:"price,omitempty"`
+	Quantity        int                 `json:"quantity"`
+	AppliedDiscount *DraftOrderDiscount `json:"applied_discount,omitempty"`
+}
+
+type DraftOrderShippingLine struct {
+	Title  string `json:"title"`
+	Price  string `json:"price"`
+	Custom bool   `json:"custom"`
+}
+
+type DraftOrderDiscount struct {
+	Description string `json:"description"`
+	Title       string `json:"title"`
+	ValueType   string `json:"value_type"`
+	Value       string `json:"value"`
+	Amount      string `json:"amount,omitempty"`
+}
+
+type DraftOrderResponse struct {
+	DraftOrder DraftOrder `json:"draft_order"`
+}
+
+// DraftOrderRow は下書き注文入力の1行を表す。
+// Item は SKU、もしくは数値の場合は variant ID として扱う。Price は空の場合は商品の価格のまま。
+type DraftOrderRow struct {
+	Row      int
+	Group    string
+	Store    string
+	Email    string
+	Item     string
+	Quantity int
+	Price    string
+	Discount string
+	Shipping string
+	Action   string
+	Note     string
+}
+
+// DraftOrderResult は JSON 出力モードで書き出す1件の下書き注文の作成結果。
+// OrderID / OrderName は paid / pending で完了した場合に作られたオーダー。
+type DraftOrderResult struct {
+	Type           string `json:"type"`
+	Store          string `json:"store"`
+	Group          string `json:"group"`
+	Rows           []int  `json:"rows"`
+	Email          string `json:"email"`
+	Action         string `json:"action,omitempty"`
+	DraftOrderID   int64  `json:"draft_order_id,omitempty"`
+	DraftOrderName string `json:"draft_order_name,omitempty"`
+	InvoiceURL     string `json:"invoice_url,omitempty"`
+	OrderID        int64  `json:"order_id,omitempty"`
+	Status         string `json:"status"`
+	Step           string `json:"step,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// draftOrderInput は同じグループの行をまとめた1件の下書き注文。
+// existing は前回の実行で作成済みの下書き注文で、ある場合は作成せずに残りの処理から再開する。
+type draftOrderInput struct {
+	group      string
+	rows       []DraftOrderRow
+	action     string
+	draftOrder *DraftOrder
+	existing   *DraftOrder
+}
+
+// isDone は作成済みの下書き注文が H 列の処理まで済んでいるかどうか。
+func (input *draftOrderInput) isDone() bool {
+	if input.existing == nil {
+		return false
+	}
+	switch input.action {
+	case DRAFT_ORDER_ACTION_INVOICE:
+		return input.existing.Status != DRAFT_ORDER_STATUS_OPEN
+	case DRAFT_ORDER_ACTION_PAID, DRAFT_ORDER_ACTION_PENDING:
+		return input.existing.Status == DRAFT_ORDER_STATUS_COMPLETED
+	}
+	return true
+}
+
+func (input *draftOrderInput) newResult(store *config.Store) *DraftOrderResult {
+	result := &DraftOrderResult{Type: output.TYPE_DRAFT_ORDER, Store: store.Name, Group: input.group, Email: input.draftOrder.Email, Action: input.action}
+	for _, row := range input.rows {
+		result.Rows = append(result.Rows, row.Row)
+	}
+	return result
+}
+
+func draftOrderGroupTag(group string) string {
+	return DRAFT_ORDER_GROUP_TAG_PREFIX + group
+}
+
+// CreateDraftOrders は入力エクセルの行から下書き注文を作り、H 列に応じて請求書の送信・支払済み / 支払待ちでの完了を行う。
+// A 列が同じ行は1件の下書き注文にまとめる。I 列にストア名がある行はそのストア、無い行は storeName のストアが対象になる。
+func CreateDraftOrders(config *config.Config, storeName string, sink Sink) error {
+	store, err := config.GetStore(storeName)
+	if err != nil {
+		return err
+	}
+
+	rows, err := getDraftOrderRowList(constants.INPUT_DRAFT_ORDER_EXCEL_FILE_PATH, store.Name)
+	if err != nil {
+		return err
+	}
+
+	var storeNames []string
+	storeRowMap := map[string][]DraftOrderRow{}
+	for _, row := range rows {
+		if _, ok := storeRowMap[row.Store]; !ok {
+			storeNames = append(storeNames, row.Store)
+		}
+		storeRowMap[row.Store] = append(storeRowMap[row.Store], row)
+	}
+
+	targetStores, err := resolveStores(config, storeNames)
+	if err != nil {
+		return err
+	}
+
+	isSuccess := true
+	for _, targetStore := range targetStores {
+		name := targetStore.Name
+		err = createStoreDraftOrders(storeRowMap[name], targetStore, &config.Safety, sink)
+		if err != nil {
+			log.Printf("ERROR : store '%s' %s\n", name, err.Error())
+			isSuccess = false
+		}
+	}
+
+	if isSuccess {
+		return nil
+	} else {
+		return fmt.Errorf("Failed to create any of draft orders.")
+	}
+}
+
+func createStoreDraftOrders(rows []DraftOrderRow, store *config.Store, safety *config.Safety, sink Sink) error {
+	client, err := NewClient(store)
+	if err != nil {
+		return err
+	}
+
+	inputs, err := groupDraftOrderRows(rows)
+	if err != nil {
+		return err
+	}
+
+	// 同じ商品の行が多いため、商品の取得は SKU・variant ID 毎に1回にする
+	variants := map[string]*Variant{}
+	for _, input := range inputs {
+		input.draftOrder, err = buildInputDraftOrder(client, input, variants)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 前回の実行で作成済みのグループは作成し直さない。請求書の送信・完了の途中で止まったものはそこから再開する
+	var pending []*draftOrderInput
+	for _, input := range inputs {
+		existing, err := client.FindDraftOrdersByTag(draftOrderGroupTag(input.group))
+		if err != nil {
+			return fmt.Errorf("グループ '%s' の作成済みの下書き注文を確認できません。%s", input.group, err.Error())
+		}
+		if len(existing) > 1 {
+			return fmt.Errorf("グループ '%s' の下書き注文が %d 件あります。Shopify の管理画面で確認してください", input.group, len(existing))
+		}
+		if len(existing) == 1 {
+			input.existing = &existing[0]
+		}
+
+		if input.isDone() {
+			log.Printf("INFO : group '%s' は下書き注文 '%s' を作成済みのためスキップします\n", input.group, input.existing.Name)
+			result := input.newResult(store)
+			result.DraftOrderID = input.existing.ID
+			result.DraftOrderName = input.existing.Name
+			result.InvoiceURL = input.existing.InvoiceURL
+			result.OrderID = input.existing.OrderID
+			result.Status = output.STATUS_SKIPPED
+			result.Step = "already created"
+			output.Write(result)
+			continue
+		}
+		pending = append(pending, input)
+	}
+	inputs = pending
+
+	if safety.MaxOrdersPerRun > 0 && len(inputs) > safety.MaxOrdersPerRun {
+		problem := fmt.Sprintf("下書き注文数 %d が上限 maxOrdersPerRun %d を超えています", len(inputs), safety.MaxOrdersPerRun)
+		if !safety.Force {
+			return fmt.Errorf("安全上限を超えるため中止しました。内容を確認の上 -force で実行できます\n  %s", problem)
+		}
+		log.Printf("WARN : store '%s' %s (-force のため続行します)\n", store.Name, problem)
+	}
+	if !confirmDraftOrders(inputs, store, safety) {
+		return fmt.Errorf("下書き注文の作成を中止しました")
+	}
+
+	var success worker.Success
+	reporter := sink.Progress(fmt.Sprintf("下書き注文作成 (%s)", store.Name), len(inputs))
+	worker.New(store.Domain, store.ThreadNum).Run(len(inputs), func(i int) {
+		input := inputs[i]
+		item := input.group
+		result := input.newResult(store)
+		failed := func(step string, err error) {
+			log.Printf("ERROR : group '%s' failed to %s. %s\n", input.group, step, err.Error())
+			success.Fail()
+			reporter.Failed(item, err)
+			result.Status = output.STATUS_FAILED
+			result.Step = step
+			result.Error = err.Error()
+			output.Write(result)
+		}
+
+		created := input.existing
+		if created != nil {
+			log.Printf("INFO : group '%s' は下書き注文 '%s' を作成済みのため続きから処理します\n", input.group, created.Name)
+		} else {
+			reporter.Step(item, "create draft order")
+			log.Printf("INFO : Try to create draft order for group '%s' (%s)\n", input.group, input.draftOrder.Email)
+			var err error
+			created, err = client.CreateDraftOrder(input.draftOrder)
+			if err != nil {
+				failed("create draft order", err)
+				return
+			}
+		}
+		result.DraftOrderID = created.ID
+		result.DraftOrderName = created.Name
+		result.InvoiceURL = created.InvoiceURL
+
+		switch input.action {
+		case DRAFT_ORDER_ACTION_INVOICE:
+			reporter.Step(item, "send invoice")
+			log.Printf("INFO : Try to send invoice of draft order '%s'\n", created.Name)
+			err := client.SendDraftOrderInvoice(created)
+			if err != nil {
+				failed("send invoice", err)
+				return
+			}
+		case DRAFT_ORDER_ACTION_PAID, DRAFT_ORDER_ACTION_PENDING:
+			reporter.Step(item, "complete draft order")
+			log.Printf("INFO : Try to complete draft order '%s' as %s\n", created.Name, input.action)
+			completed, err := client.CompleteDraftOrder(created, input.action == DRAFT_ORDER_ACTION_PENDING)
+			if err != nil {
+				failed("complete draft order", err)
+				return
+			}
+			result.OrderID = completed.OrderID
+		}
+
+		log.Printf("group '%s' successed to create draft order '%s'.\n", input.group, created.Name)
+		reporter.Succeeded(item)
+		result.Status = output.STATUS_SUCCEEDED
+		output.Write(result)
+	})
+	reporter.Finish()
+
+	if success.OK() {
+		return nil
+	} else {
+		return fmt.Errorf("Failed to create any of draft orders.")
+	}
+}
+
+// confirmDraftOrders は作成する下書き注文の概要を表示し、対話実行の場合は件数の入力で確認を取る。
+func confirmDraftOrders(inputs []*draftOrderInput, store *config.Store, safety *config.Safety) bool {
+	actionCount := map[string]int{}
+	for _, input := range inputs {
+		actionCount[input.action]++
+	}
+	log.Printf("INFO : store '%s' (%s) : %d draft orders (請求書送信 %d / 支払済み %d / 支払待ち %d / 作成のみ %d)\n", store.Name, store.Domain, len(inputs),
+		actionCount[DRAFT_ORDER_ACTION_INVOICE], actionCount[DRAFT_ORDER_ACTION_PAID], actionCount[DRAFT_ORDER_ACTION_PENDING], actionCount[DRAFT_ORDER_ACTION_NONE])
+
+	if len(inputs) == 0 || safety.AssumeYes || !util.IsTerminal() {
+		return true
+	}
+
+	answer := util.Prompt(fmt.Sprintf("下書き注文を作成する場合は対象件数 %d を入力してください : ", len(inputs)))
+	if answer != strconv.Itoa(len(inputs)) {
+		log.Printf("INFO : 入力 '%s' が件数と一致しないため下書き注文の作成を中止します\n", answer)
+		return false
+	}
+	return true
+}
+
+// groupDraftOrderRows は同じグループの行を1件にまとめる。
+// メール・割引・配送・処理・メモはグループ内のいずれかの行に書けばよいが、異なる値が書かれている場合はエラーにする。
+func groupDraftOrderRows(rows []DraftOrderRow) ([]*draftOrderInput, error) {
+	var inputs []*draftOrderInput
+	inputMap := map[string]*draftOrderInput{}
+	for _, row := range rows {
+		if strings.Contains(row.Group, ",") {
+			return nil, fmt.Errorf("%d行目 : グループ '%s' にカンマは使えません", row.Row, row.Group)
+		}
+		input, ok := inputMap[row.Group]
+		if !ok {
+			input = &draftOrderInput{group: row.Group}
+			inputMap[row.Group] = input
+			inputs = append(inputs, input)
+		}
+		input.rows = append(input.rows, row)
+	}
+
+	for _, input := range inputs {
+		first := &input.rows[0]
+		for _, row := range input.rows[1:] {
+			for _, field := range []struct {
+				name         string
+				value, other *string
+			}{
+				{"メール", &first.Email, &row.Email},
+				{"割引", &first.Discount, &row.Discount},
+				{"配送", &first.Shipping, &row.Shipping},
+				{"処理", &first.Action, &row.Action},
+				{"メモ", &first.Note, &row.Note},
+			} {
+				if *field.other == "" || *field.other == *field.value {
+					continue
+				}
+				if *field.value != "" {
+					return nil, fmt.Errorf("%d行目 : グループ '%s' の%s '%s' が %d行目の '%s' と異なります", row.Row, input.group, field.name, *field.other, first.Row, *field.value)
+				}
+				*field.value = *field.other
+			}
+		}
+		if first.Email == "" {
+			return nil, fmt.Errorf("%d行目 : グループ '%s' の顧客のメールアドレスが空です", first.Row, input.group)
+		}
+		input.action = first.Action
+	}
+	return inputs, nil
+}
+
+// buildInputDraftOrder はグループの行から下書き注文を作る。
+func buildInputDraftOrder(client Client, input *draftOrderInput, variants map[string]*Variant) (*DraftOrder, error) {
+	first := input.rows[0]
+	draftOrder := &DraftOrder{Email: first.Email, Note: first.Note, Tags: draftOrderGroupTag(input.group)}
+
+	for _, row := range input.rows {
+		variant, ok := variants[row.Item]
+		if !ok {
+			var err error
+			variant, err = findVariant(client, row.Item)
+			if err != nil {
+				return nil, fmt.Errorf("%d行目 : %s", row.Row, err.Error())
+			}
+			variants[row.Item] = variant
+		}
+
+		lineItem := DraftOrderLineItem{VariantID: variant.ID, Quantity: row.Quantity}
+		if row.Price != "" {
+			price, err := ParseDecimal(row.Price)
+			if err != nil {
+				return nil, fmt.Errorf("%d行目 : 価格 %s", row.Row, err.Error())
+			}
+			if price.Cmp(Decimal{}) < 0 || price.Cmp(variant.Price) > 0 {
+				return nil, fmt.Errorf("%d行目 : 価格 %s は 0 以上、商品の価格 %s 以下を指定してください", row.Row, price, variant.Price)
+			}
+			if off := variant.Price.Sub(price); off.IsPositive() {
+				lineItem.AppliedDiscount = &DraftOrderDiscount{
+					Description: fmt.Sprintf("価格 %s", price),
+					Title:       fmt.Sprintf("価格 %s", price),
+					ValueType:   DISCOUNT_VALUE_TYPE_FIXED_AMOUNT,
+					Value:       off.String(),
+				}
+			}
+		}
+		draftOrder.LineItems = append(draftOrder.LineItems, lineItem)
+	}
+
+	if first.Discount != "" {
+		discount, err := parseDraftOrderDiscount(first.Discount)
+		if err != nil {
+			return nil, fmt.Errorf("%d行目 : %s", first.Row, err.Error())
+		}
+		draftOrder.AppliedDiscount = discount
+	}
+	if first.Shipping != "" {
+		shippingLine, err := parseDraftOrderShipping(first.Shipping)
+		if err != nil {
+			return nil, fmt.Errorf("%d行目 : %s", first.Row, err.Error())
+		}
+		draftOrder.ShippingLine = shippingLine
+	}
+
+	return draftOrder, nil
+}
+
+// findVariant は数値の場合は variant ID、それ以外は SKU で商品を取得する。
+func findVariant(client Client, item string) (*Variant, error) {
+	if variantId, err := strconv.ParseInt(item, 10, 64); err == nil {
+		variant, err := client.GetVariant(variantId)
+		if err != nil {
+			return nil, fmt.Errorf("variant ID '%d' の商品を取得できません。%s", variantId, err.Error())
+		}
+		return variant, nil
+	}
+	variant, err := client.FindVariantBySku(item)
+	if err != nil {
+		return nil, fmt.Errorf("SKU '%s' の商品を取得できません。%s", item, err.Error())
+	}
+	return variant, nil
+}
+
+// parseDraftOrderDiscount は "10%" を割合、"500" を金額の値引きとして解釈する。
+func parseDraftOrderDiscount(value string) (*DraftOrderDiscount, error) {
+	valueType := DISCOUNT_VALUE_TYPE_FIXED_AMOUNT
+	if strings.HasSuffix(value, "%") {
+		valueType = DISCOUNT_VALUE_TYPE_PERCENTAGE
+	}
+	amount, err := ParseDecimal(strings.TrimSuffix(value, "%"))
+	if err != nil || !amount.IsPositive() {
+		return nil, fmt.Errorf("割引 '%s' は 10%% または金額で指定してください", value)
+	}
+	if valueType == DISCOUNT_VALUE_TYPE_PERCENTAGE && amount.Cmp(DecimalFromInt(100)) > 0 {
+		return nil, fmt.Errorf("割引 '%s' は 100%% 以下を指定してください", value)
+	}
+	return &DraftOrderDiscount{Description: "割引 " + value, Title: "割引 " + value, ValueType: valueType, Value: amount.String()}, nil
+}
+
+// parseDraftOrderShipping は "配送名:送料" または送料のみを解釈する。
+func parseDraftOrderShipping(value string) (*DraftOrderShippingLine, error) {
+	title, priceValue := DEFAULT_SHIPPING_TITLE, value
+	if colon := strings.LastIndexAny(value, ":："); colon >= 0 {
+		title = strings.TrimSpace(value[:colon])
+		priceValue = strings.TrimLeft(value[colon:], ":：")
+	}
+	price, err := ParseDecimal(priceValue)
+	if err != nil || price.Cmp(Decimal{}) < 0 || title == "" {
+		return nil, fmt.Errorf("配送 '%s' は 配送名:送料 または送料で指定してください", value)
+	}
+	return &DraftOrderShippingLine{Title: title, Price: price.String(), Custom: true}, nil
+}
+
+func isDraftOrderAction(action string) bool {
+	switch action {
+	case DRAFT_ORDER_ACTION_NONE, DRAFT_ORDER_ACTION_INVOICE, DRAFT_ORDER_ACTION_PAID, DRAFT_ORDER_ACTION_PENDING:
+		return true
+	}
+	return false
+}
+
+func getDraftOrderRowList(excelFilePath, defaultStore string) ([]DraftOrderRow, error) {
+	var rowList []DraftOrderRow
+	err := readInputRows(excelFilePath, func(row inputRow) error {
+		if len(row.cells) < 4 {
+			return fmt.Errorf("%d行目、グループ・メールアドレス・SKU(または variant ID)・数量の4列が必要です", row.num)
+		}
+
+		item := row.cell(2)
+		if item == "" {
+			return fmt.Errorf("%d行目、SKU または variant ID が空です", row.num)
+		}
+
+		quantity, err := row.int(3)
+		if err != nil || quantity < 1 {
+			return fmt.Errorf("%d行目、数量が空、または1以上の数値ではありません", row.num)
+		}
+
+		action := strings.ToLower(row.cell(7))
+		if !isDraftOrderAction(action) {
+			return fmt.Errorf("%d行目、処理は %s / %s / %s または空を指定してください : %s", row.num, DRAFT_ORDER_ACTION_INVOICE, DRAFT_ORDER_ACTION_PAID, DRAFT_ORDER_ACTION_PENDING, action)
+		}
+
+		store := defaultStore
+		if row.cell(8) != "" {
+			store = row.cell(8)
+		}
+
+		rowList = append(rowList, DraftOrderRow{
+			Row:      row.num,
+			Group:    row.cell(0),
+			Store:    store,
+			Email:    row.cell(1),
+			Item:     item,
+			Quantity: quantity,
+			Price:    row.cell(4),
+			Discount: row.cell(5),
+			Shipping: row.cell(6),
+			Action:   action,
+			Note:     row.cell(9),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rowList, nil
+}
+
+// createDraftOrder は下書き注文を作る。order は取り消しで元にしたオーダーで、無い場合は nil。
+func createDraftOrder(draftOrder *DraftOrder, order *Order, store *config.Store) (*DraftOrder, error) {
+	reqJsonBytes, err := json.MarshalIndent(DraftOrderRequest{DraftOrder: *draftOrder}, "", "  ")
+	if err != nil {
+		log.Println("Create draft order request json marshal error")
+		return nil, err
+	}
+
+	err = checkAudit(AUDIT_ACTION_CREATE_DRAFT_ORDER)
+	if err != nil {
+		return nil, err
+	}
+
+	draftOrdersUrl := restUrl(store, constants.DRAFT_ORDERS_URL_TEMPLATE, store.Domain, store.ApiVersion)
+	httpReqHeader := restHeader(store)
+	res, err := http.PostWithResponse(draftOrdersUrl, reqJsonBytes, httpReqHeader)
+
+	draftOrderResponse := new(DraftOrderResponse)
+	if err == nil {
+		err = json.Unmarshal(res.Body, &draftOrderResponse)
+		if err != nil {
+			log.Println("Create draft order response json unmarshal err")
+		}
+	}
+	err = recordDraftOrderAudit(store, AUDIT_ACTION_CREATE_DRAFT_ORDER, order, draftOrderResponse.DraftOrder.ID, reqJsonBytes, res, err)
+	if err != nil {
+		return nil, err
+	}
+	return &draftOrderResponse.DraftOrder, nil
+}
+
+func sendDraftOrderInvoice(draftOrder *DraftOrder, store *config.Store) error {
+	err := checkAudit(AUDIT_ACTION_SEND_DRAFT_ORDER_INVOICE)
+	if err != nil {
+		return err
+	}
+
+	reqJsonBytes := []byte(`{"draft_order_invoice":{}}`)
+	sendInvoiceUrl := restUrl(store, constants.SEND_DRAFT_ORDER_INVOICE_URL_TEMPLATE, store.Domain, store.ApiVersion, draftOrder.ID)
+	httpReqHeader := restHeader(store)
+	res, err := http.PostWithResponse(sendInvoiceUrl, reqJsonBytes, httpReqHeader)
+	return recordDraftOrderAudit(store, AUDIT_ACTION_SEND_DRAFT_ORDER_INVOICE, nil, draftOrder.ID, reqJsonBytes, res, err)
+}
+
+// completeDraftOrder は下書き注文を完了してオーダーにする。paymentPending が true の場合は支払待ち、false の場合は支払済みになる。
+func completeDraftOrder(draftOrder *DraftOrder, paymentPending bool, store *config.Store) (*DraftOrder, error) {
+	err := checkAudit(AUDIT_ACTION_COMPLETE_DRAFT_ORDER)
+	if err != nil {
+		return nil, err
+	}
+
+	reqJsonBytes := []byte("{}")
+	completeUrl := restUrl(store, constants.COMPLETE_DRAFT_ORDER_URL_TEMPLATE, store.Domain, store.ApiVersion, draftOrder.ID, paymentPending)
+	httpReqHeader := restHeader(store)
+	res, err := http.PutWithResponse(completeUrl, reqJsonBytes, httpReqHeader)
+	err = recordDraftOrderAudit(store, AUDIT_ACTION_COMPLETE_DRAFT_ORDER, nil, draftOrder.ID, reqJsonBytes, res, err)
+	if err != nil {
+		return nil, err
+	}
+
+	draftOrderResponse := new(DraftOrderResponse)
+	err = json.Unmarshal(res.Body, &draftOrderResponse)
+	if err != nil {
+		log.Println("Complete draft order response json unmarshal err")
+		return nil, err
+	}
+	return &draftOrderResponse.DraftOrder, nil
+}
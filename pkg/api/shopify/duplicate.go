@@ -0,0 +1,113 @@
+package shopify
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/duplicate"
+)
+
+// 電話番号として扱う最小の桁数。これより短い値は入力ミスとみなして判定に使わない
+const MIN_PHONE_DIGITS = 8
+
+// DEFAULT_DUPLICATE_QUERY は -query 未指定時の検索条件
+const DEFAULT_DUPLICATE_QUERY = "status=open"
+
+// DuplicateReport は1ストアの重複判定の結果。Orders はオーダー番号から引くクラスタのオーダー。
+type DuplicateReport struct {
+	Clusters []duplicate.Cluster
+	Orders   map[int]*Order
+}
+
+// FindDuplicateClusters は検索条件に一致するオーダーを [DuplicateCheck] keys の項目でつなぎ、
+// 複数のアカウントで購入している同一人物らしきまとまりを返す。
+// 配送先・支払い情報は REST のレスポンスにのみ含まれるため、backend に関わらず REST で取得する。
+func FindDuplicateClusters(query string, setting *config.DuplicateCheck, store *config.Store) (*DuplicateReport, error) {
+	if query == "" {
+		query = DEFAULT_DUPLICATE_QUERY
+	}
+	orderQuery, err := ParseOrderQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("INFO : Search orders by query '%s' in store '%s'\n", query, store.Name)
+	orders, err := searchOrders(orderQuery, store)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DuplicateReport{Orders: map[int]*Order{}}
+	var duplicateOrders []duplicate.Order
+	for i := range orders {
+		order := &orders[i]
+		report.Orders[order.OrderNumber] = order
+		duplicateOrders = append(duplicateOrders, duplicate.Order{
+			OrderNumber: order.OrderNumber,
+			CustomerKey: customerKey(order),
+			Keys:        duplicateKeys(order),
+		})
+	}
+
+	report.Clusters = duplicate.FindClusters(duplicateOrders, setting.Keys, setting.MinAccounts)
+	log.Printf("INFO : %d orders, %d clusters found by %s\n", len(orders), len(report.Clusters), strings.Join(setting.Keys, ", "))
+	return report, nil
+}
+
+// customerKey はオーダーのアカウント。顧客 ID、無い場合 (ゲスト購入) はメールアドレス。
+func customerKey(order *Order) string {
+	if order.Customer != nil && order.Customer.ID != 0 {
+		return strconv.FormatInt(order.Customer.ID, 10)
+	}
+	return strings.ToLower(order.Email)
+}
+
+// duplicateKeys はオーダーの判定項目毎の正規化した値を返す。
+func duplicateKeys(order *Order) map[string][]string {
+	keys := map[string][]string{}
+
+	keys[config.DUPLICATE_KEY_ADDRESS] = []string{order.ShippingAddress.NormalizedKey()}
+
+	phones := []string{order.Phone}
+	if order.ShippingAddress != nil {
+		phones = append(phones, order.ShippingAddress.Phone)
+	}
+	if order.Customer != nil {
+		phones = append(phones, order.Customer.Phone)
+	}
+	for _, phone := range phones {
+		digits := normalizePhone(phone)
+		if len(digits) >= MIN_PHONE_DIGITS {
+			keys[config.DUPLICATE_KEY_PHONE] = append(keys[config.DUPLICATE_KEY_PHONE], digits)
+		}
+	}
+
+	if order.Email != "" {
+		keys[config.DUPLICATE_KEY_EMAIL] = []string{duplicate.NormalizeEmail(order.Email)}
+	}
+
+	browserIP := order.BrowserIP
+	if browserIP == "" && order.ClientDetails != nil {
+		browserIP = order.ClientDetails.BrowserIP
+	}
+	keys[config.DUPLICATE_KEY_IP] = []string{browserIP}
+
+	keys[config.DUPLICATE_KEY_CARD] = []string{cardKey(order.PaymentDetails)}
+
+	return keys
+}
+
+// cardKey はカードの BIN と下4桁を "BIN-下4桁" にする。どちらかが無い場合は空。
+func cardKey(paymentDetails *PaymentDetails) string {
+	if paymentDetails == nil {
+		return ""
+	}
+	bin := strings.TrimSpace(paymentDetails.CreditCardBin.String())
+	digits := onlyDigits(paymentDetails.CreditCardNumber.String())
+	if bin == "" || len(digits) < 4 {
+		return ""
+	}
+	return bin + "-" + digits[len(digits)-4:]
+}
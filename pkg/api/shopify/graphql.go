@@ -0,0 +1,1494 @@
+package shopify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/infrastructure/http"
+	"shopify-manager/pkg/infrastructure/ratelimit"
+)
+
+const GID_ORDER = "gid://shopify/Order/%d"
+const GID_TRANSACTION = "gid://shopify/OrderTransaction/%d"
+const GID_PRODUCT_VARIANT = "gid://shopify/ProductVariant/%d"
+const GID_DRAFT_ORDER = "gid://shopify/DraftOrder/%d"
+const GID_LINE_ITEM = "gid://shopify/LineItem/%d"
+const GID_LOCATION = "gid://shopify/Location/%d"
+
+// GraphQL の1回の取得で指定できるオーダー・line item・返金の数。
+// クエリのコスト (上限 1000) は first の積で見積もられるため、返金の line item を含めて上限に収まる数にする。
+const GRAPHQL_ORDERS_PAGE_LIMIT = 5
+const GRAPHQL_LINE_ITEMS_LIMIT = 20
+const GRAPHQL_REFUNDS_LIMIT = 5
+
+// THROTTLED のリクエストは実行されていないため、コストの回復を待ってこの回数まで再送する。
+const MAX_RETRY_ON_THROTTLED = 5
+const GRAPHQL_ERROR_CODE_THROTTLED = "THROTTLED"
+
+const GRAPHQL_ADDRESS_FIELDS = `
+	firstName
+	lastName
+	name
+	company
+	address1
+	address2
+	city
+	province
+	provinceCode
+	country
+	countryCodeV2
+	zip
+	phone
+`
+
+// GRAPHQL_ORDER_FIELDS は REST の Order のうち、自動キャンセルのルール・在庫戻りの確認・
+// 顧客へのお知らせで使う項目 (line item・返金・住所・カードの支払い情報を含む)。
+const GRAPHQL_ORDER_FIELDS = `
+	id
+	legacyResourceId
+	name
+	email
+	phone
+	note
+	test
+	tags
+	createdAt
+	cancelledAt
+	closedAt
+	clientIp
+	customerLocale
+	currencyCode
+	displayFinancialStatus
+	paymentGatewayNames
+	totalPriceSet { shopMoney { amount currencyCode } }
+	totalDiscountsSet { shopMoney { amount } }
+	customer { legacyResourceId email firstName lastName phone tags }
+	shippingAddress {` + GRAPHQL_ADDRESS_FIELDS + `}
+	billingAddress {` + GRAPHQL_ADDRESS_FIELDS + `}
+	lineItems(first: $lineItems) {
+		pageInfo { hasNextPage }
+		edges { node {
+			id
+			name
+			title
+			variantTitle
+			sku
+			quantity
+			unfulfilledQuantity
+			requiresShipping
+			originalUnitPriceSet { shopMoney { amount } }
+			variant { legacyResourceId inventoryItem { tracked } }
+			product { legacyResourceId }
+		} }
+	}
+	refunds(first: $refunds) {
+		legacyResourceId
+		createdAt
+		refundLineItems(first: $lineItems) {
+			pageInfo { hasNextPage }
+			edges { node { quantity restockType lineItem { id } location { legacyResourceId } } }
+		}
+	}
+	transactions(first: 5) {
+		kind
+		status
+		paymentDetails {
+			... on CardPaymentDetails { bin number company avsResultCode cvvResultCode }
+		}
+	}
+`
+
+const GRAPHQL_GET_ORDERS_QUERY = `
+query getOrders($first: Int!, $lineItems: Int!, $refunds: Int!, $after: String, $query: String) {
+	orders(first: $first, after: $after, query: $query, sortKey: ID) {
+		pageInfo { hasNextPage endCursor }
+		edges { node {` + GRAPHQL_ORDER_FIELDS + `} }
+	}
+}`
+
+const GRAPHQL_GET_TRANSACTIONS_QUERY = `
+query getTransactions($id: ID!) {
+	order(id: $id) {
+		transactions { id kind status }
+	}
+}`
+
+const GRAPHQL_TRANSACTION_VOID_MUTATION = `
+mutation transactionVoid($parentTransactionId: ID!) {
+	transactionVoid(parentTransactionId: $parentTransactionId) {
+		transaction { id status }
+		userErrors { field message }
+	}
+}`
+
+const GRAPHQL_ORDER_CAPTURE_MUTATION = `
+mutation orderCapture($input: OrderCaptureInput!) {
+	orderCapture(input: $input) {
+		transaction { id status }
+		userErrors { field message }
+	}
+}`
+
+const GRAPHQL_ORDER_CANCEL_MUTATION = `
+mutation orderCancel($orderId: ID!, $restock: Boolean!) {
+	orderCancel(orderId: $orderId, reason: OTHER, refund: false, restock: $restock, notifyCustomer: true) {
+		job { id }
+		orderCancelUserErrors { field message }
+	}
+}`
+
+const GRAPHQL_SUGGESTED_REFUND_QUERY = `
+query suggestedRefund($id: ID!, $refundLineItems: [RefundLineItemInput!]) {
+	order(id: $id) {
+		suggestedRefund(refundLineItems: $refundLineItems) {
+			amountSet { shopMoney { amount currencyCode } }
+			refundLineItems { quantity restockType lineItem { id } location { legacyResourceId } }
+			suggestedTransactions {
+				kind
+				gateway
+				amountSet { shopMoney { amount currencyCode } }
+				parentTransaction { id }
+			}
+		}
+	}
+}`
+
+const GRAPHQL_REFUND_CREATE_MUTATION = `
+mutation refundCreate($input: RefundInput!) {
+	refundCreate(input: $input) {
+		refund {
+			legacyResourceId
+			refundLineItems(first: 50) { edges { node { quantity restockType lineItem { id } } } }
+			transactions(first: 10) { edges { node { id kind status gateway amountSet { shopMoney { amount currencyCode } } } } }
+		}
+		userErrors { field message }
+	}
+}`
+
+const GRAPHQL_GET_SHOP_QUERY = `
+query getShop {
+	shop {
+		name
+		email
+		myshopifyDomain
+		currencyCode
+		ianaTimezone
+		plan { displayName }
+	}
+	currentAppInstallation {
+		accessScopes { handle }
+	}
+}`
+
+const GRAPHQL_VARIANT_FIELDS = `
+	legacyResourceId
+	title
+	sku
+	price
+	product { legacyResourceId }
+	inventoryItem { legacyResourceId tracked }
+`
+
+const GRAPHQL_GET_VARIANT_QUERY = `
+query getVariant($id: ID!) {
+	productVariant(id: $id) {` + GRAPHQL_VARIANT_FIELDS + `}
+}`
+
+const GRAPHQL_GET_VARIANTS_QUERY = `
+query getVariants($first: Int!, $query: String) {
+	productVariants(first: $first, query: $query) {
+		edges { node {` + GRAPHQL_VARIANT_FIELDS + `} }
+	}
+}`
+
+const GRAPHQL_DRAFT_ORDER_FIELDS = `
+	legacyResourceId
+	name
+	status
+	invoiceUrl
+	tags
+	order { legacyResourceId }
+`
+
+const GRAPHQL_GET_DRAFT_ORDERS_QUERY = `
+query getDraftOrders($first: Int!, $after: String, $query: String) {
+	draftOrders(first: $first, after: $after, query: $query) {
+		pageInfo { hasNextPage endCursor }
+		edges { node {` + GRAPHQL_DRAFT_ORDER_FIELDS + `} }
+	}
+}`
+
+const GRAPHQL_DRAFT_ORDER_CREATE_MUTATION = `
+mutation draftOrderCreate($input: DraftOrderInput!) {
+	draftOrderCreate(input: $input) {
+		draftOrder {` + GRAPHQL_DRAFT_ORDER_FIELDS + `}
+		userErrors { field message }
+	}
+}`
+
+const GRAPHQL_DRAFT_ORDER_INVOICE_SEND_MUTATION = `
+mutation draftOrderInvoiceSend($id: ID!) {
+	draftOrderInvoiceSend(id: $id) {
+		draftOrder {` + GRAPHQL_DRAFT_ORDER_FIELDS + `}
+		userErrors { field message }
+	}
+}`
+
+const GRAPHQL_DRAFT_ORDER_COMPLETE_MUTATION = `
+mutation draftOrderComplete($id: ID!, $paymentPending: Boolean) {
+	draftOrderComplete(id: $id, paymentPending: $paymentPending) {
+		draftOrder {` + GRAPHQL_DRAFT_ORDER_FIELDS + `}
+		userErrors { field message }
+	}
+}`
+
+type GraphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type GraphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
+	} `json:"errors"`
+	Extensions struct {
+		Cost *GraphqlCost `json:"cost"`
+	} `json:"extensions"`
+}
+
+// GraphqlCost はレスポンスの extensions.cost。レートリミットの学習に使う。
+type GraphqlCost struct {
+	RequestedQueryCost float64 `json:"requestedQueryCost"`
+	ThrottleStatus     struct {
+		MaximumAvailable   float64 `json:"maximumAvailable"`
+		CurrentlyAvailable float64 `json:"currentlyAvailable"`
+		RestoreRate        float64 `json:"restoreRate"`
+	} `json:"throttleStatus"`
+}
+
+// GraphqlThrottledError は THROTTLED で実行されなかったリクエストのエラー。
+type GraphqlThrottledError struct {
+	Message string
+}
+
+func (e *GraphqlThrottledError) Error() string {
+	return fmt.Sprintf("GraphQL error. %s", e.Message)
+}
+
+type GraphqlUserError struct {
+	Field   []string `json:"field"`
+	Message string   `json:"message"`
+}
+
+type GraphqlMoneyBag struct {
+	ShopMoney struct {
+		Amount       string `json:"amount"`
+		CurrencyCode string `json:"currencyCode"`
+	} `json:"shopMoney"`
+}
+
+type GraphqlAddress struct {
+	FirstName     string `json:"firstName"`
+	LastName      string `json:"lastName"`
+	Name          string `json:"name"`
+	Company       string `json:"company"`
+	Address1      string `json:"address1"`
+	Address2      string `json:"address2"`
+	City          string `json:"city"`
+	Province      string `json:"province"`
+	ProvinceCode  string `json:"provinceCode"`
+	Country       string `json:"country"`
+	CountryCodeV2 string `json:"countryCodeV2"`
+	Zip           string `json:"zip"`
+	Phone         string `json:"phone"`
+}
+
+type GraphqlLineItem struct {
+	ID                   string          `json:"id"`
+	Name                 string          `json:"name"`
+	Title                string          `json:"title"`
+	VariantTitle         string          `json:"variantTitle"`
+	Sku                  string          `json:"sku"`
+	Quantity             int             `json:"quantity"`
+	UnfulfilledQuantity  int             `json:"unfulfilledQuantity"`
+	RequiresShipping     bool            `json:"requiresShipping"`
+	OriginalUnitPriceSet GraphqlMoneyBag `json:"originalUnitPriceSet"`
+	Variant              *struct {
+		LegacyResourceID string `json:"legacyResourceId"`
+		InventoryItem    struct {
+			Tracked bool `json:"tracked"`
+		} `json:"inventoryItem"`
+	} `json:"variant"`
+	Product *struct {
+		LegacyResourceID string `json:"legacyResourceId"`
+	} `json:"product"`
+}
+
+type GraphqlOrder struct {
+	ID                     string          `json:"id"`
+	LegacyResourceID       string          `json:"legacyResourceId"`
+	Name                   string          `json:"name"`
+	Email                  string          `json:"email"`
+	Phone                  string          `json:"phone"`
+	Note                   string          `json:"note"`
+	Test                   bool            `json:"test"`
+	Tags                   []string        `json:"tags"`
+	CreatedAt              string          `json:"createdAt"`
+	CancelledAt            string          `json:"cancelledAt"`
+	ClosedAt               string          `json:"closedAt"`
+	ClientIP               string          `json:"clientIp"`
+	CustomerLocale         string          `json:"customerLocale"`
+	CurrencyCode           string          `json:"currencyCode"`
+	DisplayFinancialStatus string          `json:"displayFinancialStatus"`
+	PaymentGatewayNames    []string        `json:"paymentGatewayNames"`
+	TotalPriceSet          GraphqlMoneyBag `json:"totalPriceSet"`
+	TotalDiscountsSet      GraphqlMoneyBag `json:"totalDiscountsSet"`
+	Customer               *struct {
+		LegacyResourceID string   `json:"legacyResourceId"`
+		Email            string   `json:"email"`
+		FirstName        string   `json:"firstName"`
+		LastName         string   `json:"lastName"`
+		Phone            string   `json:"phone"`
+		Tags             []string `json:"tags"`
+	} `json:"customer"`
+	ShippingAddress *GraphqlAddress `json:"shippingAddress"`
+	BillingAddress  *GraphqlAddress `json:"billingAddress"`
+	LineItems       struct {
+		PageInfo struct {
+			HasNextPage bool `json:"hasNextPage"`
+		} `json:"pageInfo"`
+		Edges []struct {
+			Node GraphqlLineItem `json:"node"`
+		} `json:"edges"`
+	} `json:"lineItems"`
+	Refunds []struct {
+		LegacyResourceID string `json:"legacyResourceId"`
+		CreatedAt        string `json:"createdAt"`
+		RefundLineItems  struct {
+			PageInfo struct {
+				HasNextPage bool `json:"hasNextPage"`
+			} `json:"pageInfo"`
+			Edges []struct {
+				Node GraphqlRefundLineItem `json:"node"`
+			} `json:"edges"`
+		} `json:"refundLineItems"`
+	} `json:"refunds"`
+	Transactions []struct {
+		Kind           string `json:"kind"`
+		Status         string `json:"status"`
+		PaymentDetails *struct {
+			Bin           string `json:"bin"`
+			Number        string `json:"number"`
+			Company       string `json:"company"`
+			AvsResultCode string `json:"avsResultCode"`
+			CvvResultCode string `json:"cvvResultCode"`
+		} `json:"paymentDetails"`
+	} `json:"transactions"`
+}
+
+type GraphqlGetOrdersData struct {
+	Orders struct {
+		PageInfo struct {
+			HasNextPage bool   `json:"hasNextPage"`
+			EndCursor   string `json:"endCursor"`
+		} `json:"pageInfo"`
+		Edges []struct {
+			Node GraphqlOrder `json:"node"`
+		} `json:"edges"`
+	} `json:"orders"`
+}
+
+type GraphqlGetTransactionsData struct {
+	Order *struct {
+		Transactions []struct {
+			ID     string `json:"id"`
+			Kind   string `json:"kind"`
+			Status string `json:"status"`
+		} `json:"transactions"`
+	} `json:"order"`
+}
+
+type GraphqlTransactionMutationData struct {
+	Transaction *struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	} `json:"transaction"`
+	UserErrors []GraphqlUserError `json:"userErrors"`
+}
+
+type GraphqlOrderCancelData struct {
+	OrderCancel struct {
+		Job *struct {
+			ID string `json:"id"`
+		} `json:"job"`
+		OrderCancelUserErrors []GraphqlUserError `json:"orderCancelUserErrors"`
+	} `json:"orderCancel"`
+}
+
+type GraphqlRefundLineItem struct {
+	Quantity    int    `json:"quantity"`
+	RestockType string `json:"restockType"`
+	LineItem    struct {
+		ID string `json:"id"`
+	} `json:"lineItem"`
+	Location *struct {
+		LegacyResourceID string `json:"legacyResourceId"`
+	} `json:"location"`
+}
+
+type GraphqlSuggestedRefundData struct {
+	Order *struct {
+		SuggestedRefund struct {
+			AmountSet             GraphqlMoneyBag         `json:"amountSet"`
+			RefundLineItems       []GraphqlRefundLineItem `json:"refundLineItems"`
+			SuggestedTransactions []struct {
+				Kind              string          `json:"kind"`
+				Gateway           string          `json:"gateway"`
+				AmountSet         GraphqlMoneyBag `json:"amountSet"`
+				ParentTransaction *struct {
+					ID string `json:"id"`
+				} `json:"parentTransaction"`
+			} `json:"suggestedTransactions"`
+		} `json:"suggestedRefund"`
+	} `json:"order"`
+}
+
+type GraphqlRefundCreateData struct {
+	RefundCreate struct {
+		Refund *struct {
+			LegacyResourceID string `json:"legacyResourceId"`
+			RefundLineItems  struct {
+				Edges []struct {
+					Node GraphqlRefundLineItem `json:"node"`
+				} `json:"edges"`
+			} `json:"refundLineItems"`
+			Transactions struct {
+				Edges []struct {
+					Node struct {
+						ID        string          `json:"id"`
+						Kind      string          `json:"kind"`
+						Status    string          `json:"status"`
+						Gateway   string          `json:"gateway"`
+						AmountSet GraphqlMoneyBag `json:"amountSet"`
+					} `json:"node"`
+				} `json:"edges"`
+			} `json:"transactions"`
+		} `json:"refund"`
+		UserErrors []GraphqlUserError `json:"userErrors"`
+	} `json:"refundCreate"`
+}
+
+type GraphqlGetShopData struct {
+	Shop struct {
+		Name            string `json:"name"`
+		Email           string `json:"email"`
+		MyshopifyDomain string `json:"myshopifyDomain"`
+		CurrencyCode    string `json:"currencyCode"`
+		IanaTimezone    string `json:"ianaTimezone"`
+		Plan            struct {
+			DisplayName string `json:"displayName"`
+		} `json:"plan"`
+	} `json:"shop"`
+	CurrentAppInstallation struct {
+		AccessScopes []struct {
+			Handle string `json:"handle"`
+		} `json:"accessScopes"`
+	} `json:"currentAppInstallation"`
+}
+
+type GraphqlVariant struct {
+	LegacyResourceID string `json:"legacyResourceId"`
+	Title            string `json:"title"`
+	Sku              string `json:"sku"`
+	Price            string `json:"price"`
+	Product          struct {
+		LegacyResourceID string `json:"legacyResourceId"`
+	} `json:"product"`
+	InventoryItem struct {
+		LegacyResourceID string `json:"legacyResourceId"`
+		Tracked          bool   `json:"tracked"`
+	} `json:"inventoryItem"`
+}
+
+type GraphqlGetVariantData struct {
+	ProductVariant *GraphqlVariant `json:"productVariant"`
+}
+
+type GraphqlGetVariantsData struct {
+	ProductVariants struct {
+		Edges []struct {
+			Node GraphqlVariant `json:"node"`
+		} `json:"edges"`
+	} `json:"productVariants"`
+}
+
+type GraphqlDraftOrder struct {
+	LegacyResourceID string   `json:"legacyResourceId"`
+	Name             string   `json:"name"`
+	Status           string   `json:"status"`
+	InvoiceURL       string   `json:"invoiceUrl"`
+	Tags             []string `json:"tags"`
+	Order            *struct {
+		LegacyResourceID string `json:"legacyResourceId"`
+	} `json:"order"`
+}
+
+type GraphqlGetDraftOrdersData struct {
+	DraftOrders struct {
+		PageInfo struct {
+			HasNextPage bool   `json:"hasNextPage"`
+			EndCursor   string `json:"endCursor"`
+		} `json:"pageInfo"`
+		Edges []struct {
+			Node GraphqlDraftOrder `json:"node"`
+		} `json:"edges"`
+	} `json:"draftOrders"`
+}
+
+type GraphqlDraftOrderMutationData struct {
+	DraftOrder *GraphqlDraftOrder `json:"draftOrder"`
+	UserErrors []GraphqlUserError `json:"userErrors"`
+}
+
+// graphqlClient は GraphQL Admin API で Client を実装する。
+// 取得結果は REST と同じ Order に詰め替えて返す。
+type graphqlClient struct {
+	store *config.Store
+}
+
+func (c *graphqlClient) GetOrder(orderNumber int) (*Order, error) {
+	variables := map[string]interface{}{
+		"first":     1,
+		"lineItems": GRAPHQL_LINE_ITEMS_LIMIT,
+		"refunds":   GRAPHQL_REFUNDS_LIMIT,
+		"query":     fmt.Sprintf("name:%d", orderNumber),
+	}
+	data := new(GraphqlGetOrdersData)
+	err := c.execute(GRAPHQL_GET_ORDERS_QUERY, variables, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data.Orders.Edges) < 1 {
+		return nil, fmt.Errorf("Not found Order by order number '%d'", orderNumber)
+	}
+
+	return toOrder(data.Orders.Edges[0].Node)
+}
+
+func (c *graphqlClient) SearchOrders(query *OrderQuery) ([]Order, error) {
+	var orders []Order
+	variables := map[string]interface{}{
+		"first":     GRAPHQL_ORDERS_PAGE_LIMIT,
+		"lineItems": GRAPHQL_LINE_ITEMS_LIMIT,
+		"refunds":   GRAPHQL_REFUNDS_LIMIT,
+		"query":     toGraphqlSearchQuery(query),
+	}
+	for {
+		data := new(GraphqlGetOrdersData)
+		err := c.execute(GRAPHQL_GET_ORDERS_QUERY, variables, data)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, edge := range data.Orders.Edges {
+			order, err := toOrder(edge.Node)
+			if err != nil {
+				return nil, err
+			}
+			// 検索構文の解釈差を吸収するため REST と同じ条件で絞り込み直す
+			if orderMatchesQuery(order, query) {
+				orders = append(orders, *order)
+			}
+		}
+
+		if !data.Orders.PageInfo.HasNextPage {
+			break
+		}
+		variables["after"] = data.Orders.PageInfo.EndCursor
+	}
+
+	return orders, nil
+}
+
+func (c *graphqlClient) GetAuthorizationTransactionId(orderId int64) (int64, error) {
+	variables := map[string]interface{}{
+		"id": fmt.Sprintf(GID_ORDER, orderId),
+	}
+	data := new(GraphqlGetTransactionsData)
+	err := c.execute(GRAPHQL_GET_TRANSACTIONS_QUERY, variables, data)
+	if err != nil {
+		return -1, err
+	}
+
+	if data.Order == nil || len(data.Order.Transactions) < 1 {
+		return -1, fmt.Errorf("Not found transaction by orderId '%d'", orderId)
+	}
+
+	for _, transaction := range data.Order.Transactions {
+		if transaction.Kind == "AUTHORIZATION" && transaction.Status == "SUCCESS" {
+			return parseGid(transaction.ID)
+		}
+	}
+
+	return -1, fmt.Errorf("Found transaction but no exists authorization type transaction")
+}
+
+func (c *graphqlClient) VoidTransaction(order *Order, transactionId int64) error {
+	variables := map[string]interface{}{
+		"parentTransactionId": fmt.Sprintf(GID_TRANSACTION, transactionId),
+	}
+	data := struct {
+		TransactionVoid GraphqlTransactionMutationData `json:"transactionVoid"`
+	}{}
+	return c.executeMutation(AUDIT_ACTION_VOID, order, transactionId, GRAPHQL_TRANSACTION_VOID_MUTATION, variables, &data, func() error {
+		return checkTransactionMutation("transactionVoid", data.TransactionVoid)
+	})
+}
+
+func (c *graphqlClient) CaptureTransaction(order *Order, transactionId int64, amount string) error {
+	variables := map[string]interface{}{
+		"input": map[string]interface{}{
+			"id":                  fmt.Sprintf(GID_ORDER, order.ID),
+			"parentTransactionId": fmt.Sprintf(GID_TRANSACTION, transactionId),
+			"amount":              amount,
+		},
+	}
+	data := struct {
+		OrderCapture GraphqlTransactionMutationData `json:"orderCapture"`
+	}{}
+	return c.executeMutation(AUDIT_ACTION_CAPTURE, order, transactionId, GRAPHQL_ORDER_CAPTURE_MUTATION, variables, &data, func() error {
+		return checkTransactionMutation("orderCapture", data.OrderCapture)
+	})
+}
+
+func (c *graphqlClient) CancelOrder(order *Order) error {
+	variables := map[string]interface{}{
+		"orderId": fmt.Sprintf(GID_ORDER, order.ID),
+		"restock": RESTOCK_ON_CANCEL,
+	}
+	data := new(GraphqlOrderCancelData)
+	return c.executeMutation(AUDIT_ACTION_CANCEL, order, 0, GRAPHQL_ORDER_CANCEL_MUTATION, variables, data, func() error {
+		if len(data.OrderCancel.OrderCancelUserErrors) > 0 {
+			return fmt.Errorf("orderCancel failed. %s", joinUserErrors(data.OrderCancel.OrderCancelUserErrors))
+		}
+		return nil
+	})
+}
+
+// CalculateRefund は suggestedRefund で返金額を計算し、REST の refunds/calculate と同じ形で返す。
+func (c *graphqlClient) CalculateRefund(order *Order, refundLineItems []RefundLineItemRequest) (*CalculateRefundResponse, error) {
+	variables := map[string]interface{}{
+		"id":              fmt.Sprintf(GID_ORDER, order.ID),
+		"refundLineItems": toGraphqlRefundLineItemInputs(refundLineItems),
+	}
+	data := new(GraphqlSuggestedRefundData)
+	err := c.execute(GRAPHQL_SUGGESTED_REFUND_QUERY, variables, data)
+	if err != nil {
+		return nil, err
+	}
+	if data.Order == nil {
+		return nil, fmt.Errorf("Not found Order by orderId '%d'", order.ID)
+	}
+
+	suggested := data.Order.SuggestedRefund
+	calculated := new(CalculateRefundResponse)
+	calculated.Refund.Currency = suggested.AmountSet.ShopMoney.CurrencyCode
+	for _, refundLineItem := range suggested.RefundLineItems {
+		lineItem, err := toCalculatedRefundLineItem(refundLineItem)
+		if err != nil {
+			return nil, err
+		}
+		calculated.Refund.RefundLineItems = append(calculated.Refund.RefundLineItems, *lineItem)
+	}
+	for _, transaction := range suggested.SuggestedTransactions {
+		amount, err := ParseDecimal(transaction.AmountSet.ShopMoney.Amount)
+		if err != nil {
+			return nil, err
+		}
+		suggestedTransaction := SuggestedRefundTransaction{
+			OrderID:  order.ID,
+			Amount:   amount,
+			Kind:     strings.ToLower(transaction.Kind),
+			Gateway:  transaction.Gateway,
+			Currency: transaction.AmountSet.ShopMoney.CurrencyCode,
+		}
+		if transaction.ParentTransaction != nil {
+			suggestedTransaction.ParentID, err = parseGid(transaction.ParentTransaction.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		calculated.Refund.Transactions = append(calculated.Refund.Transactions, suggestedTransaction)
+	}
+	return calculated, nil
+}
+
+// CreateRefund は計算結果の line item と返金額で refundCreate を実行する。
+// オーソリのみで売上確定前のオーダーは返金額が 0 になるため、取引は送らない。
+func (c *graphqlClient) CreateRefund(order *Order, calculated *CalculateRefundResponse) (*Refund, error) {
+	var refundLineItems []RefundLineItemRequest
+	for _, lineItem := range calculated.Refund.RefundLineItems {
+		refundLineItems = append(refundLineItems, RefundLineItemRequest{
+			LineItemID:  lineItem.LineItemID,
+			Quantity:    lineItem.Quantity,
+			RestockType: lineItem.RestockType,
+			LocationID:  lineItem.LocationID,
+		})
+	}
+	var transactions []map[string]interface{}
+	for _, transaction := range calculated.Refund.Transactions {
+		if !transaction.Amount.IsPositive() {
+			continue
+		}
+		transactions = append(transactions, map[string]interface{}{
+			"orderId":  fmt.Sprintf(GID_ORDER, order.ID),
+			"parentId": fmt.Sprintf(GID_TRANSACTION, transaction.ParentID),
+			"amount":   transaction.Amount.String(),
+			"gateway":  transaction.Gateway,
+			"kind":     "REFUND",
+		})
+	}
+	input := map[string]interface{}{
+		"orderId":         fmt.Sprintf(GID_ORDER, order.ID),
+		"notify":          true,
+		"refundLineItems": toGraphqlRefundLineItemInputs(refundLineItems),
+		"transactions":    transactions,
+	}
+	if calculated.Refund.Currency != "" {
+		input["currency"] = calculated.Refund.Currency
+	}
+
+	var refund *Refund
+	data := new(GraphqlRefundCreateData)
+	err := c.executeMutation(AUDIT_ACTION_REFUND, order, 0, GRAPHQL_REFUND_CREATE_MUTATION, map[string]interface{}{"input": input}, data, func() error {
+		if len(data.RefundCreate.UserErrors) > 0 {
+			return fmt.Errorf("refundCreate failed. %s", joinUserErrors(data.RefundCreate.UserErrors))
+		}
+		if data.RefundCreate.Refund == nil {
+			return fmt.Errorf("refundCreate returned no refund")
+		}
+		var err error
+		refund, err = toRefund(order, data)
+		return err
+	})
+	return refund, err
+}
+
+func (c *graphqlClient) GetShop() (*Shop, error) {
+	data := new(GraphqlGetShopData)
+	err := c.execute(GRAPHQL_GET_SHOP_QUERY, nil, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Shop{
+		Name:            data.Shop.Name,
+		Email:           data.Shop.Email,
+		MyshopifyDomain: data.Shop.MyshopifyDomain,
+		PlanName:        data.Shop.Plan.DisplayName,
+		Currency:        data.Shop.CurrencyCode,
+		IanaTimezone:    data.Shop.IanaTimezone,
+	}, nil
+}
+
+func (c *graphqlClient) GetAccessScopes() ([]string, error) {
+	data := new(GraphqlGetShopData)
+	err := c.execute(GRAPHQL_GET_SHOP_QUERY, nil, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var scopes []string
+	for _, scope := range data.CurrentAppInstallation.AccessScopes {
+		scopes = append(scopes, scope.Handle)
+	}
+	return scopes, nil
+}
+
+func (c *graphqlClient) GetVariant(variantId int64) (*Variant, error) {
+	variables := map[string]interface{}{
+		"id": fmt.Sprintf(GID_PRODUCT_VARIANT, variantId),
+	}
+	data := new(GraphqlGetVariantData)
+	err := c.execute(GRAPHQL_GET_VARIANT_QUERY, variables, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.ProductVariant == nil {
+		return nil, fmt.Errorf("Not found variant by variantId '%d'", variantId)
+	}
+	return toVariant(*data.ProductVariant)
+}
+
+func (c *graphqlClient) FindVariantBySku(sku string) (*Variant, error) {
+	variables := map[string]interface{}{
+		"first": 2,
+		"query": fmt.Sprintf("sku:'%s'", sku),
+	}
+	data := new(GraphqlGetVariantsData)
+	err := c.execute(GRAPHQL_GET_VARIANTS_QUERY, variables, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// 検索は前方一致などを含むため、SKU が一致するものだけを使う
+	var found []GraphqlVariant
+	for _, edge := range data.ProductVariants.Edges {
+		if edge.Node.Sku == sku {
+			found = append(found, edge.Node)
+		}
+	}
+	if len(found) < 1 {
+		return nil, fmt.Errorf("Not found variant by sku '%s'", sku)
+	}
+	if len(found) > 1 {
+		return nil, fmt.Errorf("SKU '%s' の商品が複数あります。variant ID で指定してください", sku)
+	}
+	return toVariant(found[0])
+}
+
+func (c *graphqlClient) FindDraftOrdersByTag(tag string) ([]DraftOrder, error) {
+	var draftOrders []DraftOrder
+	variables := map[string]interface{}{
+		"first": GRAPHQL_ORDERS_PAGE_LIMIT,
+		"query": fmt.Sprintf("tag:'%s'", tag),
+	}
+	for {
+		data := new(GraphqlGetDraftOrdersData)
+		err := c.execute(GRAPHQL_GET_DRAFT_ORDERS_QUERY, variables, data)
+		if err != nil {
+			return nil, err
+		}
+
+		// 検索は前方一致などを含むため、タグが一致するものだけを使う
+		for _, edge := range data.DraftOrders.Edges {
+			draftOrder, err := toDraftOrder(edge.Node)
+			if err != nil {
+				return nil, err
+			}
+			if !hasTag(draftOrder.Tags, tag) {
+				continue
+			}
+			draftOrders = append(draftOrders, *draftOrder)
+		}
+
+		if !data.DraftOrders.PageInfo.HasNextPage {
+			break
+		}
+		variables["after"] = data.DraftOrders.PageInfo.EndCursor
+	}
+
+	return draftOrders, nil
+}
+
+func (c *graphqlClient) CreateDraftOrder(draftOrder *DraftOrder) (*DraftOrder, error) {
+	input, err := toGraphqlDraftOrderInput(draftOrder)
+	if err != nil {
+		return nil, err
+	}
+	variables := map[string]interface{}{
+		"input": input,
+	}
+	data := struct {
+		DraftOrderCreate GraphqlDraftOrderMutationData `json:"draftOrderCreate"`
+	}{}
+	return c.executeDraftOrderMutation(AUDIT_ACTION_CREATE_DRAFT_ORDER, 0, GRAPHQL_DRAFT_ORDER_CREATE_MUTATION, variables, &data, "draftOrderCreate", &data.DraftOrderCreate)
+}
+
+func (c *graphqlClient) SendDraftOrderInvoice(draftOrder *DraftOrder) error {
+	variables := map[string]interface{}{
+		"id": fmt.Sprintf(GID_DRAFT_ORDER, draftOrder.ID),
+	}
+	data := struct {
+		DraftOrderInvoiceSend GraphqlDraftOrderMutationData `json:"draftOrderInvoiceSend"`
+	}{}
+	_, err := c.executeDraftOrderMutation(AUDIT_ACTION_SEND_DRAFT_ORDER_INVOICE, draftOrder.ID, GRAPHQL_DRAFT_ORDER_INVOICE_SEND_MUTATION, variables, &data, "draftOrderInvoiceSend", &data.DraftOrderInvoiceSend)
+	return err
+}
+
+func (c *graphqlClient) CompleteDraftOrder(draftOrder *DraftOrder, paymentPending bool) (*DraftOrder, error) {
+	variables := map[string]interface{}{
+		"id":             fmt.Sprintf(GID_DRAFT_ORDER, draftOrder.ID),
+		"paymentPending": paymentPending,
+	}
+	data := struct {
+		DraftOrderComplete GraphqlDraftOrderMutationData `json:"draftOrderComplete"`
+	}{}
+	return c.executeDraftOrderMutation(AUDIT_ACTION_COMPLETE_DRAFT_ORDER, draftOrder.ID, GRAPHQL_DRAFT_ORDER_COMPLETE_MUTATION, variables, &data, "draftOrderComplete", &data.DraftOrderComplete)
+}
+
+func (c *graphqlClient) execute(query string, variables map[string]interface{}, data interface{}) error {
+	reqJsonBytes, err := json.Marshal(GraphqlRequest{Query: query, Variables: variables})
+	if err != nil {
+		log.Println("GraphQL request json marshal error")
+		return err
+	}
+
+	_, err = c.post(reqJsonBytes, data)
+	return err
+}
+
+// executeMutation は変更を伴う mutation を実行して監査ファイルに記録する。
+// check は userErrors などレスポンスの内容による失敗を判定する。
+func (c *graphqlClient) executeMutation(action string, order *Order, transactionId int64, query string, variables map[string]interface{}, data interface{}, check func() error) error {
+	err := checkAudit(action)
+	if err != nil {
+		return err
+	}
+
+	reqJsonBytes, res, err := c.mutate(query, variables, data, check)
+	return recordAudit(c.store, action, order, transactionId, reqJsonBytes, res, err)
+}
+
+// executeDraftOrderMutation は下書き注文の mutation を実行して監査ファイルに記録し、結果の下書き注文を返す。
+// 作成時は draftOrderId に 0 を渡し、作成された下書き注文の ID を記録する。
+func (c *graphqlClient) executeDraftOrderMutation(action string, draftOrderId int64, query string, variables map[string]interface{}, data interface{}, name string, mutationData *GraphqlDraftOrderMutationData) (*DraftOrder, error) {
+	err := checkAudit(action)
+	if err != nil {
+		return nil, err
+	}
+
+	var draftOrder *DraftOrder
+	reqJsonBytes, res, err := c.mutate(query, variables, data, func() error {
+		if len(mutationData.UserErrors) > 0 {
+			return fmt.Errorf("%s failed. %s", name, joinUserErrors(mutationData.UserErrors))
+		}
+		if mutationData.DraftOrder == nil {
+			return fmt.Errorf("%s returned no draft order", name)
+		}
+		var err error
+		draftOrder, err = toDraftOrder(*mutationData.DraftOrder)
+		return err
+	})
+	if draftOrderId == 0 && draftOrder != nil {
+		draftOrderId = draftOrder.ID
+	}
+	err = recordDraftOrderAudit(c.store, action, nil, draftOrderId, reqJsonBytes, res, err)
+
+	return draftOrder, err
+}
+
+// mutate は mutation を実行し、監査ファイルに記録するリクエストとレスポンスを返す。
+func (c *graphqlClient) mutate(query string, variables map[string]interface{}, data interface{}, check func() error) ([]byte, *http.Response, error) {
+	reqJsonBytes, err := json.Marshal(GraphqlRequest{Query: query, Variables: variables})
+	if err != nil {
+		log.Println("GraphQL request json marshal error")
+		return nil, nil, err
+	}
+
+	res, err := c.post(reqJsonBytes, data)
+	if err == nil {
+		err = check()
+	}
+	return reqJsonBytes, res, err
+}
+
+// post はリクエストを送ってレスポンスを data に読み込む。
+// THROTTLED は HTTP 200 で返るため、extensions.cost をレートリミットに反映して回復を待ってから再送する。
+func (c *graphqlClient) post(reqJsonBytes []byte, data interface{}) (*http.Response, error) {
+	limiter := ratelimit.For(c.store.Domain)
+	for retry := 0; ; retry++ {
+		res, err := http.PostWithResponse(c.graphqlUrl(), reqJsonBytes, c.graphqlHeader())
+		if err != nil {
+			return res, err
+		}
+
+		err = decodeGraphqlResponse(res.Body, data, limiter)
+		if _, ok := err.(*GraphqlThrottledError); !ok || retry >= MAX_RETRY_ON_THROTTLED {
+			return res, err
+		}
+		log.Printf("WARN : store '%s' GraphQL request was throttled. retry %d/%d\n", c.store.Name, retry+1, MAX_RETRY_ON_THROTTLED)
+	}
+}
+
+func (c *graphqlClient) graphqlUrl() string {
+	return fmt.Sprintf(constants.GRAPHQL_URL_TEMPLATE, c.store.Domain, c.store.GraphqlApiVersion)
+}
+
+func (c *graphqlClient) graphqlHeader() map[string]string {
+	httpReqHeader := map[string]string{}
+	httpReqHeader["Content-Type"] = "application/json"
+	httpReqHeader["X-Shopify-Access-Token"] = c.store.ApiPassword
+	return httpReqHeader
+}
+
+// decodeGraphqlResponse はレスポンスを data に読み込み、extensions.cost を limiter に反映する。
+// THROTTLED の場合は GraphqlThrottledError を返す。
+func decodeGraphqlResponse(jsonRes []byte, data interface{}, limiter *ratelimit.Limiter) error {
+	graphqlRes := new(GraphqlResponse)
+	err := json.Unmarshal(jsonRes, &graphqlRes)
+	if err != nil {
+		log.Println("GraphQL response json unmarshal err")
+		return err
+	}
+
+	throttled := false
+	var messages []string
+	for _, graphqlErr := range graphqlRes.Errors {
+		messages = append(messages, graphqlErr.Message)
+		if graphqlErr.Extensions.Code == GRAPHQL_ERROR_CODE_THROTTLED {
+			throttled = true
+		}
+	}
+
+	if cost := graphqlRes.Extensions.Cost; cost != nil {
+		limiter.ObserveCost(cost.RequestedQueryCost, cost.ThrottleStatus.CurrentlyAvailable, cost.ThrottleStatus.MaximumAvailable, cost.ThrottleStatus.RestoreRate, throttled)
+	} else if throttled {
+		// cost が返らない場合はコスト 1 が毎秒 1 回復するものとして 1 秒待つ
+		limiter.ObserveCost(1, 0, 1, 1, true)
+	}
+
+	if throttled {
+		return &GraphqlThrottledError{Message: strings.Join(messages, " / ")}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("GraphQL error. %s", strings.Join(messages, " / "))
+	}
+
+	err = json.Unmarshal(graphqlRes.Data, data)
+	if err != nil {
+		log.Println("GraphQL response data json unmarshal err")
+		return err
+	}
+
+	return nil
+}
+
+func checkTransactionMutation(name string, data GraphqlTransactionMutationData) error {
+	if len(data.UserErrors) > 0 {
+		return fmt.Errorf("%s failed. %s", name, joinUserErrors(data.UserErrors))
+	}
+	if data.Transaction == nil || data.Transaction.Status != "SUCCESS" {
+		status := ""
+		if data.Transaction != nil {
+			status = data.Transaction.Status
+		}
+		return fmt.Errorf("%s transaction status is not success. actual %s", name, status)
+	}
+	return nil
+}
+
+func joinUserErrors(userErrors []GraphqlUserError) string {
+	var messages []string
+	for _, userError := range userErrors {
+		messages = append(messages, fmt.Sprintf("%s: %s", strings.Join(userError.Field, "."), userError.Message))
+	}
+	return strings.Join(messages, " / ")
+}
+
+// toGraphqlSearchQuery は OrderQuery を GraphQL の検索構文に変換する。
+func toGraphqlSearchQuery(query *OrderQuery) string {
+	var terms []string
+	if query.Status != "" && query.Status != "any" {
+		terms = append(terms, "status:"+query.Status)
+	}
+	if query.FinancialStatus != "" {
+		terms = append(terms, "financial_status:"+query.FinancialStatus)
+	}
+	if !query.CreatedAtMin.IsZero() {
+		terms = append(terms, fmt.Sprintf("created_at:>='%s'", query.CreatedAtMin.Format(time.RFC3339)))
+	}
+	if !query.CreatedAtMax.IsZero() {
+		terms = append(terms, fmt.Sprintf("created_at:<='%s'", query.CreatedAtMax.Format(time.RFC3339)))
+	}
+	if query.Email != "" {
+		terms = append(terms, fmt.Sprintf("email:'%s'", query.Email))
+	}
+	if query.Tag != "" {
+		terms = append(terms, fmt.Sprintf("tag:'%s'", query.Tag))
+	}
+	if query.Gateway != "" {
+		terms = append(terms, fmt.Sprintf("gateway:'%s'", query.Gateway))
+	}
+	if query.Test != nil {
+		terms = append(terms, fmt.Sprintf("test:%t", *query.Test))
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// toOrder は GraphQL のオーダーを REST と同じ Order に詰め替える。
+// line item が GRAPHQL_LINE_ITEMS_LIMIT を超えるオーダーは、在庫や返金を誤らないようエラーにする。
+func toOrder(graphqlOrder GraphqlOrder) (*Order, error) {
+	id, err := strconv.ParseInt(graphqlOrder.LegacyResourceID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid legacyResourceId '%s'", graphqlOrder.LegacyResourceID)
+	}
+	orderNumber, _ := strconv.Atoi(strings.TrimPrefix(graphqlOrder.Name, "#"))
+
+	if graphqlOrder.LineItems.PageInfo.HasNextPage {
+		return nil, fmt.Errorf("オーダー %s の line item が %d 件を超えるため GraphQL では取得できません。backend = \"rest\" のストアで実行してください", graphqlOrder.Name, GRAPHQL_LINE_ITEMS_LIMIT)
+	}
+	// refunds は件数の上限を超えたかどうかが返らないため、上限と同じ件数の場合は取得しきれていないものとして扱う
+	if len(graphqlOrder.Refunds) >= GRAPHQL_REFUNDS_LIMIT {
+		return nil, fmt.Errorf("オーダー %s の返金が %d 件以上あるため GraphQL では取得できません。backend = \"rest\" のストアで実行してください", graphqlOrder.Name, GRAPHQL_REFUNDS_LIMIT)
+	}
+
+	totalPrice, err := ParseDecimal(graphqlOrder.TotalPriceSet.ShopMoney.Amount)
+	if err != nil {
+		return nil, err
+	}
+	totalDiscounts, err := ParseDecimal(graphqlOrder.TotalDiscountsSet.ShopMoney.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &Order{
+		ID:                  id,
+		AdminGraphqlAPIID:   graphqlOrder.ID,
+		Name:                graphqlOrder.Name,
+		Number:              orderNumber,
+		OrderNumber:         orderNumber,
+		Email:               graphqlOrder.Email,
+		Phone:               graphqlOrder.Phone,
+		Note:                FlexString(graphqlOrder.Note),
+		Test:                graphqlOrder.Test,
+		Tags:                strings.Join(graphqlOrder.Tags, ", "),
+		BrowserIP:           graphqlOrder.ClientIP,
+		CustomerLocale:      FlexString(graphqlOrder.CustomerLocale),
+		Currency:            graphqlOrder.CurrencyCode,
+		FinancialStatus:     strings.ToLower(graphqlOrder.DisplayFinancialStatus),
+		PaymentGatewayNames: graphqlOrder.PaymentGatewayNames,
+		TotalPrice:          totalPrice,
+		TotalDiscounts:      totalDiscounts,
+		ShippingAddress:     toAddress(graphqlOrder.ShippingAddress),
+		BillingAddress:      toAddress(graphqlOrder.BillingAddress),
+	}
+	order.TotalPriceSet.ShopMoney = Money{Amount: totalPrice, CurrencyCode: graphqlOrder.TotalPriceSet.ShopMoney.CurrencyCode}
+	if len(graphqlOrder.PaymentGatewayNames) > 0 {
+		order.Gateway = graphqlOrder.PaymentGatewayNames[0]
+	}
+	for _, value := range []struct {
+		text string
+		time *Time
+	}{
+		{graphqlOrder.CreatedAt, &order.CreatedAt},
+		{graphqlOrder.CancelledAt, &order.CancelledAt},
+		{graphqlOrder.ClosedAt, &order.ClosedAt},
+	} {
+		if value.text == "" {
+			continue
+		}
+		value.time.Time, err = time.Parse(time.RFC3339, value.text)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if graphqlOrder.Customer != nil {
+		customerId, _ := strconv.ParseInt(graphqlOrder.Customer.LegacyResourceID, 10, 64)
+		order.Customer = &Customer{
+			ID:        customerId,
+			Email:     graphqlOrder.Customer.Email,
+			FirstName: graphqlOrder.Customer.FirstName,
+			LastName:  graphqlOrder.Customer.LastName,
+			Phone:     graphqlOrder.Customer.Phone,
+			Tags:      strings.Join(graphqlOrder.Customer.Tags, ", "),
+		}
+	}
+
+	for _, edge := range graphqlOrder.LineItems.Edges {
+		lineItem, err := toLineItem(edge.Node)
+		if err != nil {
+			return nil, err
+		}
+		order.LineItems = append(order.LineItems, *lineItem)
+	}
+
+	for _, graphqlRefund := range graphqlOrder.Refunds {
+		if graphqlRefund.RefundLineItems.PageInfo.HasNextPage {
+			return nil, fmt.Errorf("オーダー %s の返金の line item が %d 件を超えるため GraphQL では取得できません。backend = \"rest\" のストアで実行してください", graphqlOrder.Name, GRAPHQL_LINE_ITEMS_LIMIT)
+		}
+		refundId, _ := strconv.ParseInt(graphqlRefund.LegacyResourceID, 10, 64)
+		refund := Refund{ID: refundId, OrderID: id}
+		if graphqlRefund.CreatedAt != "" {
+			refund.CreatedAt.Time, err = time.Parse(time.RFC3339, graphqlRefund.CreatedAt)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, edge := range graphqlRefund.RefundLineItems.Edges {
+			refundLineItem, err := toCalculatedRefundLineItem(edge.Node)
+			if err != nil {
+				return nil, err
+			}
+			refund.RefundLineItems = append(refund.RefundLineItems, RefundLineItem{
+				LineItemID:  refundLineItem.LineItemID,
+				LocationID:  refundLineItem.LocationID,
+				Quantity:    refundLineItem.Quantity,
+				RestockType: refundLineItem.RestockType,
+			})
+			refund.Restock = refund.Restock || refundLineItem.RestockType != RESTOCK_TYPE_NO_RESTOCK
+		}
+		order.Refunds = append(order.Refunds, refund)
+	}
+
+	// REST の payment_details と同じく、カードで支払った取引の情報を使う
+	for _, transaction := range graphqlOrder.Transactions {
+		if transaction.PaymentDetails == nil || transaction.PaymentDetails.Number == "" {
+			continue
+		}
+		order.PaymentDetails = &PaymentDetails{
+			CreditCardBin:     FlexString(transaction.PaymentDetails.Bin),
+			AvsResultCode:     FlexString(transaction.PaymentDetails.AvsResultCode),
+			CvvResultCode:     FlexString(transaction.PaymentDetails.CvvResultCode),
+			CreditCardNumber:  FlexString(transaction.PaymentDetails.Number),
+			CreditCardCompany: FlexString(transaction.PaymentDetails.Company),
+		}
+		break
+	}
+
+	return order, nil
+}
+
+// toLineItem は GraphQL の line item を REST と同じ LineItem に詰め替える。
+// fulfillable_quantity には未発送の数量を使う。
+func toLineItem(graphqlLineItem GraphqlLineItem) (*LineItem, error) {
+	id, err := parseGid(graphqlLineItem.ID)
+	if err != nil {
+		return nil, err
+	}
+	price, err := ParseDecimal(graphqlLineItem.OriginalUnitPriceSet.ShopMoney.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	lineItem := &LineItem{
+		ID:                  id,
+		AdminGraphqlAPIID:   graphqlLineItem.ID,
+		Name:                graphqlLineItem.Name,
+		Title:               graphqlLineItem.Title,
+		VariantTitle:        graphqlLineItem.VariantTitle,
+		Sku:                 graphqlLineItem.Sku,
+		Quantity:            graphqlLineItem.Quantity,
+		FulfillableQuantity: graphqlLineItem.UnfulfilledQuantity,
+		RequiresShipping:    graphqlLineItem.RequiresShipping,
+		Price:               price,
+	}
+	if graphqlLineItem.Variant != nil {
+		lineItem.VariantID, _ = strconv.ParseInt(graphqlLineItem.Variant.LegacyResourceID, 10, 64)
+		if graphqlLineItem.Variant.InventoryItem.Tracked {
+			lineItem.VariantInventoryManagement = INVENTORY_MANAGEMENT_SHOPIFY
+		}
+	}
+	if graphqlLineItem.Product != nil {
+		lineItem.ProductID, _ = strconv.ParseInt(graphqlLineItem.Product.LegacyResourceID, 10, 64)
+	}
+	return lineItem, nil
+}
+
+func toAddress(graphqlAddress *GraphqlAddress) *Address {
+	if graphqlAddress == nil {
+		return nil
+	}
+	return &Address{
+		FirstName:    graphqlAddress.FirstName,
+		LastName:     graphqlAddress.LastName,
+		Name:         graphqlAddress.Name,
+		Company:      FlexString(graphqlAddress.Company),
+		Address1:     graphqlAddress.Address1,
+		Address2:     graphqlAddress.Address2,
+		City:         graphqlAddress.City,
+		Province:     graphqlAddress.Province,
+		ProvinceCode: graphqlAddress.ProvinceCode,
+		Country:      graphqlAddress.Country,
+		CountryCode:  graphqlAddress.CountryCodeV2,
+		Zip:          graphqlAddress.Zip,
+		Phone:        graphqlAddress.Phone,
+	}
+}
+
+// toGraphqlRefundLineItemInputs は返金する line item を RefundLineItemInput に変換する。
+func toGraphqlRefundLineItemInputs(refundLineItems []RefundLineItemRequest) []map[string]interface{} {
+	var inputs []map[string]interface{}
+	for _, refundLineItem := range refundLineItems {
+		input := map[string]interface{}{
+			"lineItemId":  fmt.Sprintf(GID_LINE_ITEM, refundLineItem.LineItemID),
+			"quantity":    refundLineItem.Quantity,
+			"restockType": strings.ToUpper(refundLineItem.RestockType),
+		}
+		if refundLineItem.LocationID != 0 {
+			input["locationId"] = fmt.Sprintf(GID_LOCATION, refundLineItem.LocationID)
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+func toCalculatedRefundLineItem(graphqlRefundLineItem GraphqlRefundLineItem) (*CalculatedRefundLineItem, error) {
+	lineItemId, err := parseGid(graphqlRefundLineItem.LineItem.ID)
+	if err != nil {
+		return nil, err
+	}
+	lineItem := &CalculatedRefundLineItem{
+		Quantity:    graphqlRefundLineItem.Quantity,
+		LineItemID:  lineItemId,
+		RestockType: strings.ToLower(graphqlRefundLineItem.RestockType),
+	}
+	if graphqlRefundLineItem.Location != nil {
+		lineItem.LocationID, _ = strconv.ParseInt(graphqlRefundLineItem.Location.LegacyResourceID, 10, 64)
+	}
+	return lineItem, nil
+}
+
+// toRefund は refundCreate の結果を REST と同じ Refund に詰め替える。
+func toRefund(order *Order, data *GraphqlRefundCreateData) (*Refund, error) {
+	graphqlRefund := data.RefundCreate.Refund
+	id, err := strconv.ParseInt(graphqlRefund.LegacyResourceID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid legacyResourceId '%s'", graphqlRefund.LegacyResourceID)
+	}
+
+	refund := &Refund{ID: id, OrderID: order.ID}
+	for _, edge := range graphqlRefund.RefundLineItems.Edges {
+		lineItem, err := toCalculatedRefundLineItem(edge.Node)
+		if err != nil {
+			return nil, err
+		}
+		refund.RefundLineItems = append(refund.RefundLineItems, RefundLineItem{
+			LineItemID:  lineItem.LineItemID,
+			Quantity:    lineItem.Quantity,
+			RestockType: lineItem.RestockType,
+		})
+		refund.Restock = refund.Restock || lineItem.RestockType != RESTOCK_TYPE_NO_RESTOCK
+	}
+	for _, edge := range graphqlRefund.Transactions.Edges {
+		transactionId, err := parseGid(edge.Node.ID)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := ParseDecimal(edge.Node.AmountSet.ShopMoney.Amount)
+		if err != nil {
+			return nil, err
+		}
+		refund.Transactions = append(refund.Transactions, Transaction{
+			ID:       transactionId,
+			OrderID:  order.ID,
+			Kind:     strings.ToLower(edge.Node.Kind),
+			Status:   strings.ToLower(edge.Node.Status),
+			Gateway:  edge.Node.Gateway,
+			Amount:   amount,
+			Currency: edge.Node.AmountSet.ShopMoney.CurrencyCode,
+		})
+	}
+	return refund, nil
+}
+
+// toVariant は GraphQL の商品バリアントを REST と同じ Variant に詰め替える。
+func toVariant(graphqlVariant GraphqlVariant) (*Variant, error) {
+	id, err := strconv.ParseInt(graphqlVariant.LegacyResourceID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid legacyResourceId '%s'", graphqlVariant.LegacyResourceID)
+	}
+	price, err := ParseDecimal(graphqlVariant.Price)
+	if err != nil {
+		return nil, err
+	}
+
+	variant := &Variant{ID: id, Title: graphqlVariant.Title, Sku: graphqlVariant.Sku, Price: price}
+	variant.ProductID, _ = strconv.ParseInt(graphqlVariant.Product.LegacyResourceID, 10, 64)
+	variant.InventoryItemID, _ = strconv.ParseInt(graphqlVariant.InventoryItem.LegacyResourceID, 10, 64)
+	if graphqlVariant.InventoryItem.Tracked {
+		variant.InventoryManagement = INVENTORY_MANAGEMENT_SHOPIFY
+	}
+	return variant, nil
+}
+
+// toDraftOrder は GraphQL の下書き注文を REST と同じ DraftOrder に詰め替える。
+func toDraftOrder(graphqlDraftOrder GraphqlDraftOrder) (*DraftOrder, error) {
+	id, err := strconv.ParseInt(graphqlDraftOrder.LegacyResourceID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid legacyResourceId '%s'", graphqlDraftOrder.LegacyResourceID)
+	}
+
+	draftOrder := &DraftOrder{
+		ID:         id,
+		Name:       graphqlDraftOrder.Name,
+		Status:     strings.ToLower(graphqlDraftOrder.Status),
+		InvoiceURL: graphqlDraftOrder.InvoiceURL,
+		Tags:       strings.Join(graphqlDraftOrder.Tags, ", "),
+	}
+	if graphqlDraftOrder.Order != nil {
+		draftOrder.OrderID, _ = strconv.ParseInt(graphqlDraftOrder.Order.LegacyResourceID, 10, 64)
+	}
+	return draftOrder, nil
+}
+
+// toGraphqlDraftOrderInput は DraftOrder を draftOrderCreate の DraftOrderInput に変換する。
+func toGraphqlDraftOrderInput(draftOrder *DraftOrder) (map[string]interface{}, error) {
+	var lineItems []map[string]interface{}
+	for _, lineItem := range draftOrder.LineItems {
+		item := map[string]interface{}{"quantity": lineItem.Quantity}
+		if lineItem.VariantID != 0 {
+			item["variantId"] = fmt.Sprintf(GID_PRODUCT_VARIANT, lineItem.VariantID)
+		} else {
+			item["title"] = lineItem.Title
+			item["originalUnitPrice"] = lineItem.Price
+		}
+		if lineItem.AppliedDiscount != nil {
+			discount, err := toGraphqlDiscountInput(lineItem.AppliedDiscount)
+			if err != nil {
+				return nil, err
+			}
+			item["appliedDiscount"] = discount
+		}
+		lineItems = append(lineItems, item)
+	}
+
+	input := map[string]interface{}{"lineItems": lineItems}
+	if draftOrder.Email != "" {
+		input["email"] = draftOrder.Email
+	}
+	if draftOrder.Customer != nil {
+		input["customerId"] = fmt.Sprintf("gid://shopify/Customer/%d", draftOrder.Customer.ID)
+	}
+	if draftOrder.Note != "" {
+		input["note"] = draftOrder.Note
+	}
+	if draftOrder.Tags != "" {
+		input["tags"] = strings.Split(draftOrder.Tags, ", ")
+	}
+	if draftOrder.ShippingLine != nil {
+		input["shippingLine"] = map[string]interface{}{"title": draftOrder.ShippingLine.Title, "price": draftOrder.ShippingLine.Price}
+	}
+	if draftOrder.AppliedDiscount != nil {
+		discount, err := toGraphqlDiscountInput(draftOrder.AppliedDiscount)
+		if err != nil {
+			return nil, err
+		}
+		input["appliedDiscount"] = discount
+	}
+	return input, nil
+}
+
+func toGraphqlDiscountInput(discount *DraftOrderDiscount) (map[string]interface{}, error) {
+	value, err := ParseDecimal(discount.Value)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"title":       discount.Title,
+		"description": discount.Description,
+		"value":       value.Float64(),
+		"valueType":   strings.ToUpper(discount.ValueType),
+	}, nil
+}
+
+// parseGid は "gid://shopify/OrderTransaction/123" の末尾の数値 ID を返す。
+func parseGid(gid string) (int64, error) {
+	id, err := strconv.ParseInt(gid[strings.LastIndex(gid, "/")+1:], 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("invalid gid '%s'", gid)
+	}
+	return id, nil
+}
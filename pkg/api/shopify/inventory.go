@@ -0,0 +1,328 @@
+package shopify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/infrastructure/http"
+	"shopify-manager/pkg/output"
+)
+
+// Shopify が在庫を管理している商品の variant_inventory_management
+const INVENTORY_MANAGEMENT_SHOPIFY = "shopify"
+
+// inventory_levels.json に一度に指定する inventory_item_ids の数
+const INVENTORY_ITEM_IDS_PER_REQUEST = 50
+
+type Variant struct {
+	ID                  int64      `json:"id"`
+	ProductID           int64      `json:"product_id"`
+	Title               string     `json:"title"`
+	Sku                 string     `json:"sku"`
+	Price               Decimal    `json:"price"`
+	InventoryItemID     int64      `json:"inventory_item_id"`
+	InventoryManagement FlexString `json:"inventory_management"`
+}
+
+type GetVariantResponse struct {
+	Variant Variant `json:"variant"`
+}
+
+type InventoryLevel struct {
+	InventoryItemID int64 `json:"inventory_item_id"`
+	LocationID      int64 `json:"location_id"`
+	Available       int   `json:"available"`
+}
+
+type GetInventoryLevelsResponse struct {
+	InventoryLevels []InventoryLevel `json:"inventory_levels"`
+}
+
+type AdjustInventoryLevelRequest struct {
+	LocationID          int64 `json:"location_id"`
+	InventoryItemID     int64 `json:"inventory_item_id"`
+	AvailableAdjustment int   `json:"available_adjustment"`
+}
+
+// RestockResult は JSON 出力モードで書き出す1商品 (バリアント) の在庫戻りの確認結果。
+// Expected はキャンセルしたオーダーの未発送数量の合計、Restocked はそのうちキャンセルで在庫に戻った数量の合計。
+// Restocked はオーダー毎にキャンセル前後の返金の restock を比べて求めるため、処理中に他のオーダーで売れた分は含まない。
+// Missing は戻っていない数量、Adjusted は adjust で加えた数量。
+type RestockResult struct {
+	Type            string `json:"type"`
+	Store           string `json:"store"`
+	VariantID       int64  `json:"variant_id"`
+	InventoryItemID int64  `json:"inventory_item_id,omitempty"`
+	Sku             string `json:"sku,omitempty"`
+	Title           string `json:"title"`
+	Expected        int    `json:"expected"`
+	Restocked       int    `json:"restocked"`
+	Missing         int    `json:"missing"`
+	Adjusted        int    `json:"adjusted,omitempty"`
+	Status          string `json:"status"`
+	Error           string `json:"error,omitempty"`
+}
+
+// GraphQL のキャンセルは非同期のため、キャンセル後のオーダーを取得してキャンセル済みになっていない場合はこの間隔で取得し直す
+const RESTOCK_CHECK_RETRY = 3
+const RESTOCK_CHECK_INTERVAL = 2 * time.Second
+
+// restockCheck はキャンセルしたオーダー毎に、未発送数量のうちキャンセルで在庫に戻った数量を確認する。
+// ストアの restock が off の場合は nil で、全てのメソッドは何もしない。
+type restockCheck struct {
+	store    *config.Store
+	client   Client
+	mutex    sync.Mutex
+	variants map[int64]*RestockResult
+	orders   []*Order
+}
+
+// newRestockCheck は orders の在庫管理されている商品を確認の対象にする。対象が無い場合は nil を返す。
+func newRestockCheck(store *config.Store, client Client, orders []*Order) *restockCheck {
+	if store.Restock == "" || store.Restock == config.RESTOCK_MODE_OFF {
+		return nil
+	}
+
+	c := &restockCheck{store: store, client: client, variants: map[int64]*RestockResult{}}
+	for _, order := range orders {
+		for _, lineItem := range order.LineItems {
+			if lineItem.VariantID == 0 || lineItem.VariantInventoryManagement.String() != INVENTORY_MANAGEMENT_SHOPIFY {
+				continue
+			}
+			if _, ok := c.variants[lineItem.VariantID]; ok {
+				continue
+			}
+			c.variants[lineItem.VariantID] = &RestockResult{
+				Type:      output.TYPE_RESTOCK,
+				Store:     store.Name,
+				VariantID: lineItem.VariantID,
+				Sku:       lineItem.Sku,
+				Title:     lineItem.Name,
+			}
+		}
+	}
+	if len(c.variants) == 0 {
+		return nil
+	}
+	return c
+}
+
+// cancelled はキャンセルに成功したオーダーを確認の対象に加える。order はキャンセル前に取得したオーダー。
+func (c *restockCheck) cancelled(order *Order) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.orders = append(c.orders, order)
+}
+
+// finish はキャンセルしたオーダーを取得し直して戻った数量を確認し、商品毎の結果を書き出す。
+// restock が adjust の場合は不足分を在庫に加える。戻っていない商品があれば false を返す。
+func (c *restockCheck) finish() bool {
+	if c == nil {
+		return true
+	}
+
+	for _, order := range c.orders {
+		cancelled, err := c.getCancelledOrder(order)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' のキャンセル後のオーダーを取得できないため、在庫戻りを確認できません。%s\n", order.OrderNumber, err.Error())
+		}
+		for _, lineItem := range order.LineItems {
+			result, ok := c.variants[lineItem.VariantID]
+			if !ok || lineItem.FulfillableQuantity == 0 {
+				continue
+			}
+			result.Expected += lineItem.FulfillableQuantity
+			if err != nil {
+				if result.Error == "" {
+					result.Error = fmt.Sprintf("orderNumber '%d' get order : %s", order.OrderNumber, err.Error())
+				}
+				continue
+			}
+			result.Restocked += restockedQuantity(cancelled, lineItem.ID) - restockedQuantity(order, lineItem.ID)
+		}
+	}
+
+	isRestocked := true
+	for _, variantId := range c.variantIds() {
+		result := c.variants[variantId]
+		if result.Expected == 0 {
+			continue
+		}
+		// 取得できなかったオーダーがある商品は戻った数量が分からないため、調整せずに失敗にする
+		if result.Error != "" {
+			result.Status = output.STATUS_FAILED
+			isRestocked = false
+			output.Write(result)
+			continue
+		}
+
+		result.Missing = result.Expected - result.Restocked
+		if result.Missing <= 0 {
+			result.Missing = 0
+			result.Status = output.STATUS_SUCCEEDED
+			output.Write(result)
+			continue
+		}
+
+		log.Printf("WARN : variantId '%d' (%s) の在庫が %d 戻っていません。キャンセル数 %d、戻った数 %d\n", variantId, result.Title, result.Missing, result.Expected, result.Restocked)
+		if c.store.Restock == config.RESTOCK_MODE_ADJUST {
+			err := c.adjust(result)
+			if err == nil {
+				result.Adjusted = result.Missing
+				result.Status = output.STATUS_SUCCEEDED
+				output.Write(result)
+				continue
+			}
+			log.Printf("ERROR : variantId '%d' の在庫の調整に失敗しました。%s\n", variantId, err.Error())
+			result.Error = err.Error()
+		} else {
+			result.Error = "restock missing"
+		}
+		result.Status = output.STATUS_FAILED
+		isRestocked = false
+		output.Write(result)
+	}
+	return isRestocked
+}
+
+// getCancelledOrder はキャンセル後のオーダーを取得する。キャンセル済みになるまで RESTOCK_CHECK_RETRY 回まで取得し直す。
+func (c *restockCheck) getCancelledOrder(order *Order) (*Order, error) {
+	for retry := 0; ; retry++ {
+		cancelled, err := c.client.GetOrder(order.OrderNumber)
+		if err != nil {
+			return nil, err
+		}
+		if !cancelled.CancelledAt.IsZero() {
+			return cancelled, nil
+		}
+		if retry >= RESTOCK_CHECK_RETRY {
+			return nil, fmt.Errorf("キャンセルが完了していません")
+		}
+		time.Sleep(RESTOCK_CHECK_INTERVAL)
+	}
+}
+
+// adjust は不足分を restockLocationId、未指定なら在庫のある唯一のロケーションに加える。
+func (c *restockCheck) adjust(result *RestockResult) error {
+	variant, err := c.client.GetVariant(result.VariantID)
+	if err != nil {
+		return fmt.Errorf("get variant : %s", err.Error())
+	}
+	result.InventoryItemID = variant.InventoryItemID
+
+	locationId := c.store.RestockLocationId
+	if locationId == 0 {
+		levels, err := getInventoryLevels([]int64{variant.InventoryItemID}, c.store)
+		if err != nil {
+			return fmt.Errorf("get inventory levels : %s", err.Error())
+		}
+		if len(levels[variant.InventoryItemID]) != 1 {
+			return fmt.Errorf("在庫のロケーションが %d 件あるため、ストアの restockLocationId を指定してください", len(levels[variant.InventoryItemID]))
+		}
+		locationId = levels[variant.InventoryItemID][0].LocationID
+	}
+
+	log.Printf("INFO : Try to adjust inventory of variantId '%d' by %d at locationId '%d'\n", result.VariantID, result.Missing, locationId)
+	return adjustInventoryLevel(locationId, result.InventoryItemID, result.Missing, c.store)
+}
+
+func (c *restockCheck) variantIds() []int64 {
+	var ids []int64
+	for id := range c.variants {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// restockedQuantity は order の返金で在庫に戻された lineItemId の数量の合計を返す。
+func restockedQuantity(order *Order, lineItemId int64) int {
+	quantity := 0
+	for _, refund := range order.Refunds {
+		for _, refundLineItem := range refund.RefundLineItems {
+			if refundLineItem.LineItemID == lineItemId && refundLineItem.RestockType != RESTOCK_TYPE_NO_RESTOCK {
+				quantity += refundLineItem.Quantity
+			}
+		}
+	}
+	return quantity
+}
+
+func getVariant(variantId int64, store *config.Store) (*Variant, error) {
+
+	getVariantUrl := restUrl(store, constants.VARIANT_URL_TEMPLATE, store.Domain, store.ApiVersion, variantId)
+	httpReqHeader := restHeader(store)
+	jsonRes, err := http.Get(getVariantUrl, httpReqHeader, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	getVariantRes := new(GetVariantResponse)
+	err = json.Unmarshal(jsonRes, &getVariantRes)
+	if err != nil {
+		log.Println("Get variant response json unmarshal err")
+		return nil, err
+	}
+
+	return &getVariantRes.Variant, nil
+}
+
+// getInventoryLevels は在庫アイテム毎の全ロケーションの在庫数を返す。
+func getInventoryLevels(inventoryItemIds []int64, store *config.Store) (map[int64][]InventoryLevel, error) {
+	levels := map[int64][]InventoryLevel{}
+	getInventoryLevelsUrl := restUrl(store, constants.INVENTORY_LEVELS_URL_TEMPLATE, store.Domain, store.ApiVersion)
+	httpReqHeader := restHeader(store)
+
+	for start := 0; start < len(inventoryItemIds); start += INVENTORY_ITEM_IDS_PER_REQUEST {
+		end := start + INVENTORY_ITEM_IDS_PER_REQUEST
+		if end > len(inventoryItemIds) {
+			end = len(inventoryItemIds)
+		}
+		var ids []string
+		for _, id := range inventoryItemIds[start:end] {
+			ids = append(ids, strconv.FormatInt(id, 10))
+		}
+
+		queryParam := map[string]string{"inventory_item_ids": strings.Join(ids, ","), "limit": "250"}
+		jsonRes, err := http.Get(getInventoryLevelsUrl, httpReqHeader, queryParam)
+		if err != nil {
+			return nil, err
+		}
+
+		getInventoryLevelsRes := new(GetInventoryLevelsResponse)
+		err = json.Unmarshal(jsonRes, &getInventoryLevelsRes)
+		if err != nil {
+			log.Println("Get inventory levels response json unmarshal err")
+			return nil, err
+		}
+		for _, level := range getInventoryLevelsRes.InventoryLevels {
+			levels[level.InventoryItemID] = append(levels[level.InventoryItemID], level)
+		}
+	}
+	return levels, nil
+}
+
+func adjustInventoryLevel(locationId, inventoryItemId int64, adjustment int, store *config.Store) error {
+	adjustReq := AdjustInventoryLevelRequest{LocationID: locationId, InventoryItemID: inventoryItemId, AvailableAdjustment: adjustment}
+	reqJsonBytes, err := json.MarshalIndent(adjustReq, "", "  ")
+	if err != nil {
+		log.Println("Adjust inventory level request json marshal error")
+		return err
+	}
+
+	adjustUrl := restUrl(store, constants.ADJUST_INVENTORY_LEVEL_URL_TEMPLATE, store.Domain, store.ApiVersion)
+	httpReqHeader := restHeader(store)
+	_, err = postWithAudit(adjustUrl, reqJsonBytes, httpReqHeader, store, AUDIT_ACTION_ADJUST_INVENTORY, nil, 0)
+	return err
+}
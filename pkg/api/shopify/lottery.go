@@ -0,0 +1,120 @@
+package shopify
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/lottery"
+)
+
+// LotteryDraw は1ストアの抽選結果。Results は抽選順で、Orders はオーダー番号から引く応募のオーダー。
+type LotteryDraw struct {
+	Seed    int64
+	Results []lottery.Result
+	Orders  map[int]*Order
+}
+
+// DrawLottery は [Lottery] の条件に一致するオーソリ済みのオーダーを取得して抽選する。
+// 商品・配送先・顧客タグは REST のレスポンスにのみ含まれるため、backend に関わらず REST で取得する。
+func DrawLottery(setting *config.Lottery, store *config.Store) (*LotteryDraw, error) {
+	if setting.Winners < 1 {
+		return nil, fmt.Errorf("[Lottery] winners に当選数を指定してください")
+	}
+	if setting.Tag == "" && setting.ProductId == 0 && setting.Sku == "" {
+		return nil, fmt.Errorf("[Lottery] tag / productId / sku のいずれかで抽選の対象を指定してください")
+	}
+
+	query := &OrderQuery{Status: "open", FinancialStatus: "authorized", Tag: setting.Tag}
+	log.Printf("INFO : Search lottery orders in store '%s' (tag '%s' productId '%d' sku '%s')\n", store.Name, setting.Tag, setting.ProductId, setting.Sku)
+	orders, err := searchOrders(query, store)
+	if err != nil {
+		return nil, err
+	}
+
+	draw := &LotteryDraw{Seed: setting.Seed, Orders: map[int]*Order{}}
+	var entries []lottery.Entry
+	for i := range orders {
+		order := &orders[i]
+		if !hasLotteryProduct(order, setting) {
+			continue
+		}
+		draw.Orders[order.OrderNumber] = order
+		entries = append(entries, lottery.Entry{
+			OrderNumber: order.OrderNumber,
+			OrderID:     order.ID,
+			Key:         lotteryKey(order, setting.Unique),
+			Excluded:    lotteryExcluded(order, setting),
+		})
+	}
+	log.Printf("INFO : %d of %d authorized orders entered the lottery\n", len(entries), len(orders))
+
+	if draw.Seed == 0 {
+		draw.Seed = time.Now().UnixNano()
+		log.Printf("INFO : seed %d で抽選します。同じ結果を再現する場合は [Lottery] seed に指定してください\n", draw.Seed)
+	}
+	draw.Results = lottery.Draw(entries, setting.Winners, draw.Seed)
+	return draw, nil
+}
+
+func hasLotteryProduct(order *Order, setting *config.Lottery) bool {
+	if setting.ProductId == 0 && setting.Sku == "" {
+		return true
+	}
+	for _, lineItem := range order.LineItems {
+		if setting.ProductId != 0 && lineItem.ProductID != setting.ProductId {
+			continue
+		}
+		if setting.Sku != "" && lineItem.Sku != setting.Sku {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// lotteryKey は [Lottery] unique に応じて、1件までしか当選させない単位のキーを返す。
+// 配送先の無いオーダーは顧客で、顧客もメールアドレスも無いゲストのオーダーは配送先で判定し、
+// どちらも無い場合は空を返して重複を判定しない。
+func lotteryKey(order *Order, unique string) string {
+	if unique == config.LOTTERY_UNIQUE_NONE {
+		return ""
+	}
+
+	addressKey := order.ShippingAddress.NormalizedKey()
+	if addressKey != "" {
+		addressKey = "address:" + addressKey
+	}
+	customer := customerKey(order)
+	if customer != "" {
+		customer = "customer:" + customer
+	}
+
+	if unique == config.LOTTERY_UNIQUE_ADDRESS && addressKey != "" {
+		return addressKey
+	}
+	if customer != "" {
+		return customer
+	}
+	return addressKey
+}
+
+// lotteryExcluded は除外する顧客・オーダーの場合にその理由を返す。
+func lotteryExcluded(order *Order, setting *config.Lottery) string {
+	for _, email := range setting.ExcludeEmails {
+		if order.Email != "" && strings.EqualFold(order.Email, email) {
+			return fmt.Sprintf("除外 (メールアドレス %s)", email)
+		}
+	}
+	for _, tag := range setting.ExcludeTags {
+		if order.Customer != nil && order.Customer.HasTag(tag) {
+			return fmt.Sprintf("除外 (顧客タグ %s)", tag)
+		}
+		if order.HasTag(tag) {
+			return fmt.Sprintf("除外 (オーダータグ %s)", tag)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,31 @@
+package shopify
+
+import (
+	"testing"
+
+	"shopify-manager/pkg/config"
+)
+
+func TestLotteryKey(t *testing.T) {
+	address := &Address{CountryCode: "JP", Zip: "100-0001", Province: "東京都", City: "千代田区", Address1: "千代田1-1"}
+	tests := []struct {
+		name   string
+		order  Order
+		unique string
+		want   string
+	}{
+		{name: "customer id", order: Order{Email: "a@example.com", Customer: &Customer{ID: 10}}, unique: config.LOTTERY_UNIQUE_CUSTOMER, want: "customer:10"},
+		{name: "guest email", order: Order{Email: "A@Example.com"}, unique: config.LOTTERY_UNIQUE_CUSTOMER, want: "customer:a@example.com"},
+		{name: "guest without email uses address", order: Order{ShippingAddress: address}, unique: config.LOTTERY_UNIQUE_CUSTOMER, want: "address:" + address.NormalizedKey()},
+		{name: "guest without email and address", order: Order{}, unique: config.LOTTERY_UNIQUE_CUSTOMER, want: ""},
+		{name: "address", order: Order{Email: "a@example.com", ShippingAddress: address}, unique: config.LOTTERY_UNIQUE_ADDRESS, want: "address:" + address.NormalizedKey()},
+		{name: "address falls back to customer", order: Order{Email: "a@example.com"}, unique: config.LOTTERY_UNIQUE_ADDRESS, want: "customer:a@example.com"},
+		{name: "address without address and email", order: Order{}, unique: config.LOTTERY_UNIQUE_ADDRESS, want: ""},
+		{name: "none", order: Order{Email: "a@example.com", ShippingAddress: address}, unique: config.LOTTERY_UNIQUE_NONE, want: ""},
+	}
+	for _, tt := range tests {
+		if got := lotteryKey(&tt.order, tt.unique); got != tt.want {
+			t.Errorf("%s : lotteryKey = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
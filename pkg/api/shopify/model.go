@@ -0,0 +1,497 @@
+package shopify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DECIMAL_PLACES は Decimal が保持する小数点以下の桁数。
+const DECIMAL_PLACES = 4
+
+const decimalScale = 10000
+
+// Decimal は金額を float を使わずに扱うための固定小数点数。
+// JSON の文字列 "1234.50"、数値、null のいずれからもデコードできる。
+type Decimal struct {
+	units int64
+}
+
+func ParseDecimal(value string) (Decimal, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Decimal{}, nil
+	}
+
+	negative := false
+	if value[0] == '-' || value[0] == '+' {
+		negative = value[0] == '-'
+		value = value[1:]
+	}
+
+	intPart, fracPart := value, ""
+	if dot := strings.IndexByte(value, '.'); dot >= 0 {
+		intPart, fracPart = value[:dot], value[dot+1:]
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+	if len(fracPart) > DECIMAL_PLACES {
+		if strings.Trim(fracPart[DECIMAL_PLACES:], "0") != "" {
+			return Decimal{}, fmt.Errorf("金額 '%s' の小数点以下が%d桁を超えています", value, DECIMAL_PLACES)
+		}
+		fracPart = fracPart[:DECIMAL_PLACES]
+	}
+	fracPart += strings.Repeat("0", DECIMAL_PLACES-len(fracPart))
+
+	units, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil || strings.ContainsAny(intPart+fracPart, "+-") {
+		return Decimal{}, fmt.Errorf("金額 '%s' を解析できません", value)
+	}
+	if negative {
+		units = -units
+	}
+	return Decimal{units: units}, nil
+}
+
+// DecimalFromInt は整数の金額から Decimal を作る。
+func DecimalFromInt(value int64) Decimal {
+	return Decimal{units: value * decimalScale}
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{units: d.units + other.units}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{units: d.units - other.units}
+}
+
+func (d Decimal) Mul(quantity int) Decimal {
+	return Decimal{units: d.units * int64(quantity)}
+}
+
+func (d Decimal) Cmp(other Decimal) int {
+	switch {
+	case d.units < other.units:
+		return -1
+	case d.units > other.units:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+func (d Decimal) IsPositive() bool {
+	return d.units > 0
+}
+
+// Float64 はルール判定など比較のみに使う近似値を返す。
+func (d Decimal) Float64() float64 {
+	return float64(d.units) / decimalScale
+}
+
+// String は小数点以下2桁以上で金額を返す。 (例: "1000.00", "12.345")
+func (d Decimal) String() string {
+	units := d.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	frac := fmt.Sprintf("%0*d", DECIMAL_PLACES, units%decimalScale)
+	for len(frac) > 2 && frac[len(frac)-1] == '0' {
+		frac = frac[:len(frac)-1]
+	}
+	return fmt.Sprintf("%s%d.%s", sign, units/decimalScale, frac)
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	value, err := unquoteScalar(data)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseDecimal(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Time は null や空文字を許容する時刻。
+type Time struct {
+	time.Time
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Time.Format(time.RFC3339))
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	value, err := unquoteScalar(data)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// FlexString は文字列・数値・真偽値・null のいずれでも受け付ける文字列。
+// Shopify 側で型が変わりやすい項目に使う。
+type FlexString string
+
+func (s *FlexString) UnmarshalJSON(data []byte) error {
+	value, err := unquoteScalar(data)
+	if err != nil {
+		// オブジェクトや配列はそのままの JSON 文字列として保持する
+		*s = FlexString(bytes.TrimSpace(data))
+		return nil
+	}
+	*s = FlexString(value)
+	return nil
+}
+
+func (s FlexString) String() string {
+	return string(s)
+}
+
+// unquoteScalar は JSON のスカラー値を文字列で返す。null は空文字になる。
+func unquoteScalar(data []byte) (string, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return "", nil
+	}
+	if data[0] == '"' {
+		var value string
+		err := json.Unmarshal(data, &value)
+		return value, err
+	}
+	if data[0] == '{' || data[0] == '[' {
+		return "", fmt.Errorf("scalar value expected but got %s", string(data))
+	}
+	return string(data), nil
+}
+
+type Money struct {
+	Amount       Decimal `json:"amount"`
+	CurrencyCode string  `json:"currency_code"`
+}
+
+type MoneySet struct {
+	ShopMoney        Money `json:"shop_money"`
+	PresentmentMoney Money `json:"presentment_money"`
+}
+
+type Address struct {
+	FirstName    string     `json:"first_name"`
+	LastName     string     `json:"last_name"`
+	Name         string     `json:"name"`
+	Company      FlexString `json:"company"`
+	Address1     string     `json:"address1"`
+	Address2     string     `json:"address2"`
+	City         string     `json:"city"`
+	Province     string     `json:"province"`
+	ProvinceCode string     `json:"province_code"`
+	Country      string     `json:"country"`
+	CountryCode  string     `json:"country_code"`
+	Zip          string     `json:"zip"`
+	Phone        string     `json:"phone"`
+}
+
+// NormalizedKey は同じ配送先かを比べるための住所のキー。
+// 全角英数字・記号を半角に、英字を小文字にし、空白とハイフンを除いて国・郵便番号・都道府県・市区町村・住所をつなげる。
+func (a *Address) NormalizedKey() string {
+	if a == nil {
+		return ""
+	}
+	var parts []string
+	for _, part := range []string{a.CountryCode, a.Zip, a.Province, a.City, a.Address1, a.Address2} {
+		parts = append(parts, normalizeAddressPart(part))
+	}
+	key := strings.Join(parts, "|")
+	if strings.Trim(key, "|") == "" {
+		return ""
+	}
+	return key
+}
+
+func normalizeAddressPart(value string) string {
+	var builder strings.Builder
+	for _, r := range value {
+		if r >= '！' && r <= '～' {
+			r -= '！' - '!'
+		}
+		switch r {
+		case ' ', '\u3000', '\t', '-', '‐', '−', '－', '―':
+			continue
+		}
+		builder.WriteRune(unicode.ToLower(r))
+	}
+	return builder.String()
+}
+
+type Customer struct {
+	ID                int64      `json:"id"`
+	Email             string     `json:"email"`
+	FirstName         string     `json:"first_name"`
+	LastName          string     `json:"last_name"`
+	Phone             string     `json:"phone"`
+	State             string     `json:"state"`
+	Tags              string     `json:"tags"`
+	Note              FlexString `json:"note"`
+	OrdersCount       int        `json:"orders_count"`
+	TotalSpent        Decimal    `json:"total_spent"`
+	VerifiedEmail     bool       `json:"verified_email"`
+	CreatedAt         Time       `json:"created_at"`
+	AdminGraphqlAPIID string     `json:"admin_graphql_api_id"`
+	DefaultAddress    *Address   `json:"default_address"`
+}
+
+type ClientDetails struct {
+	BrowserIP      string     `json:"browser_ip"`
+	AcceptLanguage FlexString `json:"accept_language"`
+	UserAgent      FlexString `json:"user_agent"`
+}
+
+type PaymentDetails struct {
+	CreditCardBin     FlexString `json:"credit_card_bin"`
+	AvsResultCode     FlexString `json:"avs_result_code"`
+	CvvResultCode     FlexString `json:"cvv_result_code"`
+	CreditCardNumber  FlexString `json:"credit_card_number"`
+	CreditCardCompany FlexString `json:"credit_card_company"`
+}
+
+type TaxLine struct {
+	Title    string   `json:"title"`
+	Price    Decimal  `json:"price"`
+	Rate     float64  `json:"rate"`
+	PriceSet MoneySet `json:"price_set"`
+}
+
+type DiscountCode struct {
+	Code   string  `json:"code"`
+	Amount Decimal `json:"amount"`
+	Type   string  `json:"type"`
+}
+
+type NoteAttribute struct {
+	Name  string     `json:"name"`
+	Value FlexString `json:"value"`
+}
+
+type LineItem struct {
+	ID                         int64      `json:"id"`
+	VariantID                  int64      `json:"variant_id"`
+	ProductID                  int64      `json:"product_id"`
+	Title                      string     `json:"title"`
+	VariantTitle               string     `json:"variant_title"`
+	Name                       string     `json:"name"`
+	Sku                        string     `json:"sku"`
+	Vendor                     FlexString `json:"vendor"`
+	Quantity                   int        `json:"quantity"`
+	FulfillableQuantity        int        `json:"fulfillable_quantity"`
+	FulfillmentStatus          FlexString `json:"fulfillment_status"`
+	VariantInventoryManagement FlexString `json:"variant_inventory_management"`
+	RequiresShipping           bool       `json:"requires_shipping"`
+	Taxable                    bool       `json:"taxable"`
+	GiftCard                   bool       `json:"gift_card"`
+	Grams                      int        `json:"grams"`
+	Price                      Decimal    `json:"price"`
+	PriceSet                   MoneySet   `json:"price_set"`
+	TotalDiscount              Decimal    `json:"total_discount"`
+	TaxLines                   []TaxLine  `json:"tax_lines"`
+	AdminGraphqlAPIID          string     `json:"admin_graphql_api_id"`
+}
+
+type ShippingLine struct {
+	ID              int64     `json:"id"`
+	Title           string    `json:"title"`
+	Code            string    `json:"code"`
+	Source          string    `json:"source"`
+	Price           Decimal   `json:"price"`
+	DiscountedPrice Decimal   `json:"discounted_price"`
+	TaxLines        []TaxLine `json:"tax_lines"`
+}
+
+type Fulfillment struct {
+	ID              int64      `json:"id"`
+	OrderID         int64      `json:"order_id"`
+	Status          string     `json:"status"`
+	LocationID      int64      `json:"location_id"`
+	TrackingCompany FlexString `json:"tracking_company"`
+	TrackingNumber  FlexString `json:"tracking_number"`
+	CreatedAt       Time       `json:"created_at"`
+	LineItems       []LineItem `json:"line_items"`
+}
+
+type Transaction struct {
+	ID                int64           `json:"id"`
+	OrderID           int64           `json:"order_id"`
+	ParentID          int64           `json:"parent_id"`
+	Kind              string          `json:"kind"`
+	Gateway           string          `json:"gateway"`
+	Status            string          `json:"status"`
+	Message           FlexString      `json:"message"`
+	ErrorCode         FlexString      `json:"error_code"`
+	Authorization     FlexString      `json:"authorization"`
+	Test              bool            `json:"test"`
+	Amount            Decimal         `json:"amount"`
+	Currency          string          `json:"currency"`
+	SourceName        string          `json:"source_name"`
+	CreatedAt         Time            `json:"created_at"`
+	ProcessedAt       Time            `json:"processed_at"`
+	PaymentDetails    *PaymentDetails `json:"payment_details,omitempty"`
+	AdminGraphqlAPIID string          `json:"admin_graphql_api_id"`
+}
+
+type RefundLineItem struct {
+	ID          int64    `json:"id"`
+	LineItemID  int64    `json:"line_item_id"`
+	LocationID  int64    `json:"location_id"`
+	Quantity    int      `json:"quantity"`
+	RestockType string   `json:"restock_type"`
+	Subtotal    Decimal  `json:"subtotal"`
+	TotalTax    Decimal  `json:"total_tax"`
+	LineItem    LineItem `json:"line_item"`
+}
+
+type Refund struct {
+	ID                int64            `json:"id"`
+	OrderID           int64            `json:"order_id"`
+	Note              FlexString       `json:"note"`
+	Restock           bool             `json:"restock"`
+	CreatedAt         Time             `json:"created_at"`
+	ProcessedAt       Time             `json:"processed_at"`
+	RefundLineItems   []RefundLineItem `json:"refund_line_items"`
+	Transactions      []Transaction    `json:"transactions"`
+	AdminGraphqlAPIID string           `json:"admin_graphql_api_id"`
+}
+
+type Order struct {
+	ID                    int64           `json:"id"`
+	Name                  string          `json:"name"`
+	Number                int             `json:"number"`
+	OrderNumber           int             `json:"order_number"`
+	Email                 string          `json:"email"`
+	ContactEmail          string          `json:"contact_email"`
+	Phone                 string          `json:"phone"`
+	Note                  FlexString      `json:"note"`
+	NoteAttributes        []NoteAttribute `json:"note_attributes"`
+	Tags                  string          `json:"tags"`
+	Test                  bool            `json:"test"`
+	Confirmed             bool            `json:"confirmed"`
+	Token                 string          `json:"token"`
+	Gateway               string          `json:"gateway"`
+	PaymentGatewayNames   []string        `json:"payment_gateway_names"`
+	FinancialStatus       string          `json:"financial_status"`
+	FulfillmentStatus     FlexString      `json:"fulfillment_status"`
+	Currency              string          `json:"currency"`
+	PresentmentCurrency   string          `json:"presentment_currency"`
+	TotalPrice            Decimal         `json:"total_price"`
+	SubtotalPrice         Decimal         `json:"subtotal_price"`
+	TotalTax              Decimal         `json:"total_tax"`
+	TotalDiscounts        Decimal         `json:"total_discounts"`
+	TotalLineItemsPrice   Decimal         `json:"total_line_items_price"`
+	TotalPriceSet         MoneySet        `json:"total_price_set"`
+	TaxesIncluded         bool            `json:"taxes_included"`
+	TotalWeight           int             `json:"total_weight"`
+	BuyerAcceptsMarketing bool            `json:"buyer_accepts_marketing"`
+	CustomerLocale        FlexString      `json:"customer_locale"`
+	BrowserIP             string          `json:"browser_ip"`
+	SourceName            string          `json:"source_name"`
+	LandingSite           FlexString      `json:"landing_site"`
+	ReferringSite         FlexString      `json:"referring_site"`
+	OrderStatusURL        string          `json:"order_status_url"`
+	CancelReason          FlexString      `json:"cancel_reason"`
+	CreatedAt             Time            `json:"created_at"`
+	UpdatedAt             Time            `json:"updated_at"`
+	ProcessedAt           Time            `json:"processed_at"`
+	ClosedAt              Time            `json:"closed_at"`
+	CancelledAt           Time            `json:"cancelled_at"`
+	DiscountCodes         []DiscountCode  `json:"discount_codes"`
+	TaxLines              []TaxLine       `json:"tax_lines"`
+	LineItems             []LineItem      `json:"line_items"`
+	ShippingLines         []ShippingLine  `json:"shipping_lines"`
+	BillingAddress        *Address        `json:"billing_address"`
+	ShippingAddress       *Address        `json:"shipping_address"`
+	Customer              *Customer       `json:"customer"`
+	ClientDetails         *ClientDetails  `json:"client_details"`
+	PaymentDetails        *PaymentDetails `json:"payment_details"`
+	Fulfillments          []Fulfillment   `json:"fulfillments"`
+	Refunds               []Refund        `json:"refunds"`
+	AdminGraphqlAPIID     string          `json:"admin_graphql_api_id"`
+}
+
+// HasTag はカンマ区切りの tags に tag が含まれるかを大文字小文字を区別せずに判定する。
+func (o *Order) HasTag(tag string) bool {
+	return hasTag(o.Tags, tag)
+}
+
+// HasTag は顧客のカンマ区切りの tags に tag が含まれるかを大文字小文字を区別せずに判定する。
+func (c *Customer) HasTag(tag string) bool {
+	return hasTag(c.Tags, tag)
+}
+
+func hasTag(tags, tag string) bool {
+	for _, t := range strings.Split(tags, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), tag) {
+			return true
+		}
+	}
+	return false
+}
+
+type GetOrdersResponse struct {
+	Orders []Order `json:"orders"`
+}
+
+type CancelOrderRequest struct {
+	Email   bool `json:"email"`
+	Restock bool `json:"restock"`
+}
+
+type CancelOrderResponse struct {
+	Order  Order  `json:"order"`
+	Notice string `json:"notice"`
+}
+
+type CreateTransactionRequest struct {
+	Transaction struct {
+		Currency string `json:"currency"`
+		Amount   string `json:"amount"`
+		Kind     string `json:"kind"`
+		ParentID int64  `json:"parent_id"`
+	} `json:"transaction"`
+}
+
+type CreateTransactionResponse struct {
+	Transaction Transaction `json:"transaction"`
+}
+
+type GetTransactionResponse struct {
+	Transactions []Transaction `json:"transactions"`
+}
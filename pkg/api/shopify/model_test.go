@@ -0,0 +1,98 @@
+package shopify
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "1000", want: "1000.00"},
+		{value: "1234.5", want: "1234.50"},
+		{value: " 12.345 ", want: "12.345"},
+		{value: "0.0001", want: "0.0001"},
+		{value: "1.23450", want: "1.2345"},
+		{value: ".5", want: "0.50"},
+		{value: "-3.5", want: "-3.50"},
+		{value: "+3", want: "3.00"},
+		{value: "", want: "0.00"},
+		{value: "1.23456", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "1.-5", wantErr: true},
+		{value: "--1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDecimal(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got.String() != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	d := func(value string) Decimal {
+		parsed, err := ParseDecimal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		{name: "add", got: d("0.1").Add(d("0.2")), want: "0.30"},
+		{name: "sub", got: d("1000").Sub(d("999.99")), want: "0.01"},
+		{name: "sub negative", got: d("1").Sub(d("2.5")), want: "-1.50"},
+		{name: "mul", got: d("19.99").Mul(3), want: "59.97"},
+		{name: "from int", got: DecimalFromInt(1500), want: "1500.00"},
+	}
+	for _, tt := range tests {
+		if tt.got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if d("1.5").Cmp(d("1.50")) != 0 || d("1.49").Cmp(d("1.5")) != -1 || d("2").Cmp(d("-2")) != 1 {
+		t.Errorf("Cmp mismatch")
+	}
+	if !d("0").IsZero() || d("0.0001").IsZero() || !d("0.0001").IsPositive() || d("-1").IsPositive() {
+		t.Errorf("IsZero / IsPositive mismatch")
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{json: `"1234.50"`, want: "1234.50"},
+		{json: `1234.5`, want: "1234.50"},
+		{json: `null`, want: "0.00"},
+		{json: `""`, want: "0.00"},
+	}
+	for _, tt := range tests {
+		var got Decimal
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", tt.json, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, want %s", tt.json, got, tt.want)
+		}
+	}
+
+	data, err := json.Marshal(DecimalFromInt(5))
+	if err != nil || string(data) != `"5.00"` {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+}
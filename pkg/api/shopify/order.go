@@ -4,1351 +4,248 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"sync"
+	"sort"
+	"strconv"
+	"strings"
 
 	"shopify-manager/pkg/config"
 	"shopify-manager/pkg/constants"
 	"shopify-manager/pkg/infrastructure/http"
+	"shopify-manager/pkg/infrastructure/worker"
+	"shopify-manager/pkg/snapshot"
+	"shopify-manager/pkg/storage"
 
 	"github.com/tealeg/xlsx"
 )
 
-type GetOrdersResponse struct {
-	Orders []struct {
-		ID                    int64       `json:"id"`
-		Email                 string      `json:"email"`
-		ClosedAt              interface{} `json:"closed_at"`
-		CreatedAt             string      `json:"created_at"`
-		UpdatedAt             string      `json:"updated_at"`
-		Number                int         `json:"number"`
-		Note                  interface{} `json:"note"`
-		Token                 string      `json:"token"`
-		Gateway               string      `json:"gateway"`
-		Test                  bool        `json:"test"`
-		TotalPrice            string      `json:"total_price"`
-		SubtotalPrice         string      `json:"subtotal_price"`
-		TotalWeight           int         `json:"total_weight"`
-		TotalTax              string      `json:"total_tax"`
-		TaxesIncluded         bool        `json:"taxes_included"`
-		Currency              string      `json:"currency"`
-		FinancialStatus       string      `json:"financial_status"`
-		Confirmed             bool        `json:"confirmed"`
-		TotalDiscounts        string      `json:"total_discounts"`
-		TotalLineItemsPrice   string      `json:"total_line_items_price"`
-		CartToken             string      `json:"cart_token"`
-		BuyerAcceptsMarketing bool        `json:"buyer_accepts_marketing"`
-		Name                  string      `json:"name"`
-		ReferringSite         string      `json:"referring_site"`
-		LandingSite           string      `json:"landing_site"`
-		CancelledAt           interface{} `json:"cancelled_at"`
-		CancelReason          interface{} `json:"cancel_reason"`
-		TotalPriceUsd         string      `json:"total_price_usd"`
-		CheckoutToken         string      `json:"checkout_token"`
-		Reference             string      `json:"reference"`
-		UserID                interface{} `json:"user_id"`
-		LocationID            interface{} `json:"location_id"`
-		SourceIdentifier      string      `json:"source_identifier"`
-		SourceURL             interface{} `json:"source_url"`
-		ProcessedAt           string      `json:"processed_at"`
-		DeviceID              interface{} `json:"device_id"`
-		Phone                 string      `json:"phone"`
-		CustomerLocale        interface{} `json:"customer_locale"`
-		AppID                 interface{} `json:"app_id"`
-		BrowserIP             string      `json:"browser_ip"`
-		LandingSiteRef        string      `json:"landing_site_ref"`
-		OrderNumber           int         `json:"order_number"`
-		DiscountApplications  []struct {
-			Type             string `json:"type"`
-			Value            string `json:"value"`
-			ValueType        string `json:"value_type"`
-			AllocationMethod string `json:"allocation_method"`
-			TargetSelection  string `json:"target_selection"`
-			TargetType       string `json:"target_type"`
-			Code             string `json:"code"`
-		} `json:"discount_applications"`
-		DiscountCodes []struct {
-			Code   string `json:"code"`
-			Amount string `json:"amount"`
-			Type   string `json:"type"`
-		} `json:"discount_codes"`
-		NoteAttributes []struct {
-			Name  string `json:"name"`
-			Value string `json:"value"`
-		} `json:"note_attributes"`
-		PaymentGatewayNames []string    `json:"payment_gateway_names"`
-		ProcessingMethod    string      `json:"processing_method"`
-		CheckoutID          int         `json:"checkout_id"`
-		SourceName          string      `json:"source_name"`
-		FulfillmentStatus   interface{} `json:"fulfillment_status"`
-		TaxLines            []struct {
-			Price    string  `json:"price"`
-			Rate     float64 `json:"rate"`
-			Title    string  `json:"title"`
-			PriceSet struct {
-				ShopMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"shop_money"`
-				PresentmentMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"presentment_money"`
-			} `json:"price_set"`
-		} `json:"tax_lines"`
-		Tags                   string `json:"tags"`
-		ContactEmail           string `json:"contact_email"`
-		OrderStatusURL         string `json:"order_status_url"`
-		PresentmentCurrency    string `json:"presentment_currency"`
-		TotalLineItemsPriceSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"total_line_items_price_set"`
-		TotalDiscountsSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"total_discounts_set"`
-		TotalShippingPriceSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"total_shipping_price_set"`
-		SubtotalPriceSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"subtotal_price_set"`
-		TotalPriceSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"total_price_set"`
-		TotalTaxSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"total_tax_set"`
-		TotalTipReceived       string      `json:"total_tip_received"`
-		OriginalTotalDutiesSet interface{} `json:"original_total_duties_set"`
-		CurrentTotalDutiesSet  interface{} `json:"current_total_duties_set"`
-		AdminGraphqlAPIID      string      `json:"admin_graphql_api_id"`
-		ShippingLines          []struct {
-			ID                            int         `json:"id"`
-			Title                         string      `json:"title"`
-			Price                         string      `json:"price"`
-			Code                          string      `json:"code"`
-			Source                        string      `json:"source"`
-			Phone                         interface{} `json:"phone"`
-			RequestedFulfillmentServiceID interface{} `json:"requested_fulfillment_service_id"`
-			DeliveryCategory              interface{} `json:"delivery_category"`
-			CarrierIdentifier             interface{} `json:"carrier_identifier"`
-			DiscountedPrice               string      `json:"discounted_price"`
-			PriceSet                      struct {
-				ShopMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"shop_money"`
-				PresentmentMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"presentment_money"`
-			} `json:"price_set"`
-			DiscountedPriceSet struct {
-				ShopMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"shop_money"`
-				PresentmentMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"presentment_money"`
-			} `json:"discounted_price_set"`
-			DiscountAllocations []interface{} `json:"discount_allocations"`
-			TaxLines            []interface{} `json:"tax_lines"`
-		} `json:"shipping_lines"`
-		BillingAddress struct {
-			FirstName    string      `json:"first_name"`
-			Address1     string      `json:"address1"`
-			Phone        string      `json:"phone"`
-			City         string      `json:"city"`
-			Zip          string      `json:"zip"`
-			Province     string      `json:"province"`
-			Country      string      `json:"country"`
-			LastName     string      `json:"last_name"`
-			Address2     string      `json:"address2"`
-			Company      interface{} `json:"company"`
-			Latitude     float64     `json:"latitude"`
-			Longitude    float64     `json:"longitude"`
-			Name         string      `json:"name"`
-			CountryCode  string      `json:"country_code"`
-			ProvinceCode string      `json:"province_code"`
-		} `json:"billing_address"`
-		ShippingAddress struct {
-			FirstName    string      `json:"first_name"`
-			Address1     string      `json:"address1"`
-			Phone        string      `json:"phone"`
-			City         string      `json:"city"`
-			Zip          string      `json:"zip"`
-			Province     string      `json:"province"`
-			Country      string      `json:"country"`
-			LastName     string      `json:"last_name"`
-			Address2     string      `json:"address2"`
-			Company      interface{} `json:"company"`
-			Latitude     float64     `json:"latitude"`
-			Longitude    float64     `json:"longitude"`
-			Name         string      `json:"name"`
-			CountryCode  string      `json:"country_code"`
-			ProvinceCode string      `json:"province_code"`
-		} `json:"shipping_address"`
-		ClientDetails struct {
-			BrowserIP      string      `json:"browser_ip"`
-			AcceptLanguage interface{} `json:"accept_language"`
-			UserAgent      interface{} `json:"user_agent"`
-			SessionHash    interface{} `json:"session_hash"`
-			BrowserWidth   interface{} `json:"browser_width"`
-			BrowserHeight  interface{} `json:"browser_height"`
-		} `json:"client_details"`
-		PaymentDetails struct {
-			CreditCardBin     interface{} `json:"credit_card_bin"`
-			AvsResultCode     interface{} `json:"avs_result_code"`
-			CvvResultCode     interface{} `json:"cvv_result_code"`
-			CreditCardNumber  string      `json:"credit_card_number"`
-			CreditCardCompany string      `json:"credit_card_company"`
-		} `json:"payment_details"`
-		Customer struct {
-			ID                        int           `json:"id"`
-			Email                     string        `json:"email"`
-			AcceptsMarketing          bool          `json:"accepts_marketing"`
-			CreatedAt                 string        `json:"created_at"`
-			UpdatedAt                 string        `json:"updated_at"`
-			FirstName                 string        `json:"first_name"`
-			LastName                  string        `json:"last_name"`
-			OrdersCount               int           `json:"orders_count"`
-			State                     string        `json:"state"`
-			TotalSpent                string        `json:"total_spent"`
-			LastOrderID               int           `json:"last_order_id"`
-			Note                      interface{}   `json:"note"`
-			VerifiedEmail             bool          `json:"verified_email"`
-			MultipassIdentifier       interface{}   `json:"multipass_identifier"`
-			TaxExempt                 bool          `json:"tax_exempt"`
-			Phone                     string        `json:"phone"`
-			Tags                      string        `json:"tags"`
-			LastOrderName             string        `json:"last_order_name"`
-			Currency                  string        `json:"currency"`
-			AcceptsMarketingUpdatedAt string        `json:"accepts_marketing_updated_at"`
-			MarketingOptInLevel       interface{}   `json:"marketing_opt_in_level"`
-			TaxExemptions             []interface{} `json:"tax_exemptions"`
-			AdminGraphqlAPIID         string        `json:"admin_graphql_api_id"`
-			DefaultAddress            struct {
-				ID           int         `json:"id"`
-				CustomerID   int         `json:"customer_id"`
-				FirstName    interface{} `json:"first_name"`
-				LastName     interface{} `json:"last_name"`
-				Company      interface{} `json:"company"`
-				Address1     string      `json:"address1"`
-				Address2     string      `json:"address2"`
-				City         string      `json:"city"`
-				Province     string      `json:"province"`
-				Country      string      `json:"country"`
-				Zip          string      `json:"zip"`
-				Phone        string      `json:"phone"`
-				Name         string      `json:"name"`
-				ProvinceCode string      `json:"province_code"`
-				CountryCode  string      `json:"country_code"`
-				CountryName  string      `json:"country_name"`
-				Default      bool        `json:"default"`
-			} `json:"default_address"`
-		} `json:"customer"`
-		LineItems []struct {
-			ID                         int         `json:"id"`
-			VariantID                  int         `json:"variant_id"`
-			Title                      string      `json:"title"`
-			Quantity                   int         `json:"quantity"`
-			Sku                        string      `json:"sku"`
-			VariantTitle               string      `json:"variant_title"`
-			Vendor                     interface{} `json:"vendor"`
-			FulfillmentService         string      `json:"fulfillment_service"`
-			ProductID                  int         `json:"product_id"`
-			RequiresShipping           bool        `json:"requires_shipping"`
-			Taxable                    bool        `json:"taxable"`
-			GiftCard                   bool        `json:"gift_card"`
-			Name                       string      `json:"name"`
-			VariantInventoryManagement string      `json:"variant_inventory_management"`
-			Properties                 []struct {
-				Name  string `json:"name"`
-				Value string `json:"value"`
-			} `json:"properties"`
-			ProductExists       bool        `json:"product_exists"`
-			FulfillableQuantity int         `json:"fulfillable_quantity"`
-			Grams               int         `json:"grams"`
-			Price               string      `json:"price"`
-			TotalDiscount       string      `json:"total_discount"`
-			FulfillmentStatus   interface{} `json:"fulfillment_status"`
-			PriceSet            struct {
-				ShopMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"shop_money"`
-				PresentmentMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"presentment_money"`
-			} `json:"price_set"`
-			TotalDiscountSet struct {
-				ShopMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"shop_money"`
-				PresentmentMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"presentment_money"`
-			} `json:"total_discount_set"`
-			DiscountAllocations []struct {
-				Amount                   string `json:"amount"`
-				DiscountApplicationIndex int    `json:"discount_application_index"`
-				AmountSet                struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"amount_set"`
-			} `json:"discount_allocations"`
-			Duties            []interface{} `json:"duties"`
-			AdminGraphqlAPIID string        `json:"admin_graphql_api_id"`
-			TaxLines          []struct {
-				Title    string  `json:"title"`
-				Price    string  `json:"price"`
-				Rate     float64 `json:"rate"`
-				PriceSet struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"price_set"`
-			} `json:"tax_lines"`
-		} `json:"line_items"`
-		Fulfillments []struct {
-			ID              int         `json:"id"`
-			OrderID         int         `json:"order_id"`
-			Status          string      `json:"status"`
-			CreatedAt       string      `json:"created_at"`
-			Service         string      `json:"service"`
-			UpdatedAt       string      `json:"updated_at"`
-			TrackingCompany string      `json:"tracking_company"`
-			ShipmentStatus  interface{} `json:"shipment_status"`
-			LocationID      int         `json:"location_id"`
-			TrackingNumber  string      `json:"tracking_number"`
-			TrackingNumbers []string    `json:"tracking_numbers"`
-			TrackingURL     string      `json:"tracking_url"`
-			TrackingUrls    []string    `json:"tracking_urls"`
-			Receipt         struct {
-				Testcase      bool   `json:"testcase"`
-				Authorization string `json:"authorization"`
-			} `json:"receipt"`
-			Name              string `json:"name"`
-			AdminGraphqlAPIID string `json:"admin_graphql_api_id"`
-			LineItems         []struct {
-				ID                         int         `json:"id"`
-				VariantID                  int         `json:"variant_id"`
-				Title                      string      `json:"title"`
-				Quantity                   int         `json:"quantity"`
-				Sku                        string      `json:"sku"`
-				VariantTitle               string      `json:"variant_title"`
-				Vendor                     interface{} `json:"vendor"`
-				FulfillmentService         string      `json:"fulfillment_service"`
-				ProductID                  int         `json:"product_id"`
-				RequiresShipping           bool        `json:"requires_shipping"`
-				Taxable                    bool        `json:"taxable"`
-				GiftCard                   bool        `json:"gift_card"`
-				Name                       string      `json:"name"`
-				VariantInventoryManagement string      `json:"variant_inventory_management"`
-				Properties                 []struct {
-					Name  string `json:"name"`
-					Value string `json:"value"`
-				} `json:"properties"`
-				ProductExists       bool        `json:"product_exists"`
-				FulfillableQuantity int         `json:"fulfillable_quantity"`
-				Grams               int         `json:"grams"`
-				Price               string      `json:"price"`
-				TotalDiscount       string      `json:"total_discount"`
-				FulfillmentStatus   interface{} `json:"fulfillment_status"`
-				PriceSet            struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"price_set"`
-				TotalDiscountSet struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"total_discount_set"`
-				DiscountAllocations []struct {
-					Amount                   string `json:"amount"`
-					DiscountApplicationIndex int    `json:"discount_application_index"`
-					AmountSet                struct {
-						ShopMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"shop_money"`
-						PresentmentMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"presentment_money"`
-					} `json:"amount_set"`
-				} `json:"discount_allocations"`
-				Duties            []interface{} `json:"duties"`
-				AdminGraphqlAPIID string        `json:"admin_graphql_api_id"`
-				TaxLines          []struct {
-					Title    string  `json:"title"`
-					Price    string  `json:"price"`
-					Rate     float64 `json:"rate"`
-					PriceSet struct {
-						ShopMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"shop_money"`
-						PresentmentMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"presentment_money"`
-					} `json:"price_set"`
-				} `json:"tax_lines"`
-			} `json:"line_items"`
-		} `json:"fulfillments"`
-		Refunds []struct {
-			ID                int           `json:"id"`
-			OrderID           int           `json:"order_id"`
-			CreatedAt         string        `json:"created_at"`
-			Note              string        `json:"note"`
-			UserID            int           `json:"user_id"`
-			ProcessedAt       string        `json:"processed_at"`
-			Restock           bool          `json:"restock"`
-			Duties            []interface{} `json:"duties"`
-			AdminGraphqlAPIID string        `json:"admin_graphql_api_id"`
-			RefundLineItems   []struct {
-				ID          int     `json:"id"`
-				Quantity    int     `json:"quantity"`
-				LineItemID  int     `json:"line_item_id"`
-				LocationID  int     `json:"location_id"`
-				RestockType string  `json:"restock_type"`
-				Subtotal    float64 `json:"subtotal"`
-				TotalTax    float64 `json:"total_tax"`
-				SubtotalSet struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"subtotal_set"`
-				TotalTaxSet struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"total_tax_set"`
-				LineItem struct {
-					ID                         int           `json:"id"`
-					VariantID                  int           `json:"variant_id"`
-					Title                      string        `json:"title"`
-					Quantity                   int           `json:"quantity"`
-					Sku                        string        `json:"sku"`
-					VariantTitle               string        `json:"variant_title"`
-					Vendor                     interface{}   `json:"vendor"`
-					FulfillmentService         string        `json:"fulfillment_service"`
-					ProductID                  int           `json:"product_id"`
-					RequiresShipping           bool          `json:"requires_shipping"`
-					Taxable                    bool          `json:"taxable"`
-					GiftCard                   bool          `json:"gift_card"`
-					Name                       string        `json:"name"`
-					VariantInventoryManagement string        `json:"variant_inventory_management"`
-					Properties                 []interface{} `json:"properties"`
-					ProductExists              bool          `json:"product_exists"`
-					FulfillableQuantity        int           `json:"fulfillable_quantity"`
-					Grams                      int           `json:"grams"`
-					Price                      string        `json:"price"`
-					TotalDiscount              string        `json:"total_discount"`
-					FulfillmentStatus          interface{}   `json:"fulfillment_status"`
-					PriceSet                   struct {
-						ShopMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"shop_money"`
-						PresentmentMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"presentment_money"`
-					} `json:"price_set"`
-					TotalDiscountSet struct {
-						ShopMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"shop_money"`
-						PresentmentMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"presentment_money"`
-					} `json:"total_discount_set"`
-					DiscountAllocations []struct {
-						Amount                   string `json:"amount"`
-						DiscountApplicationIndex int    `json:"discount_application_index"`
-						AmountSet                struct {
-							ShopMoney struct {
-								Amount       string `json:"amount"`
-								CurrencyCode string `json:"currency_code"`
-							} `json:"shop_money"`
-							PresentmentMoney struct {
-								Amount       string `json:"amount"`
-								CurrencyCode string `json:"currency_code"`
-							} `json:"presentment_money"`
-						} `json:"amount_set"`
-					} `json:"discount_allocations"`
-					Duties            []interface{} `json:"duties"`
-					AdminGraphqlAPIID string        `json:"admin_graphql_api_id"`
-					TaxLines          []struct {
-						Title    string  `json:"title"`
-						Price    string  `json:"price"`
-						Rate     float64 `json:"rate"`
-						PriceSet struct {
-							ShopMoney struct {
-								Amount       string `json:"amount"`
-								CurrencyCode string `json:"currency_code"`
-							} `json:"shop_money"`
-							PresentmentMoney struct {
-								Amount       string `json:"amount"`
-								CurrencyCode string `json:"currency_code"`
-							} `json:"presentment_money"`
-						} `json:"price_set"`
-					} `json:"tax_lines"`
-				} `json:"line_item"`
-			} `json:"refund_line_items"`
-			Transactions []struct {
-				ID            int         `json:"id"`
-				OrderID       int         `json:"order_id"`
-				Kind          string      `json:"kind"`
-				Gateway       string      `json:"gateway"`
-				Status        string      `json:"status"`
-				Message       interface{} `json:"message"`
-				CreatedAt     string      `json:"created_at"`
-				Test          bool        `json:"test"`
-				Authorization string      `json:"authorization"`
-				LocationID    interface{} `json:"location_id"`
-				UserID        interface{} `json:"user_id"`
-				ParentID      int         `json:"parent_id"`
-				ProcessedAt   string      `json:"processed_at"`
-				DeviceID      interface{} `json:"device_id"`
-				Receipt       struct {
-				} `json:"receipt"`
-				ErrorCode                  interface{} `json:"error_code"`
-				SourceName                 string      `json:"source_name"`
-				CurrencyExchangeAdjustment interface{} `json:"currency_exchange_adjustment"`
-				Amount                     string      `json:"amount"`
-				Currency                   string      `json:"currency"`
-				AdminGraphqlAPIID          string      `json:"admin_graphql_api_id"`
-			} `json:"transactions"`
-			OrderAdjustments []interface{} `json:"order_adjustments"`
-		} `json:"refunds"`
-	} `json:"orders"`
-}
+// RESTOCK_ON_CANCEL はオーダーのキャンセル時に未発送の数量を在庫に戻すかどうか。
+// REST と GraphQL のどちらの backend でも明示的に指定し、在庫戻りの確認 (restock) はこれを前提にする。
+const RESTOCK_ON_CANCEL = true
+
+// OnCancelled が設定されている場合、オーダーのキャンセルに成功する度に呼ばれる。
+// 顧客へのお知らせの送信などに使う。失敗してもキャンセルの結果には影響させないこと。
+var OnCancelled func(store *config.Store, order *Order)
+
+// CancelOrders は検索条件、または入力エクセルのオーダーをキャンセルする。
+// 入力エクセルの B 列にストア名がある行はそのストア、無い行は storeName のストアが対象になる。
+// 進捗と結果は sink に通知する。
+func CancelOrders(config *config.Config, storeName, query string, sink Sink) error {
+	store, err := config.GetStore(storeName)
+	if err != nil {
+		return err
+	}
+
+	if query != "" {
+		cancelOrderNumberList, err := SearchOrderNumberList(query, store)
+		if err != nil {
+			return err
+		}
+		return CancelOrderNumbersWithSink(cancelOrderNumberList, store, &config.Safety, sink)
+	}
+
+	storeOrderNumberList, err := getStoreOrderNumberList(constants.INPUT_EXCEL_FILE_PATH, store.Name)
+	if err != nil {
+		return err
+	}
 
-type CancelOrderRequest struct {
-	Email bool `json:"email"`
+	targetStores, err := resolveStores(config, sortedStoreNames(storeOrderNumberList))
+	if err != nil {
+		return err
+	}
+
+	// 安全上限と実行の確認は全ストアの対象をまとめて1回だけ行うため、先に全ストアのオーダーを取得する
+	var batches []*cancelBatch
+	for _, targetStore := range targetStores {
+		log.Printf("INFO : Get %d orders in store '%s'\n", len(storeOrderNumberList[targetStore.Name]), targetStore.Name)
+		batch, err := newCancelBatch(storeOrderNumberList[targetStore.Name], targetStore, sink)
+		if err != nil {
+			return err
+		}
+		batches = append(batches, batch)
+	}
+
+	return cancelBatches(batches, &config.Safety, sink)
 }
 
-type CancelOrderResponse struct {
-	Order struct {
-		ID                    int         `json:"id"`
-		Email                 string      `json:"email"`
-		ClosedAt              interface{} `json:"closed_at"`
-		CreatedAt             string      `json:"created_at"`
-		UpdatedAt             string      `json:"updated_at"`
-		Number                int         `json:"number"`
-		Note                  interface{} `json:"note"`
-		Token                 string      `json:"token"`
-		Gateway               string      `json:"gateway"`
-		Test                  bool        `json:"test"`
-		TotalPrice            string      `json:"total_price"`
-		SubtotalPrice         string      `json:"subtotal_price"`
-		TotalWeight           int         `json:"total_weight"`
-		TotalTax              string      `json:"total_tax"`
-		TaxesIncluded         bool        `json:"taxes_included"`
-		Currency              string      `json:"currency"`
-		FinancialStatus       string      `json:"financial_status"`
-		Confirmed             bool        `json:"confirmed"`
-		TotalDiscounts        string      `json:"total_discounts"`
-		TotalLineItemsPrice   string      `json:"total_line_items_price"`
-		CartToken             string      `json:"cart_token"`
-		BuyerAcceptsMarketing bool        `json:"buyer_accepts_marketing"`
-		Name                  string      `json:"name"`
-		ReferringSite         string      `json:"referring_site"`
-		LandingSite           string      `json:"landing_site"`
-		CancelledAt           interface{} `json:"cancelled_at"`
-		CancelReason          interface{} `json:"cancel_reason"`
-		TotalPriceUsd         string      `json:"total_price_usd"`
-		CheckoutToken         string      `json:"checkout_token"`
-		Reference             string      `json:"reference"`
-		UserID                interface{} `json:"user_id"`
-		LocationID            interface{} `json:"location_id"`
-		SourceIdentifier      string      `json:"source_identifier"`
-		SourceURL             interface{} `json:"source_url"`
-		ProcessedAt           string      `json:"processed_at"`
-		DeviceID              interface{} `json:"device_id"`
-		Phone                 string      `json:"phone"`
-		CustomerLocale        interface{} `json:"customer_locale"`
-		AppID                 interface{} `json:"app_id"`
-		BrowserIP             string      `json:"browser_ip"`
-		LandingSiteRef        string      `json:"landing_site_ref"`
-		OrderNumber           int         `json:"order_number"`
-		DiscountApplications  []struct {
-			Type             string `json:"type"`
-			Value            string `json:"value"`
-			ValueType        string `json:"value_type"`
-			AllocationMethod string `json:"allocation_method"`
-			TargetSelection  string `json:"target_selection"`
-			TargetType       string `json:"target_type"`
-			Code             string `json:"code"`
-		} `json:"discount_applications"`
-		DiscountCodes []struct {
-			Code   string `json:"code"`
-			Amount string `json:"amount"`
-			Type   string `json:"type"`
-		} `json:"discount_codes"`
-		NoteAttributes []struct {
-			Name  string `json:"name"`
-			Value string `json:"value"`
-		} `json:"note_attributes"`
-		PaymentGatewayNames []string    `json:"payment_gateway_names"`
-		ProcessingMethod    string      `json:"processing_method"`
-		CheckoutID          int         `json:"checkout_id"`
-		SourceName          string      `json:"source_name"`
-		FulfillmentStatus   interface{} `json:"fulfillment_status"`
-		TaxLines            []struct {
-			Price    string  `json:"price"`
-			Rate     float64 `json:"rate"`
-			Title    string  `json:"title"`
-			PriceSet struct {
-				ShopMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"shop_money"`
-				PresentmentMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"presentment_money"`
-			} `json:"price_set"`
-		} `json:"tax_lines"`
-		Tags                   string `json:"tags"`
-		ContactEmail           string `json:"contact_email"`
-		OrderStatusURL         string `json:"order_status_url"`
-		PresentmentCurrency    string `json:"presentment_currency"`
-		TotalLineItemsPriceSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"total_line_items_price_set"`
-		TotalDiscountsSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"total_discounts_set"`
-		TotalShippingPriceSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"total_shipping_price_set"`
-		SubtotalPriceSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"subtotal_price_set"`
-		TotalPriceSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"total_price_set"`
-		TotalTaxSet struct {
-			ShopMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"shop_money"`
-			PresentmentMoney struct {
-				Amount       string `json:"amount"`
-				CurrencyCode string `json:"currency_code"`
-			} `json:"presentment_money"`
-		} `json:"total_tax_set"`
-		TotalTipReceived       string      `json:"total_tip_received"`
-		OriginalTotalDutiesSet interface{} `json:"original_total_duties_set"`
-		CurrentTotalDutiesSet  interface{} `json:"current_total_duties_set"`
-		AdminGraphqlAPIID      string      `json:"admin_graphql_api_id"`
-		ShippingLines          []struct {
-			ID                            int         `json:"id"`
-			Title                         string      `json:"title"`
-			Price                         string      `json:"price"`
-			Code                          string      `json:"code"`
-			Source                        string      `json:"source"`
-			Phone                         interface{} `json:"phone"`
-			RequestedFulfillmentServiceID interface{} `json:"requested_fulfillment_service_id"`
-			DeliveryCategory              interface{} `json:"delivery_category"`
-			CarrierIdentifier             interface{} `json:"carrier_identifier"`
-			DiscountedPrice               string      `json:"discounted_price"`
-			PriceSet                      struct {
-				ShopMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"shop_money"`
-				PresentmentMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"presentment_money"`
-			} `json:"price_set"`
-			DiscountedPriceSet struct {
-				ShopMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"shop_money"`
-				PresentmentMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"presentment_money"`
-			} `json:"discounted_price_set"`
-			DiscountAllocations []interface{} `json:"discount_allocations"`
-			TaxLines            []interface{} `json:"tax_lines"`
-		} `json:"shipping_lines"`
-		BillingAddress struct {
-			FirstName    string      `json:"first_name"`
-			Address1     string      `json:"address1"`
-			Phone        string      `json:"phone"`
-			City         string      `json:"city"`
-			Zip          string      `json:"zip"`
-			Province     string      `json:"province"`
-			Country      string      `json:"country"`
-			LastName     string      `json:"last_name"`
-			Address2     string      `json:"address2"`
-			Company      interface{} `json:"company"`
-			Latitude     float64     `json:"latitude"`
-			Longitude    float64     `json:"longitude"`
-			Name         string      `json:"name"`
-			CountryCode  string      `json:"country_code"`
-			ProvinceCode string      `json:"province_code"`
-		} `json:"billing_address"`
-		ShippingAddress struct {
-			FirstName    string      `json:"first_name"`
-			Address1     string      `json:"address1"`
-			Phone        string      `json:"phone"`
-			City         string      `json:"city"`
-			Zip          string      `json:"zip"`
-			Province     string      `json:"province"`
-			Country      string      `json:"country"`
-			LastName     string      `json:"last_name"`
-			Address2     string      `json:"address2"`
-			Company      interface{} `json:"company"`
-			Latitude     float64     `json:"latitude"`
-			Longitude    float64     `json:"longitude"`
-			Name         string      `json:"name"`
-			CountryCode  string      `json:"country_code"`
-			ProvinceCode string      `json:"province_code"`
-		} `json:"shipping_address"`
-		ClientDetails struct {
-			BrowserIP      string      `json:"browser_ip"`
-			AcceptLanguage interface{} `json:"accept_language"`
-			UserAgent      interface{} `json:"user_agent"`
-			SessionHash    interface{} `json:"session_hash"`
-			BrowserWidth   interface{} `json:"browser_width"`
-			BrowserHeight  interface{} `json:"browser_height"`
-		} `json:"client_details"`
-		PaymentDetails struct {
-			CreditCardBin     interface{} `json:"credit_card_bin"`
-			AvsResultCode     interface{} `json:"avs_result_code"`
-			CvvResultCode     interface{} `json:"cvv_result_code"`
-			CreditCardNumber  string      `json:"credit_card_number"`
-			CreditCardCompany string      `json:"credit_card_company"`
-		} `json:"payment_details"`
-		Customer struct {
-			ID                        int           `json:"id"`
-			Email                     string        `json:"email"`
-			AcceptsMarketing          bool          `json:"accepts_marketing"`
-			CreatedAt                 string        `json:"created_at"`
-			UpdatedAt                 string        `json:"updated_at"`
-			FirstName                 string        `json:"first_name"`
-			LastName                  string        `json:"last_name"`
-			OrdersCount               int           `json:"orders_count"`
-			State                     string        `json:"state"`
-			TotalSpent                string        `json:"total_spent"`
-			LastOrderID               int           `json:"last_order_id"`
-			Note                      interface{}   `json:"note"`
-			VerifiedEmail             bool          `json:"verified_email"`
-			MultipassIdentifier       interface{}   `json:"multipass_identifier"`
-			TaxExempt                 bool          `json:"tax_exempt"`
-			Phone                     interface{}   `json:"phone"`
-			Tags                      string        `json:"tags"`
-			LastOrderName             string        `json:"last_order_name"`
-			Currency                  string        `json:"currency"`
-			AcceptsMarketingUpdatedAt string        `json:"accepts_marketing_updated_at"`
-			MarketingOptInLevel       interface{}   `json:"marketing_opt_in_level"`
-			TaxExemptions             []interface{} `json:"tax_exemptions"`
-			AdminGraphqlAPIID         string        `json:"admin_graphql_api_id"`
-			DefaultAddress            struct {
-				ID           int         `json:"id"`
-				CustomerID   int         `json:"customer_id"`
-				FirstName    interface{} `json:"first_name"`
-				LastName     interface{} `json:"last_name"`
-				Company      interface{} `json:"company"`
-				Address1     string      `json:"address1"`
-				Address2     string      `json:"address2"`
-				City         string      `json:"city"`
-				Province     string      `json:"province"`
-				Country      string      `json:"country"`
-				Zip          string      `json:"zip"`
-				Phone        string      `json:"phone"`
-				Name         string      `json:"name"`
-				ProvinceCode string      `json:"province_code"`
-				CountryCode  string      `json:"country_code"`
-				CountryName  string      `json:"country_name"`
-				Default      bool        `json:"default"`
-			} `json:"default_address"`
-		} `json:"customer"`
-		LineItems []struct {
-			ID                         int         `json:"id"`
-			VariantID                  int         `json:"variant_id"`
-			Title                      string      `json:"title"`
-			Quantity                   int         `json:"quantity"`
-			Sku                        string      `json:"sku"`
-			VariantTitle               string      `json:"variant_title"`
-			Vendor                     interface{} `json:"vendor"`
-			FulfillmentService         string      `json:"fulfillment_service"`
-			ProductID                  int         `json:"product_id"`
-			RequiresShipping           bool        `json:"requires_shipping"`
-			Taxable                    bool        `json:"taxable"`
-			GiftCard                   bool        `json:"gift_card"`
-			Name                       string      `json:"name"`
-			VariantInventoryManagement string      `json:"variant_inventory_management"`
-			Properties                 []struct {
-				Name  string `json:"name"`
-				Value string `json:"value"`
-			} `json:"properties"`
-			ProductExists       bool        `json:"product_exists"`
-			FulfillableQuantity int         `json:"fulfillable_quantity"`
-			Grams               int         `json:"grams"`
-			Price               string      `json:"price"`
-			TotalDiscount       string      `json:"total_discount"`
-			FulfillmentStatus   interface{} `json:"fulfillment_status"`
-			PriceSet            struct {
-				ShopMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"shop_money"`
-				PresentmentMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"presentment_money"`
-			} `json:"price_set"`
-			TotalDiscountSet struct {
-				ShopMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"shop_money"`
-				PresentmentMoney struct {
-					Amount       string `json:"amount"`
-					CurrencyCode string `json:"currency_code"`
-				} `json:"presentment_money"`
-			} `json:"total_discount_set"`
-			DiscountAllocations []struct {
-				Amount                   string `json:"amount"`
-				DiscountApplicationIndex int    `json:"discount_application_index"`
-				AmountSet                struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"amount_set"`
-			} `json:"discount_allocations"`
-			Duties            []interface{} `json:"duties"`
-			AdminGraphqlAPIID string        `json:"admin_graphql_api_id"`
-			TaxLines          []struct {
-				Title    string  `json:"title"`
-				Price    string  `json:"price"`
-				Rate     float64 `json:"rate"`
-				PriceSet struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"price_set"`
-			} `json:"tax_lines"`
-		} `json:"line_items"`
-		Fulfillments []struct {
-			ID              int         `json:"id"`
-			OrderID         int         `json:"order_id"`
-			Status          string      `json:"status"`
-			CreatedAt       string      `json:"created_at"`
-			Service         string      `json:"service"`
-			UpdatedAt       string      `json:"updated_at"`
-			TrackingCompany string      `json:"tracking_company"`
-			ShipmentStatus  interface{} `json:"shipment_status"`
-			LocationID      int         `json:"location_id"`
-			TrackingNumber  string      `json:"tracking_number"`
-			TrackingNumbers []string    `json:"tracking_numbers"`
-			TrackingURL     string      `json:"tracking_url"`
-			TrackingUrls    []string    `json:"tracking_urls"`
-			Receipt         struct {
-				Testcase      bool   `json:"testcase"`
-				Authorization string `json:"authorization"`
-			} `json:"receipt"`
-			Name              string `json:"name"`
-			AdminGraphqlAPIID string `json:"admin_graphql_api_id"`
-			LineItems         []struct {
-				ID                         int         `json:"id"`
-				VariantID                  int         `json:"variant_id"`
-				Title                      string      `json:"title"`
-				Quantity                   int         `json:"quantity"`
-				Sku                        string      `json:"sku"`
-				VariantTitle               string      `json:"variant_title"`
-				Vendor                     interface{} `json:"vendor"`
-				FulfillmentService         string      `json:"fulfillment_service"`
-				ProductID                  int         `json:"product_id"`
-				RequiresShipping           bool        `json:"requires_shipping"`
-				Taxable                    bool        `json:"taxable"`
-				GiftCard                   bool        `json:"gift_card"`
-				Name                       string      `json:"name"`
-				VariantInventoryManagement string      `json:"variant_inventory_management"`
-				Properties                 []struct {
-					Name  string `json:"name"`
-					Value string `json:"value"`
-				} `json:"properties"`
-				ProductExists       bool        `json:"product_exists"`
-				FulfillableQuantity int         `json:"fulfillable_quantity"`
-				Grams               int         `json:"grams"`
-				Price               string      `json:"price"`
-				TotalDiscount       string      `json:"total_discount"`
-				FulfillmentStatus   interface{} `json:"fulfillment_status"`
-				PriceSet            struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"price_set"`
-				TotalDiscountSet struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"total_discount_set"`
-				DiscountAllocations []struct {
-					Amount                   string `json:"amount"`
-					DiscountApplicationIndex int    `json:"discount_application_index"`
-					AmountSet                struct {
-						ShopMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"shop_money"`
-						PresentmentMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"presentment_money"`
-					} `json:"amount_set"`
-				} `json:"discount_allocations"`
-				Duties            []interface{} `json:"duties"`
-				AdminGraphqlAPIID string        `json:"admin_graphql_api_id"`
-				TaxLines          []struct {
-					Title    string  `json:"title"`
-					Price    string  `json:"price"`
-					Rate     float64 `json:"rate"`
-					PriceSet struct {
-						ShopMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"shop_money"`
-						PresentmentMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"presentment_money"`
-					} `json:"price_set"`
-				} `json:"tax_lines"`
-			} `json:"line_items"`
-		} `json:"fulfillments"`
-		Refunds []struct {
-			ID                int           `json:"id"`
-			OrderID           int           `json:"order_id"`
-			CreatedAt         string        `json:"created_at"`
-			Note              string        `json:"note"`
-			UserID            int           `json:"user_id"`
-			ProcessedAt       string        `json:"processed_at"`
-			Restock           bool          `json:"restock"`
-			Duties            []interface{} `json:"duties"`
-			AdminGraphqlAPIID string        `json:"admin_graphql_api_id"`
-			RefundLineItems   []struct {
-				ID          int     `json:"id"`
-				Quantity    int     `json:"quantity"`
-				LineItemID  int     `json:"line_item_id"`
-				LocationID  int     `json:"location_id"`
-				RestockType string  `json:"restock_type"`
-				Subtotal    float64 `json:"subtotal"`
-				TotalTax    float64 `json:"total_tax"`
-				SubtotalSet struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"subtotal_set"`
-				TotalTaxSet struct {
-					ShopMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"shop_money"`
-					PresentmentMoney struct {
-						Amount       string `json:"amount"`
-						CurrencyCode string `json:"currency_code"`
-					} `json:"presentment_money"`
-				} `json:"total_tax_set"`
-				LineItem struct {
-					ID                         int           `json:"id"`
-					VariantID                  int           `json:"variant_id"`
-					Title                      string        `json:"title"`
-					Quantity                   int           `json:"quantity"`
-					Sku                        string        `json:"sku"`
-					VariantTitle               string        `json:"variant_title"`
-					Vendor                     interface{}   `json:"vendor"`
-					FulfillmentService         string        `json:"fulfillment_service"`
-					ProductID                  int           `json:"product_id"`
-					RequiresShipping           bool          `json:"requires_shipping"`
-					Taxable                    bool          `json:"taxable"`
-					GiftCard                   bool          `json:"gift_card"`
-					Name                       string        `json:"name"`
-					VariantInventoryManagement string        `json:"variant_inventory_management"`
-					Properties                 []interface{} `json:"properties"`
-					ProductExists              bool          `json:"product_exists"`
-					FulfillableQuantity        int           `json:"fulfillable_quantity"`
-					Grams                      int           `json:"grams"`
-					Price                      string        `json:"price"`
-					TotalDiscount              string        `json:"total_discount"`
-					FulfillmentStatus          interface{}   `json:"fulfillment_status"`
-					PriceSet                   struct {
-						ShopMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"shop_money"`
-						PresentmentMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"presentment_money"`
-					} `json:"price_set"`
-					TotalDiscountSet struct {
-						ShopMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"shop_money"`
-						PresentmentMoney struct {
-							Amount       string `json:"amount"`
-							CurrencyCode string `json:"currency_code"`
-						} `json:"presentment_money"`
-					} `json:"total_discount_set"`
-					DiscountAllocations []struct {
-						Amount                   string `json:"amount"`
-						DiscountApplicationIndex int    `json:"discount_application_index"`
-						AmountSet                struct {
-							ShopMoney struct {
-								Amount       string `json:"amount"`
-								CurrencyCode string `json:"currency_code"`
-							} `json:"shop_money"`
-							PresentmentMoney struct {
-								Amount       string `json:"amount"`
-								CurrencyCode string `json:"currency_code"`
-							} `json:"presentment_money"`
-						} `json:"amount_set"`
-					} `json:"discount_allocations"`
-					Duties            []interface{} `json:"duties"`
-					AdminGraphqlAPIID string        `json:"admin_graphql_api_id"`
-					TaxLines          []struct {
-						Title    string  `json:"title"`
-						Price    string  `json:"price"`
-						Rate     float64 `json:"rate"`
-						PriceSet struct {
-							ShopMoney struct {
-								Amount       string `json:"amount"`
-								CurrencyCode string `json:"currency_code"`
-							} `json:"shop_money"`
-							PresentmentMoney struct {
-								Amount       string `json:"amount"`
-								CurrencyCode string `json:"currency_code"`
-							} `json:"presentment_money"`
-						} `json:"price_set"`
-					} `json:"tax_lines"`
-				} `json:"line_item"`
-			} `json:"refund_line_items"`
-			Transactions []struct {
-				ID            int         `json:"id"`
-				OrderID       int         `json:"order_id"`
-				Kind          string      `json:"kind"`
-				Gateway       string      `json:"gateway"`
-				Status        string      `json:"status"`
-				Message       interface{} `json:"message"`
-				CreatedAt     string      `json:"created_at"`
-				Test          bool        `json:"test"`
-				Authorization string      `json:"authorization"`
-				LocationID    interface{} `json:"location_id"`
-				UserID        interface{} `json:"user_id"`
-				ParentID      int         `json:"parent_id"`
-				ProcessedAt   string      `json:"processed_at"`
-				DeviceID      interface{} `json:"device_id"`
-				Receipt       struct {
-				} `json:"receipt"`
-				ErrorCode         interface{} `json:"error_code"`
-				SourceName        string      `json:"source_name"`
-				Amount            string      `json:"amount"`
-				Currency          string      `json:"currency"`
-				AdminGraphqlAPIID string      `json:"admin_graphql_api_id"`
-			} `json:"transactions"`
-			OrderAdjustments []interface{} `json:"order_adjustments"`
-		} `json:"refunds"`
-	} `json:"order"`
-	Notice string `json:"notice"`
+func CancelOrderNumbers(cancelOrderNumberList []int, store *config.Store, safety *config.Safety) error {
+	return CancelOrderNumbersWithSink(cancelOrderNumberList, store, safety, ConsoleSink{})
 }
 
-type CreateTransactionRequest struct {
-	Transaction struct {
-		Currency string `json:"currency"`
-		Amount   string `json:"amount"`
-		Kind     string `json:"kind"`
-		ParentID int64  `json:"parent_id"`
-	} `json:"transaction"`
+// CancelOrderNumbersWithSink は CancelOrderNumbers と同じだが、進捗と結果を sink に通知する。
+func CancelOrderNumbersWithSink(cancelOrderNumberList []int, store *config.Store, safety *config.Safety, sink Sink) error {
+	batch, err := newCancelBatch(cancelOrderNumberList, store, sink)
+	if err != nil {
+		return err
+	}
+	return cancelBatches([]*cancelBatch{batch}, safety, sink)
 }
 
-type CreateTransactionResponse struct {
-	Transaction struct {
-		ID            int         `json:"id"`
-		OrderID       int         `json:"order_id"`
-		Kind          string      `json:"kind"`
-		Gateway       string      `json:"gateway"`
-		Status        string      `json:"status"`
-		Message       string      `json:"message"`
-		CreatedAt     string      `json:"created_at"`
-		Test          bool        `json:"test"`
-		Authorization interface{} `json:"authorization"`
-		LocationID    interface{} `json:"location_id"`
-		UserID        interface{} `json:"user_id"`
-		ParentID      int         `json:"parent_id"`
-		ProcessedAt   string      `json:"processed_at"`
-		DeviceID      interface{} `json:"device_id"`
-		Receipt       struct {
-		} `json:"receipt"`
-		ErrorCode                  interface{} `json:"error_code"`
-		SourceName                 string      `json:"source_name"`
-		CurrencyExchangeAdjustment interface{} `json:"currency_exchange_adjustment"`
-		Amount                     string      `json:"amount"`
-		Currency                   string      `json:"currency"`
-		AdminGraphqlAPIID          string      `json:"admin_graphql_api_id"`
-	} `json:"transaction"`
+// cancelBatch は1ストア分のキャンセル対象のオーダー。
+// isSuccess は取得できなかった・安全上限で除いたオーダーがある場合に false になる。
+type cancelBatch struct {
+	store     *config.Store
+	client    Client
+	orders    []*Order
+	isSuccess bool
 }
 
-type GetTransactionResponse struct {
-	Transactions []struct {
-		ID            int64       `json:"id"`
-		OrderID       int         `json:"order_id"`
-		Kind          string      `json:"kind"`
-		Gateway       string      `json:"gateway"`
-		Status        string      `json:"status"`
-		Message       interface{} `json:"message"`
-		CreatedAt     string      `json:"created_at"`
-		Test          bool        `json:"test"`
-		Authorization string      `json:"authorization"`
-		LocationID    interface{} `json:"location_id"`
-		UserID        interface{} `json:"user_id"`
-		ParentID      int         `json:"parent_id"`
-		ProcessedAt   string      `json:"processed_at"`
-		DeviceID      interface{} `json:"device_id"`
-		Receipt       struct {
-			Testcase      bool   `json:"testcase"`
-			Authorization string `json:"authorization"`
-		} `json:"receipt"`
-		ErrorCode                  interface{} `json:"error_code"`
-		SourceName                 string      `json:"source_name"`
-		CurrencyExchangeAdjustment interface{} `json:"currency_exchange_adjustment"`
-		Amount                     string      `json:"amount"`
-		Currency                   string      `json:"currency"`
-		AdminGraphqlAPIID          string      `json:"admin_graphql_api_id"`
-		PaymentDetails             struct {
-			CreditCardBin     interface{} `json:"credit_card_bin"`
-			AvsResultCode     interface{} `json:"avs_result_code"`
-			CvvResultCode     interface{} `json:"cvv_result_code"`
-			CreditCardNumber  string      `json:"credit_card_number"`
-			CreditCardCompany string      `json:"credit_card_company"`
-		} `json:"payment_details,omitempty"`
-	} `json:"transactions"`
+// newCancelBatch はストアのキャンセル対象のオーダーを取得する。
+func newCancelBatch(cancelOrderNumberList []int, store *config.Store, sink Sink) (*cancelBatch, error) {
+	client, err := NewClient(store)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, isSuccess := getOrders(client, cancelOrderNumberList, store, sink)
+	return &cancelBatch{store: store, client: client, orders: orders, isSuccess: isSuccess}, nil
 }
 
-func CancelOrders(config *config.Config) error {
-	isSuccess := true
-	cancelOrderNumberList, err := getOrderNumberList(constants.INPUT_EXCEL_FILE_PATH)
+// cancelBatches は全ストアの対象をまとめて安全上限の確認と実行の確認を行い、ストア毎にキャンセルする。
+func cancelBatches(batches []*cancelBatch, safety *config.Safety, sink Sink) error {
+	err := checkSafety(batches, safety, sink)
 	if err != nil {
 		return err
 	}
 
-	var wg sync.WaitGroup
-	limitCh := make(chan struct{}, config.Thread.ThreadNum)
-	for _, orderNumber := range cancelOrderNumberList {
-		wg.Add(1)
-		limitCh <- struct{}{}
-		go func(orderNumber int) {
-			defer wg.Done()
-
-			log.Printf("INFO : Try to get order by orderNumber '%d'\n", orderNumber)
-			order, err := getOrder(orderNumber, config)
-			if err != nil {
-				log.Printf("ERROR : orderNumber '%d' failed to cancel due to coludn't get order. %s\n", orderNumber, err.Error())
-				isSuccess = false
-				<-limitCh
-				return
-			}
+	if !confirmCancel(batches, safety) {
+		return fmt.Errorf("キャンセルを中止しました")
+	}
 
-			log.Printf("INFO : Try to get transactionId by orderId '%d' (orderNumber '%d')\n", order.Orders[0].ID, orderNumber)
-			transactionId, err := getTransactionId(order.Orders[0].ID, config)
-			if err != nil {
-				log.Printf("ERROR : orderNumber '%d' failed to cancel due to couldn't get transactionId. %s\n", orderNumber, err.Error())
-				isSuccess = false
-				<-limitCh
-				return
+	isSuccess := true
+	for _, batch := range batches {
+		if !batch.cancel(sink) {
+			if len(batches) > 1 {
+				log.Printf("ERROR : store '%s' Failed to cancel any of orders.\n", batch.store.Name)
 			}
+			isSuccess = false
+		}
+	}
 
-			log.Printf("INFO : Try to disable authorization by orderId '%d' and transactionId '%d' (orderNumber '%d')\n", order.Orders[0].ID, transactionId, orderNumber)
-			_, err = disabeAuthorization(order.Orders[0].ID, transactionId, config)
-			if err != nil {
-				log.Printf("ERROR : orderNumber '%d' failed to cancel due to coludn't be disable auhtorization. %s\n", orderNumber, err.Error())
-				isSuccess = false
-				<-limitCh
-				return
-			}
+	if isSuccess {
+		return nil
+	} else {
+		return fmt.Errorf("Failed to cancel any of orders.")
+	}
+}
 
-			log.Printf("INFO : Try to cancel order by orderId '%d' (orderNumber '%d')\n", order.Orders[0].ID, orderNumber)
-			_, err = cancelOrder(order.Orders[0].ID, config)
-			if err != nil {
-				log.Printf("ERROR : orderNumber '%d' failed to cancel. %s\n", orderNumber, err.Error())
-				isSuccess = false
-				<-limitCh
-				return
-			}
+// cancel はオーダー毎にオーソリ取消とキャンセルを行い、全て成功した場合に true を返す。
+func (b *cancelBatch) cancel(sink Sink) bool {
+	store := b.store
+	client := b.client
+	orders := b.orders
+
+	var success worker.Success
+	restock := newRestockCheck(store, client, orders)
+	archive := snapshot.Begin(store.Name)
+	reporter := sink.Progress(fmt.Sprintf("キャンセル (%s)", store.Name), len(orders))
+	worker.New(store.Domain, store.ThreadNum).Run(len(orders), func(i int) {
+		order := orders[i]
+		orderNumber := order.OrderNumber
+		item := strconv.Itoa(orderNumber)
+		result := newOrderResult(sink, store, orderNumber, order)
+
+		reporter.Step(item, "snapshot")
+		err := takeSnapshot(archive, order, store)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' failed to cancel due to couldn't save snapshot. %s\n", orderNumber, err.Error())
+			success.Fail()
+			reporter.Failed(item, err)
+			result.failed("snapshot", err)
+			return
+		}
 
-			log.Printf("orderNumber '%d' successed to cancel.\n", orderNumber)
-			<-limitCh
+		reporter.Step(item, "get transactionId")
+		log.Printf("INFO : Try to get transactionId by orderId '%d' (orderNumber '%d')\n", order.ID, orderNumber)
+		transactionId, err := client.GetAuthorizationTransactionId(order.ID)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' failed to cancel due to couldn't get transactionId. %s\n", orderNumber, err.Error())
+			success.Fail()
+			reporter.Failed(item, err)
+			result.failed("get transactionId", err)
+			return
+		}
 
-		}(orderNumber)
+		result.TransactionID = transactionId
+
+		reporter.Step(item, "disable authorization")
+		log.Printf("INFO : Try to disable authorization by orderId '%d' and transactionId '%d' (orderNumber '%d')\n", order.ID, transactionId, orderNumber)
+		err = client.VoidTransaction(order, transactionId)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' failed to cancel due to coludn't be disable auhtorization. %s\n", orderNumber, err.Error())
+			success.Fail()
+			reporter.Failed(item, err)
+			result.failed("disable authorization", err)
+			return
+		}
+
+		reporter.Step(item, "cancel order")
+		log.Printf("INFO : Try to cancel order by orderId '%d' (orderNumber '%d')\n", order.ID, orderNumber)
+		err = client.CancelOrder(order)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' failed to cancel. %s\n", orderNumber, err.Error())
+			success.Fail()
+			reporter.Failed(item, err)
+			result.failed("cancel order", err)
+			return
+		}
+
+		log.Printf("orderNumber '%d' successed to cancel.\n", orderNumber)
+		restock.cancelled(order)
+		if OnCancelled != nil {
+			OnCancelled(store, order)
+		}
+		reporter.Succeeded(item)
+		result.succeeded()
+	})
+	reporter.Finish()
+	closeSnapshots(archive)
+
+	if !restock.finish() {
+		log.Printf("WARN : 在庫が戻っていない商品があります (store '%s')。restock の結果を確認してください\n", store.Name)
 	}
 
-	wg.Wait()
+	return b.isSuccess && success.OK()
+}
 
-	if isSuccess {
-		return nil
-	} else {
-		return fmt.Errorf("Failed to cancel any of orders.")
+// getOrders はオーダー番号のオーダーを取得する。取得できなかったオーダーは除き、
+// 1件でも失敗があれば isSuccess を false で返す。
+func getOrders(client Client, orderNumberList []int, store *config.Store, sink Sink) ([]*Order, bool) {
+	var success worker.Success
+	orders := make([]*Order, len(orderNumberList))
+
+	reporter := sink.Progress(fmt.Sprintf("オーダー取得 (%s)", store.Name), len(orderNumberList))
+	worker.New(store.Domain, store.ThreadNum).Run(len(orderNumberList), func(i int) {
+		orderNumber := orderNumberList[i]
+		item := strconv.Itoa(orderNumber)
+		reporter.Step(item, "get order")
+		log.Printf("INFO : Try to get order by orderNumber '%d'\n", orderNumber)
+		order, err := client.GetOrder(orderNumber)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' failed to cancel due to coludn't get order. %s\n", orderNumber, err.Error())
+			success.Fail()
+			reporter.Failed(item, err)
+			newOrderResult(sink, store, orderNumber, nil).failed("get order", err)
+			return
+		}
+		orders[i] = order
+		reporter.Succeeded(item)
+	})
+	reporter.Finish()
+
+	var found []*Order
+	for _, order := range orders {
+		if order != nil {
+			found = append(found, order)
+		}
 	}
+	return found, success.OK()
 }
 
-func getOrder(orderNumber int, config *config.Config) (*GetOrdersResponse, error) {
+func getOrder(orderNumber int, store *config.Store) (*Order, error) {
 
-	getOrderUrl := fmt.Sprintf(constants.GET_ORDER_URL_TEMPLATE, config.ApiInfo.ApiKey, config.ApiInfo.ApiPassword, orderNumber)
-	httpReqHeader := map[string]string{}
-	httpReqHeader["Content-Type"] = "application/json"
-	jsonRes, err := http.Get(getOrderUrl, nil, httpReqHeader)
+	getOrderUrl := restUrl(store, constants.GET_ORDER_URL_TEMPLATE, store.Domain, store.ApiVersion, orderNumber)
+	httpReqHeader := restHeader(store)
+	jsonRes, err := http.Get(getOrderUrl, httpReqHeader, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -1364,22 +261,22 @@ func getOrder(orderNumber int, config *config.Config) (*GetOrdersResponse, error
 		return nil, fmt.Errorf("Not found Order by order number '%d'", orderNumber)
 	}
 
-	return orderResponse, nil
+	return &orderResponse.Orders[0], nil
 }
 
-func cancelOrder(cancelOrderId int64, config *config.Config) (*CancelOrderResponse, error) {
+func cancelOrder(order *Order, store *config.Store) (*CancelOrderResponse, error) {
 	cancelOrderReq := new(CancelOrderRequest)
 	cancelOrderReq.Email = true
+	cancelOrderReq.Restock = RESTOCK_ON_CANCEL
 	reqJsonBytes, err := json.MarshalIndent(cancelOrderReq, "", "  ")
 	if err != nil {
 		log.Println("Cancel order request json marshal error")
 		return nil, err
 	}
 
-	cancelOrderUrl := fmt.Sprintf(constants.CANCEL_ORDER_URL_TEMPLATE, config.ApiInfo.ApiKey, config.ApiInfo.ApiPassword, cancelOrderId)
-	httpReqHeader := map[string]string{}
-	httpReqHeader["Content-Type"] = "application/json"
-	jsonRes, err := http.Post(cancelOrderUrl, reqJsonBytes, httpReqHeader)
+	cancelOrderUrl := restUrl(store, constants.CANCEL_ORDER_URL_TEMPLATE, store.Domain, store.ApiVersion, order.ID)
+	httpReqHeader := restHeader(store)
+	jsonRes, err := postWithAudit(cancelOrderUrl, reqJsonBytes, httpReqHeader, store, AUDIT_ACTION_CANCEL, order, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -1394,77 +291,167 @@ func cancelOrder(cancelOrderId int64, config *config.Config) (*CancelOrderRespon
 	return cancelOrderResponse, nil
 }
 
-func disabeAuthorization(orderId, transactionId int64, config *config.Config) (*CreateTransactionResponse, error) {
+func disabeAuthorization(order *Order, transactionId int64, store *config.Store) (*CreateTransactionResponse, error) {
 
 	createTransactionReq := new(CreateTransactionRequest)
 	createTransactionReq.Transaction.Kind = "void"
-	createTransactionReq.Transaction.Currency = "JPY"
+	createTransactionReq.Transaction.Currency = store.Currency
+	createTransactionReq.Transaction.ParentID = transactionId
+	return createTransaction(createTransactionReq, AUDIT_ACTION_VOID, order, transactionId, store)
+}
+
+func captureTransaction(order *Order, transactionId int64, amount string, store *config.Store) (*CreateTransactionResponse, error) {
+
+	createTransactionReq := new(CreateTransactionRequest)
+	createTransactionReq.Transaction.Kind = "capture"
+	createTransactionReq.Transaction.Currency = store.Currency
+	createTransactionReq.Transaction.Amount = amount
 	createTransactionReq.Transaction.ParentID = transactionId
+	return createTransaction(createTransactionReq, AUDIT_ACTION_CAPTURE, order, transactionId, store)
+}
+
+// createTransaction はトランザクションを作成する。
+// レスポンスの status が success でない場合は、GraphQL と同じくエラーとして監査ファイルに記録してエラーを返す。
+func createTransaction(createTransactionReq *CreateTransactionRequest, action string, order *Order, transactionId int64, store *config.Store) (*CreateTransactionResponse, error) {
 	reqJsonBytes, err := json.MarshalIndent(createTransactionReq, "", "  ")
 	if err != nil {
 		log.Println("Create transaction request json marshal error")
 		return nil, err
 	}
-	createTransactionUrl := fmt.Sprintf(constants.TRANSACTIONS_URL_TEMPLATE, config.ApiInfo.ApiKey, config.ApiInfo.ApiPassword, orderId)
-	httpReqHeader := map[string]string{}
-	httpReqHeader["Content-Type"] = "application/json"
-	jsonRes, err := http.Post(createTransactionUrl, reqJsonBytes, httpReqHeader)
+	err = checkAudit(action)
 	if err != nil {
 		return nil, err
 	}
 
+	createTransactionUrl := restUrl(store, constants.TRANSACTIONS_URL_TEMPLATE, store.Domain, store.ApiVersion, order.ID)
+	httpReqHeader := restHeader(store)
+	res, err := http.PostWithResponse(createTransactionUrl, reqJsonBytes, httpReqHeader)
+
 	createTransactionRes := new(CreateTransactionResponse)
-	err = json.Unmarshal(jsonRes, &createTransactionRes)
+	if err == nil {
+		err = json.Unmarshal(res.Body, &createTransactionRes)
+		if err != nil {
+			log.Println("Create transaction response json unmarshal err")
+		} else if createTransactionRes.Transaction.Status != "success" {
+			err = fmt.Errorf("Create transaction response status is not success. actual %s", createTransactionRes.Transaction.Status)
+		}
+	}
+
+	err = recordAudit(store, action, order, transactionId, reqJsonBytes, res, err)
 	if err != nil {
-		log.Println("Create transaction response json unmarshal err")
 		return nil, err
 	}
+	return createTransactionRes, nil
+}
+
+// getStoreOrderNumberList は入力エクセルのオーダー番号をストア毎にまとめる。
+// B 列が空の行は defaultStore のオーダーとして扱う。
+func getStoreOrderNumberList(excelFilePath, defaultStore string) (map[string][]int, error) {
+	orderIdList := map[string][]int{}
+	err := readInputRows(excelFilePath, func(row inputRow) error {
+		orderId, err := row.int(0)
+		if err != nil {
+			log.Printf("%d行目、OrderIDが空、または数値でないためスキップ", row.num)
+			return err
+		}
+
+		store := defaultStore
+		if row.cell(1) != "" {
+			store = row.cell(1)
+		}
 
-	if createTransactionRes.Transaction.Status != "success" {
-		log.Printf("Create transaction response status is not success. actual %s\n", createTransactionRes.Transaction.Status)
+		orderIdList[store] = append(orderIdList[store], orderId)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return createTransactionRes, nil
+	return orderIdList, nil
+}
+
+// inputRow は入力エクセルの1行。num はエクセル上の行番号。
+type inputRow struct {
+	num   int
+	cells []*xlsx.Cell
+}
+
+// cell は index 列の前後の空白を除いた値を返す。列が無い場合は空文字。
+func (r inputRow) cell(index int) string {
+	if index < len(r.cells) {
+		return strings.TrimSpace(r.cells[index].String())
+	}
+	return ""
+}
+
+// int は index 列の数値を返す。
+func (r inputRow) int(index int) (int, error) {
+	if index >= len(r.cells) {
+		return 0, fmt.Errorf("%d行目の%d列目が空です", r.num, index+1)
+	}
+	return r.cells[index].Int()
 }
 
-func getOrderNumberList(excelFilePath string) ([]int, error) {
-	excel, err := xlsx.OpenFile(excelFilePath)
+// readInputRows は入力エクセルの1枚目のシートを、見出しの1行目と A 列が空の行を除いて1行ずつ f に渡す。
+// f がエラーを返した場合はそこで読み込みを止める。
+func readInputRows(excelFilePath string, f func(row inputRow) error) error {
+	excel, err := storage.OpenXlsx(excelFilePath)
 	if err != nil {
 		log.Printf("%sのオープンに失敗", excelFilePath)
-		return nil, err
+		return err
 	}
 
-	var orderIdList []int
 	sheet := excel.Sheets[0]
 	for i, row := range sheet.Rows {
 		if i == 0 {
 			continue
 		}
 
-		name := row.Cells[0].String()
-		if name == "" {
+		inputRow := inputRow{num: i + 1, cells: row.Cells}
+		if inputRow.cell(0) == "" {
 			continue
 		}
 
-		orderId, err := row.Cells[0].Int()
+		err = f(inputRow)
 		if err != nil {
-			log.Printf("%d行目、OrderIDが空、または数値でないためスキップ", i+1)
-			return nil, err
+			return err
 		}
+	}
+	return nil
+}
 
-		orderIdList = append(orderIdList, orderId)
+func sortedStoreNames(storeOrderNumberList map[string][]int) []string {
+	var names []string
+	for name := range storeOrderNumberList {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	return orderIdList, nil
+// resolveStores は入力エクセルのストア名を全て設定のストアに変換する。
+// 未設定のストアが1つでもあれば、どのストアも変更しないうちにまとめてエラーにする。
+func resolveStores(config *config.Config, names []string) (stores []*config.Store, err error) {
+	var problems []string
+	for _, name := range names {
+		store, err := config.GetStore(name)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		stores = append(stores, store)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("入力エクセルに設定されていないストアがあるため中止しました\n  %s", strings.Join(problems, "\n  "))
+	}
+	return stores, nil
 }
 
-func getTransactionId(orderId int64, config *config.Config) (int64, error) {
+func getTransactionId(orderId int64, store *config.Store) (int64, error) {
 
-	getTransactionUrl := fmt.Sprintf(constants.TRANSACTIONS_URL_TEMPLATE, config.ApiInfo.ApiKey, config.ApiInfo.ApiPassword, orderId)
-	httpReqHeader := map[string]string{}
-	httpReqHeader["Content-Type"] = "application/json"
-	jsonRes, err := http.Get(getTransactionUrl, nil, httpReqHeader)
+	getTransactionUrl := restUrl(store, constants.TRANSACTIONS_URL_TEMPLATE, store.Domain, store.ApiVersion, orderId)
+	httpReqHeader := restHeader(store)
+	jsonRes, err := http.Get(getTransactionUrl, httpReqHeader, nil)
 	if err != nil {
 		return -1, err
 	}
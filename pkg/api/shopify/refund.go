@@ -0,0 +1,432 @@
+package shopify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/infrastructure/http"
+	"shopify-manager/pkg/infrastructure/worker"
+	"shopify-manager/pkg/snapshot"
+)
+
+// 返金の line item の restock_type
+const RESTOCK_TYPE_CANCEL = "cancel"
+const RESTOCK_TYPE_NO_RESTOCK = "no_restock"
+
+type RefundLineItemRequest struct {
+	LineItemID  int64  `json:"line_item_id"`
+	Quantity    int    `json:"quantity"`
+	RestockType string `json:"restock_type"`
+	LocationID  int64  `json:"location_id,omitempty"`
+}
+
+type CalculateRefundRequest struct {
+	Refund struct {
+		Shipping struct {
+			FullRefund bool `json:"full_refund"`
+		} `json:"shipping"`
+		RefundLineItems []RefundLineItemRequest `json:"refund_line_items"`
+	} `json:"refund"`
+}
+
+type CalculatedRefundLineItem struct {
+	Quantity             int     `json:"quantity"`
+	LineItemID           int64   `json:"line_item_id"`
+	LocationID           int64   `json:"location_id"`
+	RestockType          string  `json:"restock_type"`
+	Price                Decimal `json:"price"`
+	Subtotal             Decimal `json:"subtotal"`
+	TotalTax             Decimal `json:"total_tax"`
+	DiscountedPrice      Decimal `json:"discounted_price"`
+	DiscountedTotalPrice Decimal `json:"discounted_total_price"`
+	TotalCartDiscount    Decimal `json:"total_cart_discount_amount"`
+}
+
+type SuggestedRefundTransaction struct {
+	OrderID           int64   `json:"order_id"`
+	Amount            Decimal `json:"amount"`
+	Kind              string  `json:"kind"`
+	Gateway           string  `json:"gateway"`
+	ParentID          int64   `json:"parent_id"`
+	MaximumRefundable Decimal `json:"maximum_refundable"`
+	Currency          string  `json:"currency"`
+}
+
+type CalculateRefundResponse struct {
+	Refund struct {
+		Currency        string                       `json:"currency"`
+		RefundLineItems []CalculatedRefundLineItem   `json:"refund_line_items"`
+		Transactions    []SuggestedRefundTransaction `json:"transactions"`
+	} `json:"refund"`
+}
+
+type RefundTransactionRequest struct {
+	ParentID int64  `json:"parent_id"`
+	Amount   string `json:"amount"`
+	Kind     string `json:"kind"`
+	Gateway  string `json:"gateway"`
+}
+
+type CreateRefundRequest struct {
+	Refund struct {
+		Currency        string                     `json:"currency"`
+		Notify          bool                       `json:"notify"`
+		Note            string                     `json:"note,omitempty"`
+		RefundLineItems []RefundLineItemRequest    `json:"refund_line_items"`
+		Transactions    []RefundTransactionRequest `json:"transactions"`
+	} `json:"refund"`
+}
+
+type CreateRefundResponse struct {
+	Refund Refund `json:"refund"`
+}
+
+// LineItemCancel は行単位キャンセル入力の1行を表す。
+// Item は SKU、もしくは数値の場合は variant ID として扱う。
+type LineItemCancel struct {
+	Row         int
+	Store       string
+	OrderNumber int
+	Item        string
+	Quantity    int
+}
+
+// CancelLineItems は入力エクセルの line item をキャンセルする。
+// D 列にストア名がある行はそのストア、無い行は storeName のストアが対象になる。
+// 進捗と結果は sink に通知する。
+func CancelLineItems(config *config.Config, storeName string, sink Sink) error {
+	store, err := config.GetStore(storeName)
+	if err != nil {
+		return err
+	}
+
+	cancelList, err := getLineItemCancelList(constants.INPUT_LINE_ITEM_EXCEL_FILE_PATH, store.Name)
+	if err != nil {
+		return err
+	}
+
+	var storeNames []string
+	storeCancelMap := map[string][]LineItemCancel{}
+	for _, cancel := range cancelList {
+		if _, ok := storeCancelMap[cancel.Store]; !ok {
+			storeNames = append(storeNames, cancel.Store)
+		}
+		storeCancelMap[cancel.Store] = append(storeCancelMap[cancel.Store], cancel)
+	}
+
+	targetStores, err := resolveStores(config, storeNames)
+	if err != nil {
+		return err
+	}
+
+	isSuccess := true
+	for _, targetStore := range targetStores {
+		name := targetStore.Name
+		err = cancelStoreLineItems(storeCancelMap[name], targetStore, sink)
+		if err != nil {
+			log.Printf("ERROR : store '%s' %s\n", name, err.Error())
+			isSuccess = false
+		}
+	}
+
+	if isSuccess {
+		return nil
+	} else {
+		return fmt.Errorf("Failed to cancel any of line items.")
+	}
+}
+
+func cancelStoreLineItems(cancelList []LineItemCancel, store *config.Store, sink Sink) error {
+	client, err := NewClient(store)
+	if err != nil {
+		return err
+	}
+
+	var success worker.Success
+
+	// 同一オーダーの行は1回の返金にまとめる
+	var orderNumberList []int
+	cancelMap := map[int][]LineItemCancel{}
+	for _, cancel := range cancelList {
+		if _, ok := cancelMap[cancel.OrderNumber]; !ok {
+			orderNumberList = append(orderNumberList, cancel.OrderNumber)
+		}
+		cancelMap[cancel.OrderNumber] = append(cancelMap[cancel.OrderNumber], cancel)
+	}
+
+	archive := snapshot.Begin(store.Name)
+	reporter := sink.Progress(fmt.Sprintf("行単位キャンセル (%s)", store.Name), len(orderNumberList))
+	worker.New(store.Domain, store.ThreadNum).Run(len(orderNumberList), func(i int) {
+		orderNumber := orderNumberList[i]
+		cancels := cancelMap[orderNumber]
+		item := strconv.Itoa(orderNumber)
+		result := newOrderResult(sink, store, orderNumber, nil)
+
+		reporter.Step(item, "get order")
+		log.Printf("INFO : Try to get order by orderNumber '%d'\n", orderNumber)
+		order, err := client.GetOrder(orderNumber)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' failed to cancel line items due to coludn't get order. %s\n", orderNumber, err.Error())
+			success.Fail()
+			reporter.Failed(item, err)
+			result.failed("get order", err)
+			return
+		}
+
+		result = newOrderResult(sink, store, orderNumber, order)
+
+		refundLineItems, err := buildRefundLineItems(order, cancels)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' failed to cancel line items. %s\n", orderNumber, err.Error())
+			success.Fail()
+			reporter.Failed(item, err)
+			result.failed("build refund line items", err)
+			return
+		}
+
+		reporter.Step(item, "snapshot")
+		err = takeSnapshot(archive, order, store)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' failed to cancel line items due to couldn't save snapshot. %s\n", orderNumber, err.Error())
+			success.Fail()
+			reporter.Failed(item, err)
+			result.failed("snapshot", err)
+			return
+		}
+
+		reporter.Step(item, "calculate refund")
+		log.Printf("INFO : Try to calculate refund by orderId '%d' (orderNumber '%d')\n", order.ID, orderNumber)
+		calculated, err := client.CalculateRefund(order, refundLineItems)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' failed to cancel line items due to couldn't calculate refund. %s\n", orderNumber, err.Error())
+			success.Fail()
+			reporter.Failed(item, err)
+			result.failed("calculate refund", err)
+			return
+		}
+
+		reporter.Step(item, "create refund")
+		log.Printf("INFO : Try to create refund by orderId '%d' (orderNumber '%d')\n", order.ID, orderNumber)
+		refund, err := client.CreateRefund(order, calculated)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' failed to cancel line items. %s\n", orderNumber, err.Error())
+			success.Fail()
+			reporter.Failed(item, err)
+			result.failed("create refund", err)
+			return
+		}
+
+		log.Printf("orderNumber '%d' successed to cancel %d line items. refundId '%d'\n", orderNumber, len(refund.RefundLineItems), refund.ID)
+		reporter.Succeeded(item)
+		result.RefundID = refund.ID
+		result.Amount = refundAmount(*refund).String()
+		result.Currency = calculated.Refund.Currency
+		result.succeeded()
+	})
+	reporter.Finish()
+	closeSnapshots(archive)
+
+	if success.OK() {
+		return nil
+	} else {
+		return fmt.Errorf("Failed to cancel any of line items.")
+	}
+}
+
+// refundAmount は返金トランザクションの合計金額を返す。
+func refundAmount(refund Refund) Decimal {
+	var total Decimal
+	for _, transaction := range refund.Transactions {
+		total = total.Add(transaction.Amount)
+	}
+	return total
+}
+
+// buildRefundLineItems は入力行をオーダーの line item に突き合わせ、line item 毎に数量を合計する。
+// 合計が返金できる数量を超える場合は、Shopify に送る前にエラーにする。
+func buildRefundLineItems(order *Order, cancels []LineItemCancel) ([]RefundLineItemRequest, error) {
+	var lineItems []*LineItem
+	quantities := map[int64]int{}
+	rows := map[int64][]string{}
+	for _, cancel := range cancels {
+		lineItem := findLineItem(order, cancel.Item)
+		if lineItem == nil {
+			return nil, fmt.Errorf("%d行目 : SKU または variant ID '%s' に該当する line item がありません", cancel.Row, cancel.Item)
+		}
+		if _, ok := quantities[lineItem.ID]; !ok {
+			lineItems = append(lineItems, lineItem)
+		}
+		quantities[lineItem.ID] += cancel.Quantity
+		rows[lineItem.ID] = append(rows[lineItem.ID], strconv.Itoa(cancel.Row))
+	}
+
+	var refundLineItems []RefundLineItemRequest
+	for _, lineItem := range lineItems {
+		quantity := quantities[lineItem.ID]
+		refundable := refundableQuantity(order, lineItem)
+		if quantity > refundable {
+			return nil, fmt.Errorf("%s行目 : '%s' の数量の合計 %d が返金できる数量 %d (オーダーの数量 %d から発送済み・返金済みを除く) を超えています", strings.Join(rows[lineItem.ID], ","), lineItem.Name, quantity, refundable, lineItem.Quantity)
+		}
+		refundLineItems = append(refundLineItems, RefundLineItemRequest{
+			LineItemID:  lineItem.ID,
+			Quantity:    quantity,
+			RestockType: RESTOCK_TYPE_CANCEL,
+		})
+	}
+
+	return refundLineItems, nil
+}
+
+// findLineItem は SKU、または数値の場合は variant ID が一致する最初の line item を返す。
+func findLineItem(order *Order, item string) *LineItem {
+	for i := range order.LineItems {
+		lineItem := &order.LineItems[i]
+		if lineItem.Sku == item || strconv.FormatInt(lineItem.VariantID, 10) == item {
+			return lineItem
+		}
+	}
+	return nil
+}
+
+// refundableQuantity は在庫を戻してキャンセルできる数量。
+// 返金済みの数量を除き、未発送の数量 (fulfillable_quantity) を超えない。
+func refundableQuantity(order *Order, lineItem *LineItem) int {
+	refundable := lineItem.Quantity
+	for _, refund := range order.Refunds {
+		for _, refundLineItem := range refund.RefundLineItems {
+			if refundLineItem.LineItemID == lineItem.ID {
+				refundable -= refundLineItem.Quantity
+			}
+		}
+	}
+	if lineItem.FulfillableQuantity < refundable {
+		refundable = lineItem.FulfillableQuantity
+	}
+	if refundable < 0 {
+		return 0
+	}
+	return refundable
+}
+
+func calculateRefund(orderId int64, refundLineItems []RefundLineItemRequest, store *config.Store) (*CalculateRefundResponse, error) {
+	calculateRefundReq := new(CalculateRefundRequest)
+	calculateRefundReq.Refund.RefundLineItems = refundLineItems
+	reqJsonBytes, err := json.MarshalIndent(calculateRefundReq, "", "  ")
+	if err != nil {
+		log.Println("Calculate refund request json marshal error")
+		return nil, err
+	}
+
+	calculateRefundUrl := restUrl(store, constants.CALCULATE_REFUND_URL_TEMPLATE, store.Domain, store.ApiVersion, orderId)
+	httpReqHeader := restHeader(store)
+	jsonRes, err := http.Post(calculateRefundUrl, reqJsonBytes, httpReqHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	calculateRefundRes := new(CalculateRefundResponse)
+	err = json.Unmarshal(jsonRes, &calculateRefundRes)
+	if err != nil {
+		log.Println("Calculate refund response json unmarshal err")
+		return nil, err
+	}
+
+	return calculateRefundRes, nil
+}
+
+func createRefund(order *Order, calculated *CalculateRefundResponse, store *config.Store) (*CreateRefundResponse, error) {
+	createRefundReq := new(CreateRefundRequest)
+	createRefundReq.Refund.Currency = calculated.Refund.Currency
+	createRefundReq.Refund.Notify = true
+	for _, lineItem := range calculated.Refund.RefundLineItems {
+		createRefundReq.Refund.RefundLineItems = append(createRefundReq.Refund.RefundLineItems, RefundLineItemRequest{
+			LineItemID:  lineItem.LineItemID,
+			Quantity:    lineItem.Quantity,
+			RestockType: lineItem.RestockType,
+			LocationID:  lineItem.LocationID,
+		})
+	}
+	// 計算結果の suggested_refund を実際の返金トランザクションに変換する。
+	// オーソリのみで売上確定前のオーダーは返金額が 0 になるため送らない。
+	for _, transaction := range calculated.Refund.Transactions {
+		if !transaction.Amount.IsPositive() {
+			continue
+		}
+		createRefundReq.Refund.Transactions = append(createRefundReq.Refund.Transactions, RefundTransactionRequest{
+			ParentID: transaction.ParentID,
+			Amount:   transaction.Amount.String(),
+			Kind:     "refund",
+			Gateway:  transaction.Gateway,
+		})
+	}
+	reqJsonBytes, err := json.MarshalIndent(createRefundReq, "", "  ")
+	if err != nil {
+		log.Println("Create refund request json marshal error")
+		return nil, err
+	}
+
+	createRefundUrl := restUrl(store, constants.REFUNDS_URL_TEMPLATE, store.Domain, store.ApiVersion, order.ID)
+	httpReqHeader := restHeader(store)
+	jsonRes, err := postWithAudit(createRefundUrl, reqJsonBytes, httpReqHeader, store, AUDIT_ACTION_REFUND, order, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	createRefundRes := new(CreateRefundResponse)
+	err = json.Unmarshal(jsonRes, &createRefundRes)
+	if err != nil {
+		log.Println("Create refund response json unmarshal err")
+		return nil, err
+	}
+
+	return createRefundRes, nil
+}
+
+func getLineItemCancelList(excelFilePath, defaultStore string) ([]LineItemCancel, error) {
+	var cancelList []LineItemCancel
+	err := readInputRows(excelFilePath, func(row inputRow) error {
+		if len(row.cells) < 3 {
+			return fmt.Errorf("%d行目、OrderID・SKU(または variant ID)・数量の3列が必要です", row.num)
+		}
+
+		orderNumber, err := row.int(0)
+		if err != nil {
+			log.Printf("%d行目、OrderIDが数値でないためエラー", row.num)
+			return err
+		}
+
+		item := row.cell(1)
+		if item == "" {
+			return fmt.Errorf("%d行目、SKU または variant ID が空です", row.num)
+		}
+
+		quantity, err := row.int(2)
+		if err != nil || quantity < 1 {
+			return fmt.Errorf("%d行目、数量が空、または1以上の数値ではありません", row.num)
+		}
+
+		store := defaultStore
+		if row.cell(3) != "" {
+			store = row.cell(3)
+		}
+
+		cancelList = append(cancelList, LineItemCancel{
+			Row:         row.num,
+			Store:       store,
+			OrderNumber: orderNumber,
+			Item:        item,
+			Quantity:    quantity,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cancelList, nil
+}
@@ -0,0 +1,35 @@
+package shopify
+
+import (
+	"fmt"
+	"net/url"
+
+	"shopify-manager/pkg/config"
+)
+
+// restUrl は REST API の URL テンプレート (先頭が apiKey・apiPassword の userinfo) に args を埋めた URL を返す。
+// apiKey が無いストア (GraphQL 用のアクセストークンのみ設定したストア) では userinfo を外し、
+// restHeader のアクセストークンで認証する。
+func restUrl(store *config.Store, template string, args ...interface{}) string {
+	rawUrl := fmt.Sprintf(template, append([]interface{}{store.ApiKey, store.ApiPassword}, args...)...)
+	if store.ApiKey != "" {
+		return rawUrl
+	}
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	u.User = nil
+	return u.String()
+}
+
+// restHeader は REST API のリクエストヘッダを返す。apiKey が無いストアでは apiPassword をアクセストークンとして送る。
+func restHeader(store *config.Store) map[string]string {
+	httpReqHeader := map[string]string{}
+	httpReqHeader["Content-Type"] = "application/json"
+	if store.ApiKey == "" {
+		httpReqHeader["X-Shopify-Access-Token"] = store.ApiPassword
+	}
+	return httpReqHeader
+}
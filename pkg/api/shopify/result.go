@@ -0,0 +1,94 @@
+package shopify
+
+import (
+	"sync"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/infrastructure/progress"
+	"shopify-manager/pkg/output"
+)
+
+// Sink はオーダー毎の進捗と処理結果の通知先。
+// コマンドラインではコンソールへの進捗表示と JSON 出力、ローカル API ではジョブの状態に使う。
+type Sink interface {
+	Progress(title string, total int) progress.Reporter
+	Result(result *OrderResult)
+}
+
+// ConsoleSink は進捗をコンソールに表示し、結果を JSON 出力モードで標準出力に書き出す。
+type ConsoleSink struct{}
+
+func (ConsoleSink) Progress(title string, total int) progress.Reporter {
+	return progress.New(title, total)
+}
+
+func (ConsoleSink) Result(result *OrderResult) {
+	output.Write(result)
+}
+
+// RecordingSink は ConsoleSink と同じ出力に加えて、結果を保持する。フロー終了時の通知に使う。
+type RecordingSink struct {
+	ConsoleSink
+	mutex   sync.Mutex
+	results []*OrderResult
+}
+
+func (s *RecordingSink) Result(result *OrderResult) {
+	s.ConsoleSink.Result(result)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.results = append(s.results, result)
+}
+
+// Results はこれまでに受け取った結果を返す。
+func (s *RecordingSink) Results() []*OrderResult {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*OrderResult{}, s.results...)
+}
+
+// OrderResult は JSON 出力モードで書き出す1オーダーの処理結果。
+// Step は失敗・スキップした処理の段階。
+type OrderResult struct {
+	Type          string `json:"type"`
+	Store         string `json:"store"`
+	OrderNumber   int    `json:"order_number"`
+	OrderID       int64  `json:"order_id,omitempty"`
+	TransactionID int64  `json:"transaction_id,omitempty"`
+	RefundID      int64  `json:"refund_id,omitempty"`
+	Amount        string `json:"amount,omitempty"`
+	Currency      string `json:"currency,omitempty"`
+	Status        string `json:"status"`
+	Step          string `json:"step,omitempty"`
+	Error         string `json:"error,omitempty"`
+	sink          Sink
+}
+
+func newOrderResult(sink Sink, store *config.Store, orderNumber int, order *Order) *OrderResult {
+	result := &OrderResult{Type: output.TYPE_ORDER, Store: store.Name, OrderNumber: orderNumber, sink: sink}
+	if order != nil {
+		result.OrderID = order.ID
+		result.Amount = order.TotalPrice.String()
+		result.Currency = order.Currency
+	}
+	return result
+}
+
+func (r *OrderResult) succeeded() {
+	r.Status = output.STATUS_SUCCEEDED
+	r.sink.Result(r)
+}
+
+func (r *OrderResult) failed(step string, err error) {
+	r.Status = output.STATUS_FAILED
+	r.Step = step
+	r.Error = err.Error()
+	r.sink.Result(r)
+}
+
+func (r *OrderResult) skipped(step string, err error) {
+	r.Status = output.STATUS_SKIPPED
+	r.Step = step
+	r.Error = err.Error()
+	r.sink.Result(r)
+}
@@ -0,0 +1,243 @@
+package shopify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/output"
+	"shopify-manager/pkg/snapshot"
+)
+
+// 取り消し (revert) で行う処理
+const REVERT_ACTION_REOPEN = "reopen"
+const REVERT_ACTION_DRAFT_ORDER = "draft-order"
+const REVERT_ACTION_NONE = "none"
+
+// 復元した下書き注文に付けるタグ
+const REVERT_TAG = "reverted"
+
+// RevertTarget は監査ファイルから集めた、取り消す対象のオーダーと行われた変更。
+// CancelledAt はキャンセルを記録した日時で、オーソリ取消のみの場合はゼロ値。
+type RevertTarget struct {
+	OrderNumber int
+	OrderID     int64
+	CancelledAt time.Time
+	Voided      bool
+	Snapshot    *snapshot.Snapshot
+}
+
+// RevertResult は JSON 出力モードで書き出す1オーダーの取り消しの結果。
+// Executed が false の場合は -execute 無しの確認のみで、Shopify は変更していない。
+// NotRestored は元に戻せない項目 (取り消したオーソリなど)。
+type RevertResult struct {
+	Type           string   `json:"type"`
+	Store          string   `json:"store"`
+	OrderNumber    int      `json:"order_number"`
+	OrderID        int64    `json:"order_id"`
+	Action         string   `json:"action"`
+	Executed       bool     `json:"executed"`
+	DraftOrderID   int64    `json:"draft_order_id,omitempty"`
+	DraftOrderName string   `json:"draft_order_name,omitempty"`
+	InvoiceURL     string   `json:"invoice_url,omitempty"`
+	NotRestored    []string `json:"not_restored,omitempty"`
+	Status         string   `json:"status"`
+	Step           string   `json:"step,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// RevertOrder は誤ってキャンセル・オーソリ取消したオーダーを、可能な範囲で元に戻す。
+// キャンセルされたオーダーは Shopify では再開できないため、スナップショットから同じ商品・顧客・住所の下書き注文を作る。
+// キャンセルされずにクローズされたオーダーは再開する。execute が false の場合は確認のみ行う。
+func RevertOrder(target *RevertTarget, store *config.Store, execute bool) *RevertResult {
+	result := &RevertResult{Type: output.TYPE_REVERT, Store: store.Name, OrderNumber: target.OrderNumber, OrderID: target.OrderID, Action: REVERT_ACTION_NONE}
+	if target.Voided {
+		result.NotRestored = append(result.NotRestored, "オーソリは取消済みのため戻せません。顧客に再度支払ってもらう必要があります")
+	}
+
+	log.Printf("INFO : Try to get order by orderId '%d' (orderNumber '%d')\n", target.OrderID, target.OrderNumber)
+	current, err := getOrderById(target.OrderID, store)
+	if err != nil {
+		return result.failed("get order", err)
+	}
+
+	if current.CancelledAt.IsZero() {
+		if current.ClosedAt.IsZero() {
+			log.Printf("INFO : orderNumber '%d' はキャンセル・クローズされていないため、再開は不要です\n", target.OrderNumber)
+			return result.succeeded()
+		}
+		result.Action = REVERT_ACTION_REOPEN
+		if !execute {
+			log.Printf("INFO : orderNumber '%d' を再開します (確認のみ)\n", target.OrderNumber)
+			return result.succeeded()
+		}
+		log.Printf("INFO : Try to reopen order by orderId '%d' (orderNumber '%d')\n", target.OrderID, target.OrderNumber)
+		err = reopenOrder(current, store)
+		if err != nil {
+			return result.failed("reopen order", err)
+		}
+		result.Executed = true
+		log.Printf("orderNumber '%d' successed to reopen.\n", target.OrderNumber)
+		return result.succeeded()
+	}
+
+	result.Action = REVERT_ACTION_DRAFT_ORDER
+	if target.Snapshot == nil {
+		result.NotRestored = append(result.NotRestored, "キャンセル前のスナップショットが無いため、オーダーを復元できません")
+		return result.failed("snapshot", fmt.Errorf("orderNumber '%d' のキャンセル前のスナップショットがありません", target.OrderNumber))
+	}
+
+	var order Order
+	err = json.Unmarshal(target.Snapshot.Order, &order)
+	if err != nil {
+		return result.failed("snapshot", err)
+	}
+	var transactions []Transaction
+	err = json.Unmarshal(target.Snapshot.Transactions, &transactions)
+	if err != nil {
+		return result.failed("snapshot", err)
+	}
+
+	draftOrder, notRestored := buildDraftOrder(&order, target)
+	result.NotRestored = append(result.NotRestored, notRestored...)
+	if !target.Voided && hasPayment(transactions) {
+		result.NotRestored = append(result.NotRestored, "支払いは下書き注文に引き継がれません。請求書を送って顧客に再度支払ってもらう必要があります")
+	}
+	if len(draftOrder.LineItems) == 0 {
+		return result.failed("build draft order", fmt.Errorf("orderNumber '%d' に復元できる商品がありません", target.OrderNumber))
+	}
+
+	if !execute {
+		log.Printf("INFO : orderNumber '%d' から下書き注文を作成します (確認のみ)。商品 %d 件\n", target.OrderNumber, len(draftOrder.LineItems))
+		return result.succeeded()
+	}
+
+	log.Printf("INFO : Try to create draft order from orderNumber '%d'\n", target.OrderNumber)
+	created, err := createDraftOrder(draftOrder, &order, store)
+	if err != nil {
+		return result.failed("create draft order", err)
+	}
+	result.Executed = true
+	result.DraftOrderID = created.ID
+	result.DraftOrderName = created.Name
+	result.InvoiceURL = created.InvoiceURL
+	log.Printf("orderNumber '%d' successed to create draft order '%s'.\n", target.OrderNumber, created.Name)
+	return result.succeeded()
+}
+
+func (r *RevertResult) succeeded() *RevertResult {
+	r.Status = output.STATUS_SUCCEEDED
+	for _, item := range r.NotRestored {
+		log.Printf("WARN : orderNumber '%d' %s\n", r.OrderNumber, item)
+	}
+	return r
+}
+
+func (r *RevertResult) failed(step string, err error) *RevertResult {
+	log.Printf("ERROR : orderNumber '%d' failed to revert. %s\n", r.OrderNumber, err.Error())
+	r.Status = output.STATUS_FAILED
+	r.Step = step
+	r.Error = err.Error()
+	return r
+}
+
+// buildDraftOrder はスナップショットのオーダーと同じ内容の下書き注文を作り、元に戻せない項目を返す。
+// 割引は合計額の値引き、配送はカスタム配送料として引き継ぐ。
+func buildDraftOrder(order *Order, target *RevertTarget) (*DraftOrder, []string) {
+	var notRestored []string
+	draftOrder := &DraftOrder{
+		Email:           order.Email,
+		ShippingAddress: order.ShippingAddress,
+		BillingAddress:  order.BillingAddress,
+		NoteAttributes:  order.NoteAttributes,
+		Note:            strings.TrimSpace(fmt.Sprintf("%s のキャンセル (%s) の取り消しで作成\n%s", order.Name, target.CancelledAt.Local().Format("2006-01-02 15:04:05"), order.Note.String())),
+		Tags:            strings.Trim(order.Tags+", "+REVERT_TAG, ", "),
+	}
+	if order.Customer != nil && order.Customer.ID != 0 {
+		draftOrder.Customer = &DraftOrderCustomer{ID: order.Customer.ID}
+	}
+
+	for _, lineItem := range order.LineItems {
+		if lineItem.FulfillableQuantity <= 0 {
+			notRestored = append(notRestored, fmt.Sprintf("%s は発送済み・返金済みのため含めません", lineItem.Name))
+			continue
+		}
+		item := DraftOrderLineItem{VariantID: lineItem.VariantID, Quantity: lineItem.FulfillableQuantity}
+		if lineItem.VariantID == 0 {
+			item.Title = lineItem.Name
+			item.Price = lineItem.Price.String()
+			notRestored = append(notRestored, fmt.Sprintf("%s は商品が削除されているため、カスタム商品として追加します", lineItem.Name))
+		}
+		draftOrder.LineItems = append(draftOrder.LineItems, item)
+	}
+
+	if len(order.ShippingLines) > 0 {
+		shippingLine := order.ShippingLines[0]
+		draftOrder.ShippingLine = &DraftOrderShippingLine{Title: shippingLine.Title, Price: shippingLine.Price.String(), Custom: true}
+		if len(order.ShippingLines) > 1 {
+			notRestored = append(notRestored, fmt.Sprintf("配送は %d 件中1件目 (%s) のみ引き継ぎます", len(order.ShippingLines), shippingLine.Title))
+		}
+	}
+
+	if order.TotalDiscounts.IsPositive() {
+		var codes []string
+		for _, discountCode := range order.DiscountCodes {
+			codes = append(codes, discountCode.Code)
+		}
+		description := strings.Join(codes, ", ")
+		if description == "" {
+			description = order.Name + " の割引"
+		}
+		draftOrder.AppliedDiscount = &DraftOrderDiscount{
+			Description: description,
+			Title:       description,
+			ValueType:   "fixed_amount",
+			Value:       order.TotalDiscounts.String(),
+			Amount:      order.TotalDiscounts.String(),
+		}
+		if len(codes) > 0 {
+			notRestored = append(notRestored, fmt.Sprintf("割引コード %s は合計額の値引き (%s) として引き継ぎます", description, order.TotalDiscounts))
+		}
+	}
+
+	return draftOrder, notRestored
+}
+
+// hasPayment は成功したオーソリ・売上の取引があるかを返す。
+func hasPayment(transactions []Transaction) bool {
+	for _, transaction := range transactions {
+		if transaction.Status == "success" && (transaction.Kind == "authorization" || transaction.Kind == "sale" || transaction.Kind == "capture") {
+			return true
+		}
+	}
+	return false
+}
+
+// getOrderById はキャンセル済み・クローズ済みを含めてオーダーを取得する。
+func getOrderById(orderId int64, store *config.Store) (*Order, error) {
+	orderUrl := restUrl(store, constants.ORDER_URL_TEMPLATE, store.Domain, store.ApiVersion, orderId)
+	orderJson, err := getRawField(orderUrl, store, "order")
+	if err != nil {
+		return nil, err
+	}
+
+	order := new(Order)
+	err = json.Unmarshal(orderJson, order)
+	if err != nil {
+		log.Println("Get order response json unmarshal err")
+		return nil, err
+	}
+	return order, nil
+}
+
+func reopenOrder(order *Order, store *config.Store) error {
+	reqJsonBytes := []byte("{}")
+	openOrderUrl := restUrl(store, constants.OPEN_ORDER_URL_TEMPLATE, store.Domain, store.ApiVersion, order.ID)
+	httpReqHeader := restHeader(store)
+	_, err := postWithAudit(openOrderUrl, reqJsonBytes, httpReqHeader, store, AUDIT_ACTION_REOPEN, order, 0)
+	return err
+}
@@ -0,0 +1,137 @@
+package shopify
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/infrastructure/util"
+)
+
+// checkSafety は設定の上限を超える実行を止める。上限は全ストアの対象を合わせて判定する。
+// maxOrderAgeDays より古いオーダーは対象から除き、件数・合計金額の上限を超える場合はエラーにする。
+// safety.Force が有効な場合は警告のみで続行する。
+func checkSafety(batches []*cancelBatch, safety *config.Safety, sink Sink) error {
+	var problems []string
+
+	if safety.MaxOrderAgeDays > 0 {
+		limit := time.Now().AddDate(0, 0, -safety.MaxOrderAgeDays)
+		for _, batch := range batches {
+			var recent []*Order
+			for _, order := range batch.orders {
+				if order.CreatedAt.Before(limit) && !safety.Force {
+					log.Printf("ERROR : orderNumber '%d' failed to cancel due to created at %s is older than %d days. (-force で実行できます)\n", order.OrderNumber, order.CreatedAt.Local().Format("2006-01-02"), safety.MaxOrderAgeDays)
+					newOrderResult(sink, batch.store, order.OrderNumber, order).skipped("safety", fmt.Errorf("created at %s is older than %d days", order.CreatedAt.Local().Format("2006-01-02"), safety.MaxOrderAgeDays))
+					batch.isSuccess = false
+					continue
+				}
+				recent = append(recent, order)
+			}
+			batch.orders = recent
+		}
+	}
+
+	orders := batchOrders(batches)
+	if safety.MaxOrdersPerRun > 0 && len(orders) > safety.MaxOrdersPerRun {
+		problems = append(problems, fmt.Sprintf("対象オーダー数 %d が上限 maxOrdersPerRun %d を超えています", len(orders), safety.MaxOrdersPerRun))
+	}
+
+	if safety.MaxTotalAmount > 0 {
+		limit := DecimalFromInt(safety.MaxTotalAmount)
+		totals := totalByCurrency(orders)
+		for _, currency := range sortedCurrencies(totals) {
+			if totals[currency].Cmp(limit) > 0 {
+				problems = append(problems, fmt.Sprintf("合計金額 %s %s が上限 maxTotalAmount %d を超えています", totals[currency], currency, safety.MaxTotalAmount))
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	if safety.Force {
+		for _, problem := range problems {
+			log.Printf("WARN : %s (-force のため続行します)\n", problem)
+		}
+		return nil
+	}
+	return fmt.Errorf("安全上限を超えるため中止しました。内容を確認の上 -force で実行できます\n  %s", strings.Join(problems, "\n  "))
+}
+
+// confirmCancel は実行内容の概要をストア毎と全体で表示し、対話実行の場合は全ストアの件数の入力で1回だけ確認を取る。
+func confirmCancel(batches []*cancelBatch, safety *config.Safety) bool {
+	for _, batch := range batches {
+		logCancelSummary(fmt.Sprintf("store '%s' (%s)", batch.store.Name, batch.store.Domain), batch.orders)
+	}
+	orders := batchOrders(batches)
+	if len(batches) > 1 {
+		logCancelSummary(fmt.Sprintf("%d stores", len(batches)), orders)
+	}
+
+	testCount := countTestOrders(orders)
+	if testCount > 0 && testCount < len(orders) {
+		log.Printf("WARN : テストオーダーと本番オーダーが混在しています\n")
+	}
+
+	if len(orders) == 0 || safety.AssumeYes || !util.IsTerminal() {
+		return true
+	}
+
+	answer := util.Prompt(fmt.Sprintf("オーソリキャンセル・キャンセルを実行する場合は対象件数 %d を入力してください : ", len(orders)))
+	if answer != strconv.Itoa(len(orders)) {
+		log.Printf("INFO : 入力 '%s' が件数と一致しないためキャンセルを中止します\n", answer)
+		return false
+	}
+	return true
+}
+
+func logCancelSummary(target string, orders []*Order) {
+	testCount := countTestOrders(orders)
+
+	var totals []string
+	totalMap := totalByCurrency(orders)
+	for _, currency := range sortedCurrencies(totalMap) {
+		totals = append(totals, fmt.Sprintf("%s %s", totalMap[currency], currency))
+	}
+
+	log.Printf("INFO : %s : %d orders (live %d / test %d), total %s\n", target, len(orders), len(orders)-testCount, testCount, strings.Join(totals, ", "))
+}
+
+func countTestOrders(orders []*Order) int {
+	testCount := 0
+	for _, order := range orders {
+		if order.Test {
+			testCount++
+		}
+	}
+	return testCount
+}
+
+func batchOrders(batches []*cancelBatch) []*Order {
+	var orders []*Order
+	for _, batch := range batches {
+		orders = append(orders, batch.orders...)
+	}
+	return orders
+}
+
+func totalByCurrency(orders []*Order) map[string]Decimal {
+	totals := map[string]Decimal{}
+	for _, order := range orders {
+		totals[order.Currency] = totals[order.Currency].Add(order.TotalPrice)
+	}
+	return totals
+}
+
+func sortedCurrencies(totals map[string]Decimal) []string {
+	var currencies []string
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
@@ -0,0 +1,225 @@
+package shopify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/infrastructure/http"
+)
+
+const SEARCH_PAGE_LIMIT = 250
+
+const QUERY_DATE_LAYOUT = "2006-01-02"
+
+// OrderQuery はオーダー検索条件。
+// status / financial_status / created_at は API 側で絞り込み、
+// それ以外は取得後に絞り込む。
+type OrderQuery struct {
+	Status          string
+	FinancialStatus string
+	CreatedAtMin    time.Time
+	CreatedAtMax    time.Time
+	Email           string
+	Phone           string
+	Tag             string
+	Gateway         string
+	Test            *bool
+}
+
+// ParseOrderQuery は "financial_status=authorized test=true created=yesterday" の様な
+// スペース区切りの key=value 形式の検索条件を解析する。
+func ParseOrderQuery(query string) (*OrderQuery, error) {
+	orderQuery := &OrderQuery{Status: "any"}
+	for _, term := range strings.Fields(query) {
+		kv := strings.SplitN(term, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("検索条件 '%s' は key=value の形式で指定してください", term)
+		}
+		key, value := strings.ToLower(kv[0]), kv[1]
+
+		switch key {
+		case "status":
+			orderQuery.Status = value
+		case "financial_status":
+			orderQuery.FinancialStatus = value
+		case "email":
+			orderQuery.Email = value
+		case "phone":
+			orderQuery.Phone = value
+		case "tag":
+			orderQuery.Tag = value
+		case "gateway":
+			orderQuery.Gateway = value
+		case "test":
+			test, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("検索条件 test は true / false で指定してください : %s", value)
+			}
+			orderQuery.Test = &test
+		case "created":
+			day, err := parseQueryDate(value)
+			if err != nil {
+				return nil, err
+			}
+			orderQuery.CreatedAtMin = day
+			orderQuery.CreatedAtMax = day.AddDate(0, 0, 1).Add(-time.Second)
+		case "created_at_min":
+			day, err := parseQueryDate(value)
+			if err != nil {
+				return nil, err
+			}
+			orderQuery.CreatedAtMin = day
+		case "created_at_max":
+			day, err := parseQueryDate(value)
+			if err != nil {
+				return nil, err
+			}
+			orderQuery.CreatedAtMax = day.AddDate(0, 0, 1).Add(-time.Second)
+		default:
+			return nil, fmt.Errorf("未対応の検索条件です : %s", key)
+		}
+	}
+
+	return orderQuery, nil
+}
+
+// parseQueryDate は today / yesterday / Nd (N 日前) / YYYY-MM-DD をローカル時刻の 0 時に変換する。
+// Nd はスケジュール実行で「6日より前のオーダー」(created_at_max=7d) の様に使う。
+func parseQueryDate(value string) (time.Time, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch strings.ToLower(value) {
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	if days, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(value), "d")); err == nil && strings.HasSuffix(strings.ToLower(value), "d") && days >= 0 {
+		return today.AddDate(0, 0, -days), nil
+	}
+
+	day, err := time.ParseInLocation(QUERY_DATE_LAYOUT, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("日付 '%s' は today / yesterday / Nd (N 日前) / YYYY-MM-DD で指定してください", value)
+	}
+	return day, nil
+}
+
+// searchOrders は条件に一致するオーダーを REST API で全件取得する。
+// ページングは since_id で行う。
+func searchOrders(query *OrderQuery, store *config.Store) ([]Order, error) {
+	searchOrdersUrl := restUrl(store, constants.SEARCH_ORDERS_URL_TEMPLATE, store.Domain, store.ApiVersion)
+	httpReqHeader := restHeader(store)
+
+	var result []Order
+	var sinceId int64
+	for {
+		queryParam := map[string]string{}
+		queryParam["limit"] = strconv.Itoa(SEARCH_PAGE_LIMIT)
+		queryParam["since_id"] = strconv.FormatInt(sinceId, 10)
+		queryParam["status"] = query.Status
+		if query.FinancialStatus != "" {
+			queryParam["financial_status"] = query.FinancialStatus
+		}
+		if !query.CreatedAtMin.IsZero() {
+			queryParam["created_at_min"] = query.CreatedAtMin.Format(time.RFC3339)
+		}
+		if !query.CreatedAtMax.IsZero() {
+			queryParam["created_at_max"] = query.CreatedAtMax.Format(time.RFC3339)
+		}
+
+		jsonRes, err := http.Get(searchOrdersUrl, httpReqHeader, queryParam)
+		if err != nil {
+			return nil, err
+		}
+
+		page := new(GetOrdersResponse)
+		err = json.Unmarshal(jsonRes, &page)
+		if err != nil {
+			log.Println("Search orders response json unmarshal err")
+			return nil, err
+		}
+
+		for i := range page.Orders {
+			if page.Orders[i].ID > sinceId {
+				sinceId = page.Orders[i].ID
+			}
+			if orderMatchesQuery(&page.Orders[i], query) {
+				result = append(result, page.Orders[i])
+			}
+		}
+
+		if len(page.Orders) < SEARCH_PAGE_LIMIT {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// SearchOrderNumberList は検索条件に一致するオーダー番号の一覧を作る。
+func SearchOrderNumberList(query string, store *config.Store) ([]int, error) {
+	orderQuery, err := ParseOrderQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := NewClient(store)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("INFO : Search orders by query '%s'\n", query)
+	orders, err := client.SearchOrders(orderQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	var orderNumberList []int
+	for _, order := range orders {
+		orderNumberList = append(orderNumberList, order.OrderNumber)
+	}
+	log.Printf("INFO : %d orders matched by query '%s'\n", len(orderNumberList), query)
+
+	return orderNumberList, nil
+}
+
+// orderMatchesQuery は API 側で絞り込めない条件を判定する。
+func orderMatchesQuery(order *Order, query *OrderQuery) bool {
+	if query.Email != "" && !strings.EqualFold(order.Email, query.Email) {
+		return false
+	}
+	if query.Phone != "" && normalizePhone(order.Phone) != normalizePhone(query.Phone) {
+		return false
+	}
+	if query.Gateway != "" && !strings.EqualFold(order.Gateway, query.Gateway) {
+		return false
+	}
+	if query.Test != nil && order.Test != *query.Test {
+		return false
+	}
+	if query.Tag != "" && !order.HasTag(query.Tag) {
+		return false
+	}
+	return true
+}
+
+func normalizePhone(phone string) string {
+	return onlyDigits(phone)
+}
+
+func onlyDigits(value string) string {
+	var digits []rune
+	for _, r := range value {
+		if '0' <= r && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+	return string(digits)
+}
@@ -0,0 +1,51 @@
+package shopify
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseOrderQuery(t *testing.T) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	endOf := func(day time.Time) time.Time { return day.AddDate(0, 0, 1).Add(-time.Second) }
+	yes, no := true, false
+
+	tests := []struct {
+		query   string
+		want    OrderQuery
+		wantErr bool
+	}{
+		{query: "", want: OrderQuery{Status: "any"}},
+		{
+			query: "financial_status=authorized test=true created=yesterday",
+			want:  OrderQuery{Status: "any", FinancialStatus: "authorized", Test: &yes, CreatedAtMin: today.AddDate(0, 0, -1), CreatedAtMax: endOf(today.AddDate(0, 0, -1))},
+		},
+		{
+			query: "Status=open email=a@example.com phone=090-1234-5678 tag=lottery gateway=shopify_payments test=false",
+			want:  OrderQuery{Status: "open", Email: "a@example.com", Phone: "090-1234-5678", Tag: "lottery", Gateway: "shopify_payments", Test: &no},
+		},
+		{
+			query: "created_at_min=2026-10-01 created_at_max=7d",
+			want:  OrderQuery{Status: "any", CreatedAtMin: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local), CreatedAtMax: endOf(today.AddDate(0, 0, -7))},
+		},
+		{query: "created=today", want: OrderQuery{Status: "any", CreatedAtMin: today, CreatedAtMax: endOf(today)}},
+		{query: "financial_status", wantErr: true},
+		{query: "tag=", wantErr: true},
+		{query: "test=maybe", wantErr: true},
+		{query: "created=2026/10/01", wantErr: true},
+		{query: "created=-1d", wantErr: true},
+		{query: "unknown=1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseOrderQuery(tt.query)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseOrderQuery(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("ParseOrderQuery(%q) = %+v, want %+v", tt.query, *got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package shopify
+
+import (
+	"encoding/json"
+	"log"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/infrastructure/http"
+)
+
+type Shop struct {
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	Email           string `json:"email"`
+	MyshopifyDomain string `json:"myshopify_domain"`
+	PlanName        string `json:"plan_name"`
+	Currency        string `json:"currency"`
+	IanaTimezone    string `json:"iana_timezone"`
+}
+
+type GetShopResponse struct {
+	Shop Shop `json:"shop"`
+}
+
+type GetAccessScopesResponse struct {
+	AccessScopes []struct {
+		Handle string `json:"handle"`
+	} `json:"access_scopes"`
+}
+
+func getShop(store *config.Store) (*Shop, error) {
+
+	getShopUrl := restUrl(store, constants.SHOP_URL_TEMPLATE, store.Domain, store.ApiVersion)
+	httpReqHeader := restHeader(store)
+	jsonRes, err := http.Get(getShopUrl, httpReqHeader, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	getShopRes := new(GetShopResponse)
+	err = json.Unmarshal(jsonRes, &getShopRes)
+	if err != nil {
+		log.Println("Get shop response json unmarshal err")
+		return nil, err
+	}
+
+	return &getShopRes.Shop, nil
+}
+
+func getAccessScopes(store *config.Store) ([]string, error) {
+
+	getAccessScopesUrl := restUrl(store, constants.ACCESS_SCOPES_URL_TEMPLATE, store.Domain)
+	httpReqHeader := restHeader(store)
+	jsonRes, err := http.Get(getAccessScopesUrl, httpReqHeader, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	getAccessScopesRes := new(GetAccessScopesResponse)
+	err = json.Unmarshal(jsonRes, &getAccessScopesRes)
+	if err != nil {
+		log.Println("Get access scopes response json unmarshal err")
+		return nil, err
+	}
+
+	var scopes []string
+	for _, scope := range getAccessScopesRes.AccessScopes {
+		scopes = append(scopes, scope.Handle)
+	}
+	return scopes, nil
+}
@@ -0,0 +1,72 @@
+package shopify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/infrastructure/http"
+	"shopify-manager/pkg/snapshot"
+)
+
+// takeSnapshot はオーダーを変更する前に、オーダーと取引の JSON をそのまま archive に保存する。
+// 取得した時点の状態を残すため、検索結果ではなくオーダー ID で取得し直す。
+func takeSnapshot(archive *snapshot.Archive, order *Order, store *config.Store) error {
+	if archive == nil {
+		return nil
+	}
+
+	orderUrl := restUrl(store, constants.ORDER_URL_TEMPLATE, store.Domain, store.ApiVersion, order.ID)
+	orderJson, err := getRawField(orderUrl, store, "order")
+	if err != nil {
+		return err
+	}
+
+	transactionsUrl := restUrl(store, constants.TRANSACTIONS_URL_TEMPLATE, store.Domain, store.ApiVersion, order.ID)
+	transactionsJson, err := getRawField(transactionsUrl, store, "transactions")
+	if err != nil {
+		return err
+	}
+
+	return archive.Save(&snapshot.Snapshot{
+		Store:        store.Name,
+		OrderNumber:  order.OrderNumber,
+		OrderID:      order.ID,
+		TakenAt:      time.Now(),
+		Order:        orderJson,
+		Transactions: transactionsJson,
+	})
+}
+
+// closeSnapshots はアーカイブを閉じる。閉じられなくても保存済みの分は読めるため、ログのみ出す。
+func closeSnapshots(archive *snapshot.Archive) {
+	err := archive.Close()
+	if err != nil {
+		log.Printf("ERROR : スナップショット %s を閉じられませんでした。%s\n", archive.Path(), err.Error())
+	}
+}
+
+// getRawField はレスポンスの JSON の field の値を加工せずに返す。
+func getRawField(url string, store *config.Store, field string) (json.RawMessage, error) {
+	httpReqHeader := restHeader(store)
+	jsonRes, err := http.Get(url, httpReqHeader, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(jsonRes, &fields)
+	if err != nil {
+		log.Printf("Get %s response json unmarshal err\n", field)
+		return nil, err
+	}
+
+	value, ok := fields[field]
+	if !ok {
+		return nil, fmt.Errorf("レスポンスに %s がありません", field)
+	}
+	return value, nil
+}
@@ -0,0 +1,234 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"shopify-manager/pkg/storage"
+)
+
+const AUDIT_FILE_PATH = "./audit.jsonl"
+
+// Entry は Shopify へ変更を伴う API を呼び出した1回分の記録。
+// PrevHash に直前の記録の Hash を持たせて鎖状にし、途中の記録の書き換え・削除・並べ替えを検出できるようにする。
+// ハッシュには鍵が無いため、末尾の記録の削除や、全ての記録のハッシュを計算し直した書き換えは検出できない。
+// これらを検出するには audit-report が表示する最終の seq と hash を別の場所に控えておき、後で照合する。
+type Entry struct {
+	Seq           int64     `json:"seq"`
+	Time          time.Time `json:"time"`
+	User          string    `json:"user"`
+	Host          string    `json:"host"`
+	Store         string    `json:"store"`
+	Action        string    `json:"action"`
+	OrderID       int64     `json:"order_id"`
+	OrderNumber   int       `json:"order_number"`
+	TransactionID int64     `json:"transaction_id,omitempty"`
+	DraftOrderID  int64     `json:"draft_order_id,omitempty"`
+	RequestBody   string    `json:"request_body"`
+	Status        int       `json:"status"`
+	RequestID     string    `json:"request_id"`
+	Error         string    `json:"error,omitempty"`
+	PrevHash      string    `json:"prev_hash"`
+	Hash          string    `json:"hash"`
+}
+
+// Log は1つの監査ファイルへの書き手。
+// serve・api・schedule など別のプロセスも同じファイルに追記するため、最後の連番とハッシュは保持せず、
+// 追記する度に保存先のロックを取ってファイルの末尾から読み直す。
+type Log struct {
+	name     string
+	mutex    sync.Mutex
+	writable bool
+
+	// 追記に失敗した後は監査ファイルの末尾が壊れている可能性があるため、以降の記録を全て失敗にする
+	failure error
+}
+
+func New(name string) *Log {
+	return &Log{name: name}
+}
+
+var defaultLog = New(AUDIT_FILE_PATH)
+
+// Check は AUDIT_FILE_PATH の Log.Check。
+func Check() error {
+	return defaultLog.Check()
+}
+
+// Record は AUDIT_FILE_PATH の Log.Record。
+func Record(entry Entry) error {
+	return defaultLog.Record(entry)
+}
+
+// Check は監査ファイルを読み込めて追記できることを確認する。追記の確認は最初の1回だけ行う。
+// 変更を伴う API を呼び出す前に呼び、エラーの場合は変更を行わない。
+func (l *Log) Check() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.failure != nil {
+		return l.failure
+	}
+	_, err := l.last()
+	if err != nil {
+		return err
+	}
+
+	if !l.writable {
+		err = storage.AppendFile(l.name, nil)
+		if err != nil {
+			return err
+		}
+		l.writable = true
+	}
+	return nil
+}
+
+// Record は entry に実行者・時刻・連番・ハッシュを設定して監査ファイルに追記する。
+func (l *Log) Record(entry Entry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.failure != nil {
+		return l.failure
+	}
+
+	unlock, err := storage.Lock(l.name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	last, err := l.last()
+	if err != nil {
+		return err
+	}
+
+	entry.Seq = last.Seq + 1
+	entry.Time = time.Now()
+	entry.User = currentUser()
+	entry.Host, _ = os.Hostname()
+	entry.PrevHash = last.Hash
+	entry.Hash = ""
+	hash, err := hashEntry(entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	err = storage.AppendFile(l.name, append(line, '\n'))
+	if err != nil {
+		l.failure = fmt.Errorf("%s への追記に失敗しました : %s", storage.Location(l.name), err.Error())
+		return l.failure
+	}
+	return nil
+}
+
+// last は監査ファイルの最後の記録を読み込む。ファイルが無い場合は空の記録を返し、読み込めない場合は記録を続けない。
+func (l *Log) last() (Entry, error) {
+	var entry Entry
+	data, err := storage.ReadFile(l.name)
+	if errors.Is(err, os.ErrNotExist) {
+		return entry, nil
+	}
+	if err != nil {
+		return entry, err
+	}
+
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return entry, nil
+	}
+	line := data[bytes.LastIndexByte(data, '\n')+1:]
+	err = json.Unmarshal(line, &entry)
+	if err != nil {
+		return entry, fmt.Errorf("%s の最後の行を読み込めません : %s", storage.Location(l.name), err.Error())
+	}
+	return entry, nil
+}
+
+// Read は保存先の監査ファイルの記録を全て読み込む。
+func Read(path string) ([]Entry, error) {
+	data, err := storage.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s の%d行目を読み込めません : %s", storage.Location(path), lineNum, err.Error())
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Verify はハッシュの鎖を検証し、改ざん・削除・並べ替えを検出した最初の記録をエラーにする。
+func Verify(entries []Entry) error {
+	prevHash := ""
+	var prevSeq int64
+	for _, entry := range entries {
+		if entry.Seq != prevSeq+1 {
+			return fmt.Errorf("seq %d : 連番が不連続です (直前 %d)。記録が削除された可能性があります", entry.Seq, prevSeq)
+		}
+		if entry.PrevHash != prevHash {
+			return fmt.Errorf("seq %d : prev_hash が直前の記録と一致しません", entry.Seq)
+		}
+
+		hash := entry.Hash
+		entry.Hash = ""
+		expected, err := hashEntry(entry)
+		if err != nil {
+			return err
+		}
+		if hash != expected {
+			return fmt.Errorf("seq %d : hash が一致しません。記録が改ざんされた可能性があります", entry.Seq)
+		}
+
+		prevHash = hash
+		prevSeq = entry.Seq
+	}
+	return nil
+}
+
+func hashEntry(entry Entry) (string, error) {
+	jsonBytes, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(jsonBytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USERNAME"); name != "" {
+		return name
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/storage"
+)
+
+func TestRecordAndVerify(t *testing.T) {
+	memory := storage.NewMemory()
+	storage.Use(memory)
+	defer storage.Use(storage.NewLocal(config.DEFAULT_STORAGE_DIR))
+
+	if err := Check(); err != nil {
+		t.Fatal(err)
+	}
+	for _, orderNumber := range []int{1001, 1002, 1003} {
+		if err := Record(Entry{Store: "jp", Action: "cancel", OrderNumber: orderNumber, Status: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := Read(AUDIT_FILE_PATH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[2].Seq != 3 || entries[2].PrevHash != entries[1].Hash {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if err := Verify(entries); err != nil {
+		t.Errorf("Verify = %v", err)
+	}
+
+	data, _ := memory.Read(AUDIT_FILE_PATH)
+	tests := []struct {
+		name   string
+		modify func(entries []Entry) []Entry
+	}{
+		{name: "edited", modify: func(entries []Entry) []Entry { entries[1].OrderNumber = 9999; return entries }},
+		{name: "deleted", modify: func(entries []Entry) []Entry { return append(entries[:1], entries[2:]...) }},
+		{name: "reordered", modify: func(entries []Entry) []Entry {
+			entries[1], entries[2] = entries[2], entries[1]
+			return entries
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory.Write(AUDIT_FILE_PATH, data)
+			entries, err := Read(AUDIT_FILE_PATH)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Verify(tt.modify(entries)); err == nil {
+				t.Errorf("Verify should detect %s entry", tt.name)
+			}
+		})
+	}
+
+	if err := memory.Write(AUDIT_FILE_PATH, append(bytes.TrimSpace(data), []byte("\n{broken")...)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(AUDIT_FILE_PATH); err == nil {
+		t.Errorf("Read should fail on a broken line")
+	}
+}
+
+// 別々のプロセスの書き手を、ロックを共有しない2つの Log で再現する
+func TestRecordTwoWriters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	storage.Use(storage.NewLocal(dir))
+	defer storage.Use(storage.NewLocal(config.DEFAULT_STORAGE_DIR))
+
+	writers := []*Log{New(AUDIT_FILE_PATH), New(AUDIT_FILE_PATH)}
+	for _, writer := range writers {
+		if err := writer.Check(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const count = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, len(writers)*count)
+	for i, writer := range writers {
+		wg.Add(1)
+		go func(store string, writer *Log) {
+			defer wg.Done()
+			for orderNumber := 1; orderNumber <= count; orderNumber++ {
+				if err := writer.Record(Entry{Store: store, Action: "cancel", OrderNumber: orderNumber}); err != nil {
+					errs <- err
+				}
+			}
+		}(string(rune('a'+i)), writer)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	entries, err := Read(AUDIT_FILE_PATH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(writers)*count {
+		t.Fatalf("len(entries) = %d, want %d", len(entries), len(writers)*count)
+	}
+	if err := Verify(entries); err != nil {
+		t.Errorf("Verify = %v", err)
+	}
+}
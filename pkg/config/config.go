@@ -1,34 +1,447 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
-	ApiInfo ApiInfo
-	Thread  Thread
+	DefaultStore   string           `toml:"defaultStore"`
+	ApiInfo        ApiInfo          `toml:"ApiInfo"`
+	Stores         map[string]Store `toml:"Stores"`
+	Thread         Thread           `toml:"Thread"`
+	AutoCancel     AutoCancel       `toml:"AutoCancel"`
+	Safety         Safety           `toml:"Safety"`
+	Serve          Serve            `toml:"Serve"`
+	LocalApi       LocalApi         `toml:"LocalApi"`
+	Schedule       Schedule         `toml:"Schedule"`
+	Notifiers      []Notifier       `toml:"Notifiers"`
+	CustomerNotice CustomerNotice   `toml:"CustomerNotice"`
+	Restock        Restock          `toml:"Restock"`
+	Snapshot       Snapshot         `toml:"Snapshot"`
+	Lottery        Lottery          `toml:"Lottery"`
+	DuplicateCheck DuplicateCheck   `toml:"DuplicateCheck"`
+	Storage        Storage          `toml:"Storage"`
 }
 
 type ApiInfo struct {
 	ApiKey      string `toml:"apiKey"`
 	ApiPassword string `toml:"apiPassword"`
+	Backend     string `toml:"backend"`
+}
+
+// Store は接続先ストア毎の設定。
+// 未指定の項目は既定値、threadNum は [Thread]、restock は [Restock] の値を使う。
+type Store struct {
+	Name              string `toml:"-"`
+	Domain            string `toml:"domain"`
+	ApiVersion        string `toml:"apiVersion"`
+	GraphqlApiVersion string `toml:"graphqlApiVersion"`
+	ApiKey            string `toml:"apiKey"`
+	ApiPassword       string `toml:"apiPassword"`
+	Backend           string `toml:"backend"`
+	Currency          string `toml:"currency"`
+	ThreadNum         int    `toml:"threadNum"`
+	WebhookSecret     string `toml:"webhookSecret"`
+	Restock           string `toml:"restock"`
+	RestockLocationId int64  `toml:"restockLocationId"`
 }
 
 type Thread struct {
 	ThreadNum int `toml:"threadNum"`
 }
 
+// Safety はオーソリキャンセル・キャンセル実行時の安全上限。0 は無制限。
+// Force / AssumeYes は設定ファイルではなく起動引数で指定する。
+type Safety struct {
+	MaxOrdersPerRun int   `toml:"maxOrdersPerRun"`
+	MaxTotalAmount  int64 `toml:"maxTotalAmount"`
+	MaxOrderAgeDays int   `toml:"maxOrderAgeDays"`
+	Force           bool  `toml:"-"`
+	AssumeYes       bool  `toml:"-"`
+}
+
+type AutoCancel struct {
+	ScanHours int    `toml:"scanHours"`
+	Execute   bool   `toml:"execute"`
+	Rules     []Rule `toml:"Rules"`
+}
+
+// Serve は serve フロー (Webhook 受信サーバー) の設定。
+type Serve struct {
+	Listen       string          `toml:"listen"`
+	EventLogPath string          `toml:"eventLogPath"`
+	Actions      []WebhookAction `toml:"Actions"`
+}
+
+// LocalApi はフローを HTTP で実行するローカル API (api フロー) の設定。
+// token はシークレット参照 (env: / file: / keyring:) で指定できる。
+type LocalApi struct {
+	Listen string `toml:"listen"`
+	Token  string `toml:"token"`
+}
+
+// Schedule は schedule フロー (定期実行) の設定。
+type Schedule struct {
+	HistoryPath string        `toml:"historyPath"`
+	Jobs        []ScheduleJob `toml:"Jobs"`
+}
+
+// ScheduleJob は cron 形式 (分 時 日 月 曜日) で定期実行するフロー。
+// store / query / execute はフローの -store / -query / -execute と同じ。
+type ScheduleJob struct {
+	Name    string `toml:"name"`
+	Cron    string `toml:"cron"`
+	Flow    string `toml:"flow"`
+	Store   string `toml:"store"`
+	Query   string `toml:"query"`
+	Execute bool   `toml:"execute"`
+}
+
+// Notifier はフロー終了時の通知先。type 毎に使う項目が異なる。
+//   - webhook : url (Slack の Incoming Webhook 互換の JSON を POST する)
+//   - smtp : host / port / username / password / from / to
+//   - file : dir (結果の JSON ファイルを置く)
+//
+// url / password はシークレット参照 (env: / file: / keyring:) で指定できる。
+// on は always (既定) または failure (失敗時のみ)。
+type Notifier struct {
+	Type     string   `toml:"type"`
+	On       string   `toml:"on"`
+	Url      string   `toml:"url"`
+	Host     string   `toml:"host"`
+	Port     int      `toml:"port"`
+	Username string   `toml:"username"`
+	Password string   `toml:"password"`
+	From     string   `toml:"from"`
+	To       []string `toml:"to"`
+	Dir      string   `toml:"dir"`
+}
+
+// CustomerNotice はキャンセルしたオーダーの顧客に送る独自のお知らせメールの設定。
+// テンプレートは templateDir の cancel.<locale>.txt (必須) と cancel.<locale>.html (任意)。
+// sink が smtp の場合は host / port / username / password で送信し、file の場合は fileDir に .eml を置く。
+type CustomerNotice struct {
+	Enabled       bool     `toml:"enabled"`
+	TemplateDir   string   `toml:"templateDir"`
+	DefaultLocale string   `toml:"defaultLocale"`
+	Sink          string   `toml:"sink"`
+	Host          string   `toml:"host"`
+	Port          int      `toml:"port"`
+	Username      string   `toml:"username"`
+	Password      string   `toml:"password"`
+	From          string   `toml:"from"`
+	Bcc           []string `toml:"bcc"`
+	FileDir       string   `toml:"fileDir"`
+}
+
+// Restock はオーダーのキャンセル後に在庫が戻ったかの確認の既定値。
+// mode が verify の場合は確認して戻っていない商品を報告し、adjust の場合は不足分を在庫に加える。
+type Restock struct {
+	Mode string `toml:"mode"`
+}
+
+// Snapshot はオーダーを変更する前に保存する元の JSON (オーダーと取引) の保存先。
+// format が dir の場合は dir/<store>/<開始日時>/<orderNumber>.json、tar.gz の場合は実行毎に
+// dir/<store>/<開始日時>.tar.gz にまとめる。off の場合は保存しない。
+type Snapshot struct {
+	Dir    string `toml:"dir"`
+	Format string `toml:"format"`
+}
+
+// Lottery は lottery フロー (抽選販売の当選者選び) の設定。
+// 対象は tag・productId・sku で絞り込んだオーソリ済みのオーダーで、seed が同じなら同じ結果になる (0 は実行毎に変わる)。
+// unique が customer の場合は1顧客、address の場合は1配送先につき1件まで当選にする。
+// excludeTags は顧客タグ・オーダータグ、excludeEmails はメールアドレスで応募を除外する。
+type Lottery struct {
+	Tag           string   `toml:"tag"`
+	ProductId     int64    `toml:"productId"`
+	Sku           string   `toml:"sku"`
+	Winners       int      `toml:"winners"`
+	Seed          int64    `toml:"seed"`
+	Unique        string   `toml:"unique"`
+	ExcludeTags   []string `toml:"excludeTags"`
+	ExcludeEmails []string `toml:"excludeEmails"`
+}
+
+// DuplicateCheck は detect-duplicates フロー (複数アカウントの同一購入者の検出) の設定。
+// keys の項目のいずれかが一致するオーダーをつなぎ、アカウントが minAccounts 以上のまとまりを報告する。
+type DuplicateCheck struct {
+	Keys        []string `toml:"keys"`
+	MinAccounts int      `toml:"minAccounts"`
+}
+
+// Storage は入力エクセルの読み込みとレポート (エクセル) の書き出し先。
+// type が local の場合は dir 以下、s3 の場合は S3 互換ストレージの bucket の prefix 以下に読み書きする。
+// endpoint を省略すると AWS S3、MinIO などは endpoint と pathStyle = true を指定する。
+// accessKey / secretKey はシークレット参照 (env: / file: / keyring:) で指定できる。
+type Storage struct {
+	Type      string `toml:"type"`
+	Dir       string `toml:"dir"`
+	Endpoint  string `toml:"endpoint"`
+	Region    string `toml:"region"`
+	Bucket    string `toml:"bucket"`
+	Prefix    string `toml:"prefix"`
+	AccessKey string `toml:"accessKey"`
+	SecretKey string `toml:"secretKey"`
+	PathStyle bool   `toml:"pathStyle"`
+}
+
+// WebhookAction は受信した Webhook のトピックに対して実行する処理。
+type WebhookAction struct {
+	Topics []string `toml:"topics"`
+	Action string   `toml:"action"`
+}
+
+// Rule は不正・テストオーダー判定ルール。
+// 指定された条件を全て満たすオーダーが一致となる。
+type Rule struct {
+	Name                 string   `toml:"name"`
+	Test                 *bool    `toml:"test"`
+	BrowserIPs           []string `toml:"browserIPs"`
+	EmailDomains         []string `toml:"emailDomains"`
+	CvvResultCodes       []string `toml:"cvvResultCodes"`
+	AvsResultCodes       []string `toml:"avsResultCodes"`
+	MinTotalPrice        float64  `toml:"minTotalPrice"`
+	MaxOrdersPerCustomer int      `toml:"maxOrdersPerCustomer"`
+	WindowHours          int      `toml:"windowHours"`
+}
+
 const CONFIG_FILE_PATH = "./config.toml"
 
-func LoadConfig() (*Config, error) {
+// [ApiInfo] のみの旧形式の設定で使うストア
+const LEGACY_STORE_NAME = "default"
+const DEFAULT_STORE_DOMAIN = "penguin-auto-buy-service.myshopify.com"
+const DEFAULT_API_VERSION = "2020-07"
+const DEFAULT_GRAPHQL_API_VERSION = "2024-01"
+const DEFAULT_CURRENCY = "JPY"
+
+// 環境変数による上書き。 <STORE> は大文字にしたストア名 (英数字以外は _)
+const ENV_DEFAULT_STORE = "SHOPIFY_DEFAULT_STORE"
+const ENV_STORE_DOMAIN_TEMPLATE = "SHOPIFY_%s_DOMAIN"
+const ENV_STORE_API_KEY_TEMPLATE = "SHOPIFY_%s_API_KEY"
+const ENV_STORE_API_PASSWORD_TEMPLATE = "SHOPIFY_%s_API_PASSWORD"
+const ENV_STORE_WEBHOOK_SECRET_TEMPLATE = "SHOPIFY_%s_WEBHOOK_SECRET"
+
+const DEFAULT_SERVE_LISTEN = ":8080"
+const DEFAULT_EVENT_LOG_PATH = "./webhook-events.jsonl"
+const DEFAULT_LOCAL_API_LISTEN = "127.0.0.1:8081"
+const DEFAULT_SCHEDULE_HISTORY_PATH = "./schedule-history.jsonl"
+
+const NOTIFIER_TYPE_WEBHOOK = "webhook"
+const NOTIFIER_TYPE_SMTP = "smtp"
+const NOTIFIER_TYPE_FILE = "file"
+const NOTIFY_ON_ALWAYS = "always"
+const NOTIFY_ON_FAILURE = "failure"
+const DEFAULT_SMTP_PORT = 587
+
+const NOTICE_SINK_SMTP = "smtp"
+const NOTICE_SINK_FILE = "file"
+const DEFAULT_NOTICE_TEMPLATE_DIR = "./templates"
+const DEFAULT_NOTICE_LOCALE = "ja"
+const DEFAULT_NOTICE_FILE_DIR = "./customer-notices"
+
+const RESTOCK_MODE_OFF = "off"
+const RESTOCK_MODE_VERIFY = "verify"
+const RESTOCK_MODE_ADJUST = "adjust"
+
+const SNAPSHOT_FORMAT_DIR = "dir"
+const SNAPSHOT_FORMAT_TAR_GZ = "tar.gz"
+const SNAPSHOT_FORMAT_OFF = "off"
+
+const LOTTERY_UNIQUE_CUSTOMER = "customer"
+const LOTTERY_UNIQUE_ADDRESS = "address"
+const LOTTERY_UNIQUE_NONE = "none"
+
+// [DuplicateCheck] keys に指定できる項目
+const DUPLICATE_KEY_ADDRESS = "address"
+const DUPLICATE_KEY_PHONE = "phone"
+const DUPLICATE_KEY_EMAIL = "email"
+const DUPLICATE_KEY_IP = "ip"
+const DUPLICATE_KEY_CARD = "card"
+
+var DUPLICATE_KEYS = []string{DUPLICATE_KEY_ADDRESS, DUPLICATE_KEY_PHONE, DUPLICATE_KEY_EMAIL, DUPLICATE_KEY_IP, DUPLICATE_KEY_CARD}
+
+// DEFAULT_DUPLICATE_KEYS は keys 未指定時の項目。
+// ip は共有回線で別人が一致し、まとまりが連鎖して広がるため既定には含めない。
+var DEFAULT_DUPLICATE_KEYS = []string{DUPLICATE_KEY_ADDRESS, DUPLICATE_KEY_PHONE, DUPLICATE_KEY_EMAIL, DUPLICATE_KEY_CARD}
+
+const DEFAULT_DUPLICATE_MIN_ACCOUNTS = 2
+
+const STORAGE_TYPE_LOCAL = "local"
+const STORAGE_TYPE_S3 = "s3"
+const STORAGE_TYPE_MEMORY = "memory"
+const DEFAULT_STORAGE_DIR = "."
+const DEFAULT_STORAGE_REGION = "us-east-1"
+const DEFAULT_SNAPSHOT_DIR = "./snapshots"
+
+// Webhook のトピックと、それに対して実行できる処理
+const WEBHOOK_TOPIC_ORDERS_CREATE = "orders/create"
+const WEBHOOK_TOPIC_ORDERS_UPDATED = "orders/updated"
+const WEBHOOK_TOPIC_ORDERS_CANCELLED = "orders/cancelled"
+const WEBHOOK_ACTION_AUTO_CANCEL = "auto-cancel"
+const WEBHOOK_ACTION_EVENT_LOG = "event-log"
+
+// ParseConfig は path から読み込んだ設定ファイルの data を解釈し、既定値の補完、環境変数の上書きと
+// シークレット参照 (env: / file: / keyring:) の解決を行って検証する。
+func ParseConfig(path string, data []byte) (*Config, error) {
 	config := new(Config)
-	_, err := toml.DecodeFile(CONFIG_FILE_PATH, config)
+	meta, err := toml.Decode(string(data), config)
 	if err != nil {
 		log.Println("config parse error.")
 		return nil, err
 	}
 
+	if defaultStore := os.Getenv(ENV_DEFAULT_STORE); defaultStore != "" {
+		config.DefaultStore = defaultStore
+	}
+
+	// [Stores] の無い旧形式の設定は [ApiInfo] を既定のドメインのストアとして扱う。
+	// [Stores.<ストア名>] では誤って本番のストアを操作しないように domain の省略を許さない
+	if len(config.Stores) == 0 {
+		config.Stores = map[string]Store{
+			LEGACY_STORE_NAME: {
+				Domain:      DEFAULT_STORE_DOMAIN,
+				ApiKey:      config.ApiInfo.ApiKey,
+				ApiPassword: config.ApiInfo.ApiPassword,
+				Backend:     config.ApiInfo.Backend,
+			},
+		}
+	}
+
+	config.applyDefaults()
+
+	problems := undecodedKeys(meta)
+	problems = append(problems, config.resolveCredentials()...)
+	problems = append(problems, config.validate()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("設定が不正です (%s)\n  %s", path, strings.Join(problems, "\n  "))
+	}
+
 	return config, nil
 }
+
+// resolveCredentials は環境変数で上書きし、シークレット参照を解決した上で
+// 全ストアの接続情報が揃っているかを検証する。
+func (c *Config) resolveCredentials() []string {
+	var problems []string
+	for _, name := range c.StoreNames() {
+		store := c.Stores[name]
+		overrideFromEnv(&store.Domain, fmt.Sprintf(ENV_STORE_DOMAIN_TEMPLATE, envName(name)))
+		overrideFromEnv(&store.ApiKey, fmt.Sprintf(ENV_STORE_API_KEY_TEMPLATE, envName(name)))
+		overrideFromEnv(&store.ApiPassword, fmt.Sprintf(ENV_STORE_API_PASSWORD_TEMPLATE, envName(name)))
+		overrideFromEnv(&store.WebhookSecret, fmt.Sprintf(ENV_STORE_WEBHOOK_SECRET_TEMPLATE, envName(name)))
+
+		if store.Domain == "" {
+			problems = append(problems, fmt.Sprintf("[Stores.%s] domain が設定されていません", name))
+		}
+
+		var err error
+		store.ApiKey, err = ResolveSecret(store.ApiKey)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("[Stores.%s] apiKey : %s", name, err.Error()))
+		} else if store.ApiKey == "" && store.Backend != "graphql" {
+			problems = append(problems, fmt.Sprintf("[Stores.%s] apiKey が設定されていません (環境変数 %s でも指定できます)", name, fmt.Sprintf(ENV_STORE_API_KEY_TEMPLATE, envName(name))))
+		}
+
+		store.ApiPassword, err = ResolveSecret(store.ApiPassword)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("[Stores.%s] apiPassword : %s", name, err.Error()))
+		} else if store.ApiPassword == "" {
+			problems = append(problems, fmt.Sprintf("[Stores.%s] apiPassword が設定されていません (環境変数 %s でも指定できます)", name, fmt.Sprintf(ENV_STORE_API_PASSWORD_TEMPLATE, envName(name))))
+		}
+
+		store.WebhookSecret, err = ResolveSecret(store.WebhookSecret)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("[Stores.%s] webhookSecret : %s", name, err.Error()))
+		}
+		c.Stores[name] = store
+	}
+
+	var err error
+	c.LocalApi.Token, err = ResolveSecret(c.LocalApi.Token)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("[LocalApi] token : %s", err.Error()))
+	}
+
+	c.CustomerNotice.Password, err = ResolveSecret(c.CustomerNotice.Password)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("[CustomerNotice] password : %s", err.Error()))
+	}
+
+	c.Storage.AccessKey, err = ResolveSecret(c.Storage.AccessKey)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("[Storage] accessKey : %s", err.Error()))
+	}
+	c.Storage.SecretKey, err = ResolveSecret(c.Storage.SecretKey)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("[Storage] secretKey : %s", err.Error()))
+	}
+
+	for i := range c.Notifiers {
+		notifier := &c.Notifiers[i]
+		notifier.Url, err = ResolveSecret(notifier.Url)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("[[Notifiers]] %d番目 url : %s", i+1, err.Error()))
+		}
+		notifier.Password, err = ResolveSecret(notifier.Password)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("[[Notifiers]] %d番目 password : %s", i+1, err.Error()))
+		}
+	}
+
+	return problems
+}
+
+func overrideFromEnv(value *string, name string) {
+	if envValue, ok := os.LookupEnv(name); ok && envValue != "" {
+		*value = envValue
+	}
+}
+
+// GetStore は name のストア設定を返す。
+// name が空の場合は defaultStore、ストアが1つだけならそのストアを返す。
+func (c *Config) GetStore(name string) (*Store, error) {
+	if name == "" {
+		name = c.DefaultStore
+	}
+	if name == "" && len(c.Stores) == 1 {
+		for _, store := range c.Stores {
+			return &store, nil
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("ストアが複数設定されています。-store または defaultStore で %v のいずれかを指定してください", c.StoreNames())
+	}
+
+	store, ok := c.Stores[name]
+	if !ok {
+		return nil, fmt.Errorf("ストア '%s' は設定されていません。設定済みのストア : %v", name, c.StoreNames())
+	}
+	return &store, nil
+}
+
+// GetStoreByDomain は domain (xxx.myshopify.com) のストア設定を返す。
+func (c *Config) GetStoreByDomain(domain string) (*Store, error) {
+	for _, name := range c.StoreNames() {
+		store := c.Stores[name]
+		if strings.EqualFold(store.Domain, domain) {
+			return &store, nil
+		}
+	}
+	return nil, fmt.Errorf("ドメイン '%s' のストアは設定されていません", domain)
+}
+
+func (c *Config) StoreNames() []string {
+	var names []string
+	for name := range c.Stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfigStoreDomain(t *testing.T) {
+	tests := []struct {
+		name       string
+		toml       string
+		wantErr    string
+		wantDomain map[string]string
+	}{
+		{
+			name: "named store without domain",
+			toml: `
+[Stores.global]
+apiKey = "key"
+apiPassword = "password"
+`,
+			wantErr: "[Stores.global] domain が設定されていません",
+		},
+		{
+			name: "named store with domain",
+			toml: `
+[Stores.global]
+domain = "global-shop.myshopify.com"
+apiKey = "key"
+apiPassword = "password"
+`,
+			wantDomain: map[string]string{"global": "global-shop.myshopify.com"},
+		},
+		{
+			name: "legacy ApiInfo uses the default domain",
+			toml: `
+[ApiInfo]
+apiKey = "key"
+apiPassword = "password"
+`,
+			wantDomain: map[string]string{LEGACY_STORE_NAME: DEFAULT_STORE_DOMAIN},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseConfig("config.toml", []byte(tt.toml))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseConfig error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, domain := range tt.wantDomain {
+				if got := config.Stores[name].Domain; got != domain {
+					t.Errorf("[Stores.%s] domain = %s, want %s", name, got, domain)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// 設定値の参照形式
+// - env:NAME                 環境変数 NAME の値
+// - file:/path/to/secret     ファイルの内容 (前後の空白・改行は除く)
+// - keyring:service/account  OS のキーリング (使えない場合は keyring.json)
+const SECRET_PREFIX_ENV = "env:"
+const SECRET_PREFIX_FILE = "file:"
+const SECRET_PREFIX_KEYRING = "keyring:"
+
+const KEYRING_FILE_ENV = "SHOPIFY_KEYRING_FILE"
+const DEFAULT_KEYRING_FILE_PATH = "./keyring.json"
+
+// SecretStore はキーリングからシークレットを取り出す。
+type SecretStore interface {
+	Get(service, account string) (string, error)
+}
+
+// Keyring は keyring: 参照の解決に使う SecretStore。
+// SHOPIFY_KEYRING_FILE が設定されていればファイル、それ以外は OS のキーリングを使う。
+var Keyring SecretStore = newDefaultKeyring()
+
+func newDefaultKeyring() SecretStore {
+	if path := os.Getenv(KEYRING_FILE_ENV); path != "" {
+		return &FileKeyring{Path: path}
+	}
+	return &osKeyring{fallback: &FileKeyring{Path: DEFAULT_KEYRING_FILE_PATH}}
+}
+
+// ResolveSecret は value が参照形式であれば参照先の値を返す。
+// 参照形式でなければ value をそのまま返す。
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, SECRET_PREFIX_ENV):
+		name := strings.TrimPrefix(value, SECRET_PREFIX_ENV)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("環境変数 %s が設定されていません", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, SECRET_PREFIX_FILE):
+		path := strings.TrimPrefix(value, SECRET_PREFIX_FILE)
+		secret, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("シークレットファイル %s の読み込みに失敗しました : %s", path, err.Error())
+		}
+		return strings.TrimSpace(string(secret)), nil
+	case strings.HasPrefix(value, SECRET_PREFIX_KEYRING):
+		ref := strings.TrimPrefix(value, SECRET_PREFIX_KEYRING)
+		slash := strings.Index(ref, "/")
+		if slash <= 0 || slash == len(ref)-1 {
+			return "", fmt.Errorf("キーリングの参照 '%s' は keyring:service/account の形式で指定してください", value)
+		}
+		return Keyring.Get(ref[:slash], ref[slash+1:])
+	default:
+		return value, nil
+	}
+}
+
+// FileKeyring は {"service/account": "secret"} 形式の JSON ファイルをキーリングとして扱う。
+// OS のキーリングが使えない環境やテストで使う。
+type FileKeyring struct {
+	Path string
+}
+
+func (k *FileKeyring) Get(service, account string) (string, error) {
+	jsonBytes, err := ioutil.ReadFile(k.Path)
+	if err != nil {
+		return "", fmt.Errorf("キーリングファイル %s の読み込みに失敗しました : %s", k.Path, err.Error())
+	}
+
+	secrets := map[string]string{}
+	err = json.Unmarshal(jsonBytes, &secrets)
+	if err != nil {
+		return "", fmt.Errorf("キーリングファイル %s の形式が不正です : %s", k.Path, err.Error())
+	}
+
+	secret, ok := secrets[service+"/"+account]
+	if !ok {
+		return "", fmt.Errorf("キーリングファイル %s に %s/%s がありません", k.Path, service, account)
+	}
+	return secret, nil
+}
+
+// osKeyring は macOS の security、Linux の secret-tool でキーリングを参照する。
+// コマンドが無い OS (Windows 等) では fallback を使う。
+type osKeyring struct {
+	fallback SecretStore
+}
+
+func (k *osKeyring) Get(service, account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	default:
+		return k.fallback.Get(service, account)
+	}
+
+	if _, err := exec.LookPath(cmd.Path); err != nil {
+		return k.fallback.Get(service, account)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("キーリングから %s/%s を取得できませんでした : %s", service, account, err.Error())
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// envName は環境変数名に使えるようにストア名を大文字・英数字に変換する。
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return r - 'a' + 'A'
+		case 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,341 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/schedule"
+
+	"github.com/BurntSushi/toml"
+)
+
+const DEFAULT_THREAD_NUM = 10
+const DEFAULT_SCAN_HOURS = 24
+
+// 設定ファイルのサンプル値。このままでは API 呼び出しが必ず失敗する
+const PLACEHOLDER_CREDENTIAL = "dummy"
+
+var apiVersionPattern = regexp.MustCompile(`^(\d{4}-(01|04|07|10)|unstable)$`)
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// スケジュール実行できるフロー (サーバーとして動き続けるフローは除く)
+var schedulableFlows = []string{
+	constants.FLOW_TYPE_CREATE_INSTANCE,
+	constants.FLOW_TYPE_CANCEL_LINE_ITEMS,
+	constants.FLOW_TYPE_AUTO_CANCEL,
+	constants.FLOW_TYPE_CONFIG_CHECK,
+	constants.FLOW_TYPE_AUDIT_REPORT,
+}
+
+// applyDefaults は未指定の項目に既定値を設定する。
+func (c *Config) applyDefaults() {
+	if c.Thread.ThreadNum == 0 {
+		c.Thread.ThreadNum = DEFAULT_THREAD_NUM
+	}
+	if c.AutoCancel.ScanHours == 0 {
+		c.AutoCancel.ScanHours = DEFAULT_SCAN_HOURS
+	}
+	if c.Serve.Listen == "" {
+		c.Serve.Listen = DEFAULT_SERVE_LISTEN
+	}
+	if c.Serve.EventLogPath == "" {
+		c.Serve.EventLogPath = DEFAULT_EVENT_LOG_PATH
+	}
+	if c.LocalApi.Listen == "" {
+		c.LocalApi.Listen = DEFAULT_LOCAL_API_LISTEN
+	}
+	if c.Schedule.HistoryPath == "" {
+		c.Schedule.HistoryPath = DEFAULT_SCHEDULE_HISTORY_PATH
+	}
+	if c.CustomerNotice.TemplateDir == "" {
+		c.CustomerNotice.TemplateDir = DEFAULT_NOTICE_TEMPLATE_DIR
+	}
+	if c.CustomerNotice.DefaultLocale == "" {
+		c.CustomerNotice.DefaultLocale = DEFAULT_NOTICE_LOCALE
+	}
+	if c.CustomerNotice.Sink == "" {
+		c.CustomerNotice.Sink = NOTICE_SINK_FILE
+	}
+	if c.CustomerNotice.Port == 0 {
+		c.CustomerNotice.Port = DEFAULT_SMTP_PORT
+	}
+	if c.CustomerNotice.FileDir == "" {
+		c.CustomerNotice.FileDir = DEFAULT_NOTICE_FILE_DIR
+	}
+	if c.Snapshot.Dir == "" {
+		c.Snapshot.Dir = DEFAULT_SNAPSHOT_DIR
+	}
+	if c.Snapshot.Format == "" {
+		c.Snapshot.Format = SNAPSHOT_FORMAT_DIR
+	}
+	if c.Restock.Mode == "" {
+		c.Restock.Mode = RESTOCK_MODE_OFF
+	}
+	if c.Lottery.Unique == "" {
+		c.Lottery.Unique = LOTTERY_UNIQUE_CUSTOMER
+	}
+	if c.Storage.Type == "" {
+		c.Storage.Type = STORAGE_TYPE_LOCAL
+	}
+	if c.Storage.Dir == "" {
+		c.Storage.Dir = DEFAULT_STORAGE_DIR
+	}
+	if c.Storage.Region == "" {
+		c.Storage.Region = DEFAULT_STORAGE_REGION
+	}
+	if len(c.DuplicateCheck.Keys) == 0 {
+		c.DuplicateCheck.Keys = DEFAULT_DUPLICATE_KEYS
+	}
+	if c.DuplicateCheck.MinAccounts == 0 {
+		c.DuplicateCheck.MinAccounts = DEFAULT_DUPLICATE_MIN_ACCOUNTS
+	}
+	for i := range c.Notifiers {
+		if c.Notifiers[i].On == "" {
+			c.Notifiers[i].On = NOTIFY_ON_ALWAYS
+		}
+		if c.Notifiers[i].Type == NOTIFIER_TYPE_SMTP && c.Notifiers[i].Port == 0 {
+			c.Notifiers[i].Port = DEFAULT_SMTP_PORT
+		}
+	}
+
+	for name, store := range c.Stores {
+		store.Name = name
+		if store.ApiVersion == "" {
+			store.ApiVersion = DEFAULT_API_VERSION
+		}
+		if store.GraphqlApiVersion == "" {
+			store.GraphqlApiVersion = DEFAULT_GRAPHQL_API_VERSION
+		}
+		if store.Backend == "" {
+			store.Backend = "rest"
+		}
+		if store.Currency == "" {
+			store.Currency = DEFAULT_CURRENCY
+		}
+		if store.ThreadNum == 0 {
+			store.ThreadNum = c.Thread.ThreadNum
+		}
+		if store.Restock == "" {
+			store.Restock = c.Restock.Mode
+		}
+		c.Stores[name] = store
+	}
+}
+
+// validate は既定値の補完後の設定値の範囲・形式を検証する。
+func (c *Config) validate() []string {
+	var problems []string
+
+	if !isRestockMode(c.Restock.Mode) {
+		problems = append(problems, fmt.Sprintf("[Restock] mode は %s / %s / %s のいずれかを指定してください : %s", RESTOCK_MODE_OFF, RESTOCK_MODE_VERIFY, RESTOCK_MODE_ADJUST, c.Restock.Mode))
+	}
+
+	if !containsString([]string{SNAPSHOT_FORMAT_DIR, SNAPSHOT_FORMAT_TAR_GZ, SNAPSHOT_FORMAT_OFF}, c.Snapshot.Format) {
+		problems = append(problems, fmt.Sprintf("[Snapshot] format は %s / %s / %s のいずれかを指定してください : %s", SNAPSHOT_FORMAT_DIR, SNAPSHOT_FORMAT_TAR_GZ, SNAPSHOT_FORMAT_OFF, c.Snapshot.Format))
+	}
+
+	if !containsString([]string{LOTTERY_UNIQUE_CUSTOMER, LOTTERY_UNIQUE_ADDRESS, LOTTERY_UNIQUE_NONE}, c.Lottery.Unique) {
+		problems = append(problems, fmt.Sprintf("[Lottery] unique は %s / %s / %s のいずれかを指定してください : %s", LOTTERY_UNIQUE_CUSTOMER, LOTTERY_UNIQUE_ADDRESS, LOTTERY_UNIQUE_NONE, c.Lottery.Unique))
+	}
+	if c.Lottery.Winners < 0 || c.Lottery.ProductId < 0 {
+		problems = append(problems, "[Lottery] winners / productId は0以上を指定してください")
+	}
+
+	for _, key := range c.DuplicateCheck.Keys {
+		if !containsString(DUPLICATE_KEYS, key) {
+			problems = append(problems, fmt.Sprintf("[DuplicateCheck] keys は %s から指定してください : %s", strings.Join(DUPLICATE_KEYS, " / "), key))
+		}
+	}
+	if c.DuplicateCheck.MinAccounts < 1 {
+		problems = append(problems, fmt.Sprintf("[DuplicateCheck] minAccounts は1以上を指定してください : %d", c.DuplicateCheck.MinAccounts))
+	}
+
+	switch c.Storage.Type {
+	case STORAGE_TYPE_LOCAL, STORAGE_TYPE_MEMORY:
+	case STORAGE_TYPE_S3:
+		if c.Storage.Bucket == "" || c.Storage.AccessKey == "" || c.Storage.SecretKey == "" {
+			problems = append(problems, "[Storage] type = s3 では bucket / accessKey / secretKey を指定してください")
+		}
+		if c.Storage.Endpoint != "" {
+			endpoint, err := url.Parse(c.Storage.Endpoint)
+			if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+				problems = append(problems, fmt.Sprintf("[Storage] endpoint は http(s)://host[:port] の形式で指定してください : %s", c.Storage.Endpoint))
+			}
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("[Storage] type は %s / %s / %s のいずれかを指定してください : %s", STORAGE_TYPE_LOCAL, STORAGE_TYPE_S3, STORAGE_TYPE_MEMORY, c.Storage.Type))
+	}
+
+	if c.Thread.ThreadNum < 1 {
+		problems = append(problems, fmt.Sprintf("[Thread] threadNum は1以上を指定してください : %d", c.Thread.ThreadNum))
+	}
+
+	if c.DefaultStore != "" {
+		if _, ok := c.Stores[c.DefaultStore]; !ok {
+			problems = append(problems, fmt.Sprintf("defaultStore '%s' は設定されていません。設定済みのストア : %v", c.DefaultStore, c.StoreNames()))
+		}
+	}
+
+	for _, name := range c.StoreNames() {
+		store := c.Stores[name]
+		section := fmt.Sprintf("[Stores.%s]", name)
+		if store.Domain != "" && !strings.HasSuffix(store.Domain, ".myshopify.com") {
+			problems = append(problems, fmt.Sprintf("%s domain は xxx.myshopify.com の形式で指定してください : %s", section, store.Domain))
+		}
+		if !apiVersionPattern.MatchString(store.ApiVersion) {
+			problems = append(problems, fmt.Sprintf("%s apiVersion は YYYY-MM (01/04/07/10月) で指定してください : %s", section, store.ApiVersion))
+		}
+		if !apiVersionPattern.MatchString(store.GraphqlApiVersion) {
+			problems = append(problems, fmt.Sprintf("%s graphqlApiVersion は YYYY-MM (01/04/07/10月) で指定してください : %s", section, store.GraphqlApiVersion))
+		}
+		if store.Backend != "rest" && store.Backend != "graphql" {
+			problems = append(problems, fmt.Sprintf("%s backend は rest / graphql のいずれかを指定してください : %s", section, store.Backend))
+		}
+		if !currencyPattern.MatchString(store.Currency) {
+			problems = append(problems, fmt.Sprintf("%s currency は JPY の様な3文字の通貨コードで指定してください : %s", section, store.Currency))
+		}
+		if store.ThreadNum < 1 {
+			problems = append(problems, fmt.Sprintf("%s threadNum は1以上を指定してください : %d", section, store.ThreadNum))
+		}
+		if !isRestockMode(store.Restock) {
+			problems = append(problems, fmt.Sprintf("%s restock は %s / %s / %s のいずれかを指定してください : %s", section, RESTOCK_MODE_OFF, RESTOCK_MODE_VERIFY, RESTOCK_MODE_ADJUST, store.Restock))
+		}
+		if store.RestockLocationId < 0 {
+			problems = append(problems, fmt.Sprintf("%s restockLocationId は0以上を指定してください : %d", section, store.RestockLocationId))
+		}
+	}
+
+	if c.Safety.MaxOrdersPerRun < 0 || c.Safety.MaxTotalAmount < 0 || c.Safety.MaxOrderAgeDays < 0 {
+		problems = append(problems, "[Safety] maxOrdersPerRun / maxTotalAmount / maxOrderAgeDays は0以上を指定してください (0 は無制限)")
+	}
+
+	if c.AutoCancel.ScanHours < 0 {
+		problems = append(problems, fmt.Sprintf("[AutoCancel] scanHours は0以上を指定してください : %d", c.AutoCancel.ScanHours))
+	}
+	for i, rule := range c.AutoCancel.Rules {
+		section := fmt.Sprintf("[[AutoCancel.Rules]] %d番目", i+1)
+		if rule.Name == "" {
+			problems = append(problems, fmt.Sprintf("%s name が設定されていません", section))
+		}
+		if rule.MinTotalPrice < 0 || rule.MaxOrdersPerCustomer < 0 || rule.WindowHours < 0 {
+			problems = append(problems, fmt.Sprintf("%s minTotalPrice / maxOrdersPerCustomer / windowHours は0以上を指定してください", section))
+		}
+	}
+
+	for i, action := range c.Serve.Actions {
+		section := fmt.Sprintf("[[Serve.Actions]] %d番目", i+1)
+		if action.Action != WEBHOOK_ACTION_AUTO_CANCEL && action.Action != WEBHOOK_ACTION_EVENT_LOG {
+			problems = append(problems, fmt.Sprintf("%s action は %s / %s のいずれかを指定してください : %s", section, WEBHOOK_ACTION_AUTO_CANCEL, WEBHOOK_ACTION_EVENT_LOG, action.Action))
+		}
+		if len(action.Topics) == 0 {
+			problems = append(problems, fmt.Sprintf("%s topics が設定されていません", section))
+		}
+		for _, topic := range action.Topics {
+			if topic != WEBHOOK_TOPIC_ORDERS_CREATE && topic != WEBHOOK_TOPIC_ORDERS_UPDATED && topic != WEBHOOK_TOPIC_ORDERS_CANCELLED {
+				problems = append(problems, fmt.Sprintf("%s topics は %s / %s / %s から指定してください : %s", section, WEBHOOK_TOPIC_ORDERS_CREATE, WEBHOOK_TOPIC_ORDERS_UPDATED, WEBHOOK_TOPIC_ORDERS_CANCELLED, topic))
+			}
+		}
+	}
+
+	jobNames := map[string]bool{}
+	for i, job := range c.Schedule.Jobs {
+		section := fmt.Sprintf("[[Schedule.Jobs]] %d番目", i+1)
+		if job.Name == "" {
+			problems = append(problems, fmt.Sprintf("%s name が設定されていません", section))
+		} else if jobNames[job.Name] {
+			problems = append(problems, fmt.Sprintf("%s name '%s' が重複しています", section, job.Name))
+		}
+		jobNames[job.Name] = true
+		if _, err := schedule.ParseCron(job.Cron); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s", section, err.Error()))
+		}
+		if !containsString(schedulableFlows, job.Flow) {
+			problems = append(problems, fmt.Sprintf("%s flow は %v のいずれかを指定してください : %s", section, schedulableFlows, job.Flow))
+		}
+		if job.Store != "" {
+			if _, ok := c.Stores[job.Store]; !ok {
+				problems = append(problems, fmt.Sprintf("%s store '%s' は設定されていません", section, job.Store))
+			}
+		}
+	}
+
+	if c.CustomerNotice.Enabled {
+		switch c.CustomerNotice.Sink {
+		case NOTICE_SINK_SMTP:
+			if c.CustomerNotice.Host == "" || c.CustomerNotice.From == "" {
+				problems = append(problems, "[CustomerNotice] sink = smtp の場合は host / from を設定してください")
+			}
+		case NOTICE_SINK_FILE:
+		default:
+			problems = append(problems, fmt.Sprintf("[CustomerNotice] sink は %s / %s のいずれかを指定してください : %s", NOTICE_SINK_SMTP, NOTICE_SINK_FILE, c.CustomerNotice.Sink))
+		}
+	}
+
+	for i, notifier := range c.Notifiers {
+		section := fmt.Sprintf("[[Notifiers]] %d番目", i+1)
+		if notifier.On != NOTIFY_ON_ALWAYS && notifier.On != NOTIFY_ON_FAILURE {
+			problems = append(problems, fmt.Sprintf("%s on は %s / %s のいずれかを指定してください : %s", section, NOTIFY_ON_ALWAYS, NOTIFY_ON_FAILURE, notifier.On))
+		}
+		switch notifier.Type {
+		case NOTIFIER_TYPE_WEBHOOK:
+			if !strings.HasPrefix(notifier.Url, "https://") && !strings.HasPrefix(notifier.Url, "http://") {
+				problems = append(problems, fmt.Sprintf("%s url は http(s):// で始まる URL を指定してください", section))
+			}
+		case NOTIFIER_TYPE_SMTP:
+			if notifier.Host == "" || notifier.From == "" || len(notifier.To) == 0 {
+				problems = append(problems, fmt.Sprintf("%s host / from / to を設定してください", section))
+			}
+		case NOTIFIER_TYPE_FILE:
+			if notifier.Dir == "" {
+				problems = append(problems, fmt.Sprintf("%s dir を設定してください", section))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("%s type は %s / %s / %s のいずれかを指定してください : %s", section, NOTIFIER_TYPE_WEBHOOK, NOTIFIER_TYPE_SMTP, NOTIFIER_TYPE_FILE, notifier.Type))
+		}
+	}
+
+	return problems
+}
+
+// Warnings は読み込みは止めないが API 呼び出しが失敗する設定 (サンプル値のままの認証情報) を返す。
+// 同梱の config.toml のまま起動できるよう、config-check でのみエラーとして扱う。
+func (c *Config) Warnings() []string {
+	var warnings []string
+	for _, name := range c.StoreNames() {
+		store := c.Stores[name]
+		if store.HasPlaceholderCredential() {
+			warnings = append(warnings, fmt.Sprintf("[Stores.%s] apiKey / apiPassword がサンプル値 '%s' のままです", name, PLACEHOLDER_CREDENTIAL))
+		}
+	}
+	return warnings
+}
+
+// HasPlaceholderCredential は apiKey / apiPassword が設定ファイルのサンプル値のままかを返す。
+func (s *Store) HasPlaceholderCredential() bool {
+	return s.ApiKey == PLACEHOLDER_CREDENTIAL || s.ApiPassword == PLACEHOLDER_CREDENTIAL
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func isRestockMode(mode string) bool {
+	return containsString([]string{RESTOCK_MODE_OFF, RESTOCK_MODE_VERIFY, RESTOCK_MODE_ADJUST}, mode)
+}
+
+// undecodedKeys は設定ファイル中の未知の項目 (綴り間違い等) を返す。
+func undecodedKeys(meta toml.MetaData) []string {
+	var problems []string
+	for _, key := range meta.Undecoded() {
+		problems = append(problems, fmt.Sprintf("未知の設定項目です : %s", key.String()))
+	}
+	return problems
+}
@@ -1,10 +1,47 @@
 package constants
 
 const INPUT_EXCEL_FILE_PATH = "shopify-input.xlsx"
+const INPUT_LINE_ITEM_EXCEL_FILE_PATH = "shopify-line-item-input.xlsx"
+const INPUT_DRAFT_ORDER_EXCEL_FILE_PATH = "shopify-draft-order-input.xlsx"
+const AUTO_CANCEL_PROPOSAL_FILE_PATH = "auto-cancel-proposal.xlsx"
+const AUDIT_REPORT_FILE_PATH = "audit-report.xlsx"
+const LOTTERY_RESULT_FILE_PATH = "lottery-result.xlsx"
+const DUPLICATE_CLUSTERS_FILE_PATH = "duplicate-clusters.xlsx"
 
 const FLOW_TYPE_CREATE_INSTANCE = "cancel-order"
+const FLOW_TYPE_CANCEL_LINE_ITEMS = "cancel-line-items"
+const FLOW_TYPE_AUTO_CANCEL = "auto-cancel"
+const FLOW_TYPE_CONFIG_CHECK = "config-check"
+const FLOW_TYPE_AUDIT_REPORT = "audit-report"
+const FLOW_TYPE_SERVE = "serve"
+const FLOW_TYPE_API = "api"
+const FLOW_TYPE_SCHEDULE = "schedule"
+const FLOW_TYPE_SHOW_SNAPSHOT = "show-snapshot"
+const FLOW_TYPE_REVERT = "revert"
+const FLOW_TYPE_CREATE_DRAFT_ORDERS = "create-draft-orders"
+const FLOW_TYPE_LOTTERY = "lottery"
+const FLOW_TYPE_DETECT_DUPLICATES = "detect-duplicates"
 
-//const GET_ORDER_URL_TEMPLATE = "https://%s:%s@penguin-auto-buy-service.myshopify.com/admin/api/2020-07/orders.json?status=any&name=%d"
-const GET_ORDER_URL_TEMPLATE = "https://%s:%s@penguin-auto-buy-service.myshopify.com/admin/api/2020-07/orders.json?name=%d"
-const CANCEL_ORDER_URL_TEMPLATE = "https://%s:%s@penguin-auto-buy-service.myshopify.com/admin/api/2020-07/orders/%d/cancel.json"
-const TRANSACTIONS_URL_TEMPLATE = "https://%s:%s@penguin-auto-buy-service.myshopify.com/admin/api/2020-07/orders/%d/transactions.json"
+// "https://{apiKey}:{apiPassword}@{domain}/admin/api/{apiVersion}/..."
+//const GET_ORDER_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/orders.json?status=any&name=%d"
+const GET_ORDER_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/orders.json?name=%d"
+const SEARCH_ORDERS_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/orders.json"
+const ORDER_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/orders/%d.json"
+const CANCEL_ORDER_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/orders/%d/cancel.json"
+const OPEN_ORDER_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/orders/%d/open.json"
+const DRAFT_ORDERS_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/draft_orders.json"
+const SEND_DRAFT_ORDER_INVOICE_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/draft_orders/%d/send_invoice.json"
+const COMPLETE_DRAFT_ORDER_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/draft_orders/%d/complete.json?payment_pending=%t"
+const TRANSACTIONS_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/orders/%d/transactions.json"
+const CALCULATE_REFUND_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/orders/%d/refunds/calculate.json"
+const REFUNDS_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/orders/%d/refunds.json"
+const SHOP_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/shop.json"
+const VARIANT_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/variants/%d.json"
+const INVENTORY_LEVELS_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/inventory_levels.json"
+const ADJUST_INVENTORY_LEVEL_URL_TEMPLATE = "https://%s:%s@%s/admin/api/%s/inventory_levels/adjust.json"
+
+// "https://{apiKey}:{apiPassword}@{domain}/admin/oauth/access_scopes.json" (バージョン無し)
+const ACCESS_SCOPES_URL_TEMPLATE = "https://%s:%s@%s/admin/oauth/access_scopes.json"
+
+// "https://{domain}/admin/api/{graphqlApiVersion}/graphql.json"
+const GRAPHQL_URL_TEMPLATE = "https://%s/admin/api/%s/graphql.json"
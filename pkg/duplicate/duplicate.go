@@ -0,0 +1,128 @@
+package duplicate
+
+import (
+	"sort"
+	"strings"
+)
+
+// Order は重複判定に使うオーダーの項目。
+// Keys は項目毎の正規化済みの値で、空の値は判定に使わない。
+type Order struct {
+	OrderNumber int
+	CustomerKey string
+	Keys        map[string][]string
+}
+
+// Cluster は同じ値の項目でつながった、別アカウントのオーダーのまとまり。
+// MatchedKeys は別アカウントのオーダー同士をつないだ項目。
+type Cluster struct {
+	Orders      []Order
+	Accounts    int
+	MatchedKeys []string
+}
+
+// FindClusters は keys のいずれかの値が一致するオーダーをつなぎ、アカウント (CustomerKey) が
+// minAccounts 以上のまとまりを、オーダー数の多い順に返す。
+func FindClusters(orders []Order, keys []string, minAccounts int) []Cluster {
+	parent := make([]int, len(orders))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	type link struct {
+		from, to int
+		key      string
+	}
+	var links []link
+	for _, key := range keys {
+		first := map[string]int{}
+		for i, order := range orders {
+			for _, value := range order.Keys[key] {
+				if value == "" {
+					continue
+				}
+				j, ok := first[value]
+				if !ok {
+					first[value] = i
+					continue
+				}
+				parent[find(i)] = find(j)
+				if orders[i].CustomerKey != orders[j].CustomerKey {
+					links = append(links, link{from: i, to: j, key: key})
+				}
+			}
+		}
+	}
+
+	members := map[int][]int{}
+	for i := range orders {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+	matchedKeys := map[int]map[string]bool{}
+	for _, l := range links {
+		root := find(l.from)
+		if matchedKeys[root] == nil {
+			matchedKeys[root] = map[string]bool{}
+		}
+		matchedKeys[root][l.key] = true
+	}
+
+	var clusters []Cluster
+	for root, indexes := range members {
+		accounts := map[string]bool{}
+		for _, i := range indexes {
+			accounts[orders[i].CustomerKey] = true
+		}
+		if len(indexes) < 2 || len(accounts) < minAccounts {
+			continue
+		}
+
+		cluster := Cluster{Accounts: len(accounts)}
+		for _, i := range indexes {
+			cluster.Orders = append(cluster.Orders, orders[i])
+		}
+		sort.Slice(cluster.Orders, func(i, j int) bool { return cluster.Orders[i].OrderNumber < cluster.Orders[j].OrderNumber })
+		for _, key := range keys {
+			if matchedKeys[root][key] {
+				cluster.MatchedKeys = append(cluster.MatchedKeys, key)
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Orders) != len(clusters[j].Orders) {
+			return len(clusters[i].Orders) > len(clusters[j].Orders)
+		}
+		return clusters[i].Orders[0].OrderNumber < clusters[j].Orders[0].OrderNumber
+	})
+	return clusters
+}
+
+// NormalizeEmail はメールアドレスの別名を1つにまとめる。
+// 小文字にして + 以降 (plus addressing) を除き、ドットを無視する Gmail はローカル部のドットも除く。
+func NormalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if plus := strings.Index(local, "+"); plus > 0 {
+		local = local[:plus]
+	}
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		local = strings.Replace(local, ".", "", -1)
+	}
+	return local + "@" + domain
+}
@@ -0,0 +1,146 @@
+package duplicate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func order(orderNumber int, customer string, keys map[string][]string) Order {
+	return Order{OrderNumber: orderNumber, CustomerKey: customer, Keys: keys}
+}
+
+func TestFindClusters(t *testing.T) {
+	tests := []struct {
+		name        string
+		orders      []Order
+		keys        []string
+		minAccounts int
+		want        [][]int
+		wantKeys    [][]string
+	}{
+		{
+			name: "same address different accounts",
+			orders: []Order{
+				order(1, "a", map[string][]string{"address": {"tokyo 1-1"}}),
+				order(2, "b", map[string][]string{"address": {"tokyo 1-1"}}),
+				order(3, "c", map[string][]string{"address": {"osaka 2-2"}}),
+			},
+			keys:        []string{"address", "phone"},
+			minAccounts: 2,
+			want:        [][]int{{1, 2}},
+			wantKeys:    [][]string{{"address"}},
+		},
+		{
+			name: "same account only is not reported",
+			orders: []Order{
+				order(1, "a", map[string][]string{"address": {"tokyo 1-1"}}),
+				order(2, "a", map[string][]string{"address": {"tokyo 1-1"}}),
+			},
+			keys:        []string{"address"},
+			minAccounts: 2,
+			want:        nil,
+		},
+		{
+			name: "chained by different keys",
+			orders: []Order{
+				order(1, "a", map[string][]string{"address": {"tokyo 1-1"}}),
+				order(2, "b", map[string][]string{"address": {"tokyo 1-1"}, "phone": {"0312345678"}}),
+				order(3, "c", map[string][]string{"phone": {"0312345678"}}),
+				order(4, "d", map[string][]string{"phone": {"0698765432"}}),
+			},
+			keys:        []string{"address", "phone"},
+			minAccounts: 2,
+			want:        [][]int{{1, 2, 3}},
+			wantKeys:    [][]string{{"address", "phone"}},
+		},
+		{
+			name: "keys not listed are ignored",
+			orders: []Order{
+				order(1, "a", map[string][]string{"ip": {"203.0.113.1"}}),
+				order(2, "b", map[string][]string{"ip": {"203.0.113.1"}}),
+			},
+			keys:        []string{"address", "phone", "email", "card"},
+			minAccounts: 2,
+			want:        nil,
+		},
+		{
+			name: "empty values do not match",
+			orders: []Order{
+				order(1, "a", map[string][]string{"phone": {""}}),
+				order(2, "b", map[string][]string{"phone": {""}}),
+			},
+			keys:        []string{"phone"},
+			minAccounts: 2,
+			want:        nil,
+		},
+		{
+			name: "minAccounts",
+			orders: []Order{
+				order(1, "a", map[string][]string{"email": {"x@example.com"}}),
+				order(2, "b", map[string][]string{"email": {"x@example.com"}}),
+				order(3, "c", map[string][]string{"card": {"411111-1111"}}),
+				order(4, "d", map[string][]string{"card": {"411111-1111"}}),
+				order(5, "e", map[string][]string{"card": {"411111-1111"}}),
+			},
+			keys:        []string{"email", "card"},
+			minAccounts: 3,
+			want:        [][]int{{3, 4, 5}},
+			wantKeys:    [][]string{{"card"}},
+		},
+		{
+			name: "larger clusters first",
+			orders: []Order{
+				order(1, "a", map[string][]string{"email": {"x@example.com"}}),
+				order(2, "b", map[string][]string{"email": {"x@example.com"}}),
+				order(3, "c", map[string][]string{"card": {"411111-1111"}}),
+				order(4, "d", map[string][]string{"card": {"411111-1111"}}),
+				order(5, "e", map[string][]string{"card": {"411111-1111"}}),
+			},
+			keys:        []string{"email", "card"},
+			minAccounts: 2,
+			want:        [][]int{{3, 4, 5}, {1, 2}},
+			wantKeys:    [][]string{{"card"}, {"email"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusters := FindClusters(tt.orders, tt.keys, tt.minAccounts)
+
+			var got [][]int
+			var gotKeys [][]string
+			for _, cluster := range clusters {
+				var orderNumbers []int
+				for _, order := range cluster.Orders {
+					orderNumbers = append(orderNumbers, order.OrderNumber)
+				}
+				got = append(got, orderNumbers)
+				gotKeys = append(gotKeys, cluster.MatchedKeys)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("clusters = %v, want %v", got, tt.want)
+			}
+			if tt.wantKeys != nil && !reflect.DeepEqual(gotKeys, tt.wantKeys) {
+				t.Errorf("matched keys = %v, want %v", gotKeys, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{email: " Taro@Example.com ", want: "taro@example.com"},
+		{email: "taro+lottery@example.com", want: "taro@example.com"},
+		{email: "t.a.ro@example.com", want: "t.a.ro@example.com"},
+		{email: "T.a.ro+1@gmail.com", want: "taro@gmail.com"},
+		{email: "taro@googlemail.com", want: "taro@gmail.com"},
+		{email: "not-an-email", want: "not-an-email"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeEmail(tt.email); got != tt.want {
+			t.Errorf("NormalizeEmail(%q) = %s, want %s", tt.email, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/localapi"
+)
+
+const API_FLOW_CANCEL_ORDERS = "cancel-orders"
+
+// Api はフローを HTTP で実行するローカル API を起動する。
+// POST /flows/cancel-orders でキャンセルをジョブとして登録し、GET /jobs/{id} で進捗と結果を返す。
+func Api(config *config.Config) {
+
+	err := api(config)
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+}
+
+func api(config *config.Config) error {
+	server, err := localapi.NewServer(config.LocalApi.Token, map[string]localapi.FlowHandler{
+		API_FLOW_CANCEL_ORDERS: apiCancelOrders(config),
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("INFO : ローカル API を %s で待ち受けます\n", config.LocalApi.Listen)
+	return http.ListenAndServe(config.LocalApi.Listen, server)
+}
+
+// apiCancelOrders はオーダー番号または検索条件で指定したオーダーをオーソリキャンセル・キャンセルする。
+// API では確認入力ができないため、[Safety] の上限のみ適用する。
+func apiCancelOrders(config *config.Config) localapi.FlowHandler {
+	return func(body []byte) (string, func(job *localapi.Job) error, error) {
+		var request localapi.CancelOrdersRequest
+		err := json.Unmarshal(body, &request)
+		if err != nil {
+			return "", nil, err
+		}
+		if (len(request.OrderNumbers) == 0) == (request.Query == "") {
+			return "", nil, fmt.Errorf("order_numbers または query のどちらか一方を指定してください")
+		}
+
+		store, err := config.GetStore(request.Store)
+		if err != nil {
+			return "", nil, err
+		}
+		if request.Query != "" {
+			_, err = shopify.ParseOrderQuery(request.Query)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+
+		safety := config.Safety
+		safety.AssumeYes = true
+		return store.Name, func(job *localapi.Job) error {
+			orderNumberList := request.OrderNumbers
+			if request.Query != "" {
+				searched, err := shopify.SearchOrderNumberList(request.Query, store)
+				if err != nil {
+					return err
+				}
+				orderNumberList = searched
+			}
+			return shopify.CancelOrderNumbersWithSink(orderNumberList, store, &safety, job)
+		}, nil
+	}
+}
@@ -0,0 +1,185 @@
+package flow
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/audit"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/output"
+	"shopify-manager/pkg/storage"
+
+	"github.com/tealeg/xlsx"
+)
+
+const AUDIT_REPORT_DATE_LAYOUT = "2006-01-02"
+
+// AuditReport は監査ファイルの改ざんを検証し、期間 (from / to は YYYY-MM-DD、両端を含む) と
+// オーダー (オーダー番号またはオーダー ID) で絞り込んだ記録を表示・出力する。
+func AuditReport(config *config.Config, from, to, order string) {
+
+	err := runFlow(config, constants.FLOW_TYPE_AUDIT_REPORT, func(sink shopify.Sink) error {
+		return auditReport(from, to, order)
+	})
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+
+	log.Println("監査レポート出力成功")
+}
+
+// AuditResult は JSON 出力モードで書き出す監査記録1件。
+type AuditResult struct {
+	Type string `json:"type"`
+	audit.Entry
+}
+
+// VerifyResult は JSON 出力モードで書き出す監査ファイルのハッシュ検証結果。
+type VerifyResult struct {
+	Type     string `json:"type"`
+	Entries  int    `json:"entries"`
+	Verified bool   `json:"verified"`
+	LastSeq  int64  `json:"last_seq,omitempty"`
+	LastHash string `json:"last_hash,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func auditReport(from, to, order string) error {
+	filter, err := newAuditFilter(from, to, order)
+	if err != nil {
+		return err
+	}
+
+	entries, err := audit.Read(audit.AUDIT_FILE_PATH)
+	if err != nil {
+		return err
+	}
+
+	verifyResult := VerifyResult{Type: output.TYPE_VERIFY, Entries: len(entries), Verified: true}
+	if len(entries) > 0 {
+		verifyResult.LastSeq = entries[len(entries)-1].Seq
+		verifyResult.LastHash = entries[len(entries)-1].Hash
+	}
+	err = audit.Verify(entries)
+	if err != nil {
+		log.Printf("WARN : 監査ファイルの検証に失敗しました。%s\n", err.Error())
+		verifyResult.Verified = false
+		verifyResult.Error = err.Error()
+	} else {
+		log.Printf("INFO : 監査ファイルの検証に成功しました (%d件)\n", len(entries))
+	}
+	if len(entries) > 0 {
+		// 末尾の削除や全体の再計算はハッシュの鎖では検出できないため、最終行を別の場所に控えてもらう
+		log.Printf("INFO : 最終行 seq %d hash %s (控えておき、次回の監査で照合してください)\n", verifyResult.LastSeq, verifyResult.LastHash)
+	}
+	output.Write(verifyResult)
+
+	var matched []audit.Entry
+	for _, entry := range entries {
+		if filter.match(entry) {
+			matched = append(matched, entry)
+		}
+	}
+
+	for _, entry := range matched {
+		result := "success"
+		if entry.Error != "" {
+			result = "failed"
+		}
+		log.Printf("INFO : %s %s store '%s' %s orderNumber '%d' orderId '%d' transactionId '%d' draftOrderId '%d' status %d %s requestId '%s'\n",
+			entry.Time.Local().Format("2006-01-02 15:04:05"), entry.User, entry.Store, entry.Action,
+			entry.OrderNumber, entry.OrderID, entry.TransactionID, entry.DraftOrderID, entry.Status, result, entry.RequestID)
+		output.Write(AuditResult{Type: output.TYPE_AUDIT, Entry: entry})
+	}
+	log.Printf("INFO : %d of %d audit entries matched\n", len(matched), len(entries))
+
+	return writeAuditReport(constants.AUDIT_REPORT_FILE_PATH, matched)
+}
+
+// auditFilter は -from / -to (YYYY-MM-DD、両端を含む) と -order (オーダー番号またはオーダー ID) による監査記録の絞り込み。
+type auditFilter struct {
+	from  time.Time
+	to    time.Time
+	order int64
+}
+
+func newAuditFilter(from, to, order string) (*auditFilter, error) {
+	filter := new(auditFilter)
+	var err error
+	if from != "" {
+		filter.from, err = time.ParseInLocation(AUDIT_REPORT_DATE_LAYOUT, from, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("-from は YYYY-MM-DD で指定してください : %s", from)
+		}
+	}
+	if to != "" {
+		filter.to, err = time.ParseInLocation(AUDIT_REPORT_DATE_LAYOUT, to, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("-to は YYYY-MM-DD で指定してください : %s", to)
+		}
+		filter.to = filter.to.AddDate(0, 0, 1)
+	}
+	if order != "" {
+		filter.order, err = strconv.ParseInt(order, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("-order はオーダー番号またはオーダー ID を数値で指定してください : %s", order)
+		}
+	}
+	return filter, nil
+}
+
+func (f *auditFilter) match(entry audit.Entry) bool {
+	if !f.from.IsZero() && entry.Time.Before(f.from) {
+		return false
+	}
+	if !f.to.IsZero() && !entry.Time.Before(f.to) {
+		return false
+	}
+	if f.order != 0 && entry.OrderID != f.order && int64(entry.OrderNumber) != f.order {
+		return false
+	}
+	return true
+}
+
+func writeAuditReport(filePath string, entries []audit.Entry) error {
+	file := xlsx.NewFile()
+	sheet, err := file.AddSheet("audit")
+	if err != nil {
+		return err
+	}
+
+	header := sheet.AddRow()
+	for _, title := range []string{"Seq", "Time", "User", "Host", "Store", "Action", "OrderNumber", "OrderID", "TransactionID", "DraftOrderID", "Status", "RequestID", "Error", "RequestBody"} {
+		header.AddCell().SetString(title)
+	}
+	for _, entry := range entries {
+		row := sheet.AddRow()
+		row.AddCell().SetInt64(entry.Seq)
+		row.AddCell().SetString(entry.Time.Local().Format("2006-01-02 15:04:05"))
+		row.AddCell().SetString(entry.User)
+		row.AddCell().SetString(entry.Host)
+		row.AddCell().SetString(entry.Store)
+		row.AddCell().SetString(entry.Action)
+		row.AddCell().SetInt(entry.OrderNumber)
+		row.AddCell().SetInt64(entry.OrderID)
+		row.AddCell().SetInt64(entry.TransactionID)
+		row.AddCell().SetInt64(entry.DraftOrderID)
+		row.AddCell().SetInt(entry.Status)
+		row.AddCell().SetString(entry.RequestID)
+		row.AddCell().SetString(entry.Error)
+		row.AddCell().SetString(entry.RequestBody)
+	}
+
+	err = storage.SaveXlsx(file, filePath)
+	if err != nil {
+		log.Printf("%sの保存に失敗", filePath)
+		return err
+	}
+	log.Printf("INFO : 監査レポートを %s に出力しました\n", filePath)
+	return nil
+}
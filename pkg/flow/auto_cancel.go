@@ -0,0 +1,164 @@
+package flow
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/output"
+	"shopify-manager/pkg/rule"
+	"shopify-manager/pkg/storage"
+
+	"github.com/tealeg/xlsx"
+)
+
+// AutoCancel は直近のオーダーをルールで判定し、一致したオーダーを提案、
+// または execute が有効な場合はオーソリ取消・キャンセルする。
+func AutoCancel(config *config.Config, storeName string, execute bool) {
+
+	err := runFlow(config, constants.FLOW_TYPE_AUTO_CANCEL, func(sink shopify.Sink) error {
+		return autoCancel(config, storeName, execute || config.AutoCancel.Execute, sink)
+	})
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+
+	log.Println("自動キャンセル処理成功")
+}
+
+// MatchResult は JSON 出力モードで書き出すルールに一致したオーダー。
+// Executed が true の場合、キャンセル結果は別途 order レコードとして出力される。
+type MatchResult struct {
+	Type        string   `json:"type"`
+	Store       string   `json:"store"`
+	OrderNumber int      `json:"order_number"`
+	OrderID     int64    `json:"order_id"`
+	Rule        string   `json:"rule"`
+	Reasons     []string `json:"reasons"`
+	Executed    bool     `json:"executed"`
+}
+
+func autoCancel(config *config.Config, storeName string, execute bool, sink shopify.Sink) error {
+	if len(config.AutoCancel.Rules) == 0 {
+		return fmt.Errorf("AutoCancel.Rules が設定されていません")
+	}
+
+	store, err := config.GetStore(storeName)
+	if err != nil {
+		return err
+	}
+
+	scanHours := config.AutoCancel.ScanHours
+	query := &shopify.OrderQuery{
+		Status:       "open",
+		CreatedAtMin: time.Now().Add(-time.Duration(scanHours) * time.Hour),
+	}
+
+	client, err := shopify.NewClient(store)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("INFO : Scan orders created in last %d hours in store '%s'\n", scanHours, store.Name)
+	orders, err := client.SearchOrders(query)
+	if err != nil {
+		return err
+	}
+
+	matches := rule.Evaluate(config.AutoCancel.Rules, toRuleOrders(orders))
+	log.Printf("INFO : %d of %d orders matched auto cancel rules\n", len(matches), len(orders))
+	if len(matches) == 0 {
+		return nil
+	}
+
+	var orderNumberList []int
+	for _, match := range matches {
+		log.Printf("INFO : orderNumber '%d' matched rule '%s' (%s)\n", match.Order.OrderNumber, match.Rule, strings.Join(match.Reasons, ", "))
+		output.Write(MatchResult{
+			Type:        output.TYPE_MATCH,
+			Store:       store.Name,
+			OrderNumber: match.Order.OrderNumber,
+			OrderID:     match.Order.ID,
+			Rule:        match.Rule,
+			Reasons:     match.Reasons,
+			Executed:    execute,
+		})
+		orderNumberList = append(orderNumberList, match.Order.OrderNumber)
+	}
+
+	if !execute {
+		err = writeProposal(constants.AUTO_CANCEL_PROPOSAL_FILE_PATH, store.Name, matches)
+		if err != nil {
+			return err
+		}
+		log.Printf("INFO : キャンセル候補を %s に出力しました。確認後 %s にコピーして cancel-order を実行してください\n", constants.AUTO_CANCEL_PROPOSAL_FILE_PATH, constants.INPUT_EXCEL_FILE_PATH)
+		return nil
+	}
+
+	return shopify.CancelOrderNumbersWithSink(orderNumberList, store, &config.Safety, sink)
+}
+
+func toRuleOrders(orders []shopify.Order) []rule.Order {
+	var ruleOrders []rule.Order
+	for _, order := range orders {
+		customerKey := strings.ToLower(order.Email)
+		if order.Customer != nil && order.Customer.ID != 0 {
+			customerKey = strconv.FormatInt(order.Customer.ID, 10)
+		}
+
+		var cvvResultCode, avsResultCode string
+		if order.PaymentDetails != nil {
+			cvvResultCode = order.PaymentDetails.CvvResultCode.String()
+			avsResultCode = order.PaymentDetails.AvsResultCode.String()
+		}
+
+		ruleOrders = append(ruleOrders, rule.Order{
+			ID:            order.ID,
+			OrderNumber:   order.OrderNumber,
+			Email:         order.Email,
+			CustomerKey:   customerKey,
+			Test:          order.Test,
+			BrowserIP:     order.BrowserIP,
+			CvvResultCode: cvvResultCode,
+			AvsResultCode: avsResultCode,
+			TotalPrice:    order.TotalPrice.Float64(),
+			CreatedAt:     order.CreatedAt.Time,
+		})
+	}
+	return ruleOrders
+}
+
+// writeProposal は入力エクセルと同じ形式 (A列がオーダー番号、B列がストア名) でキャンセル候補を出力する。
+func writeProposal(filePath, storeName string, matches []rule.Match) error {
+	file := xlsx.NewFile()
+	sheet, err := file.AddSheet("proposal")
+	if err != nil {
+		return err
+	}
+
+	header := sheet.AddRow()
+	header.AddCell().SetString("OrderID")
+	header.AddCell().SetString("Store")
+	header.AddCell().SetString("Rule")
+	header.AddCell().SetString("Reason")
+	for _, match := range matches {
+		row := sheet.AddRow()
+		row.AddCell().SetInt(match.Order.OrderNumber)
+		row.AddCell().SetString(storeName)
+		row.AddCell().SetString(match.Rule)
+		row.AddCell().SetString(strings.Join(match.Reasons, ", "))
+	}
+
+	err = storage.SaveXlsx(file, filePath)
+	if err != nil {
+		log.Printf("%sの保存に失敗", filePath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package flow
+
+import (
+	"log"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+)
+
+func CancelLineItems(config *config.Config, storeName string) {
+
+	err := runFlow(config, constants.FLOW_TYPE_CANCEL_LINE_ITEMS, func(sink shopify.Sink) error {
+		return shopify.CancelLineItems(config, storeName, sink)
+	})
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+
+	log.Println("行単位キャンセル処理成功")
+}
@@ -5,11 +5,14 @@ import (
 
 	"shopify-manager/pkg/api/shopify"
 	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
 )
 
-func CancelOrders(config *config.Config) {
+func CancelOrders(config *config.Config, storeName, query string) {
 
-	err := shopify.CancelOrders(config)
+	err := runFlow(config, constants.FLOW_TYPE_CREATE_INSTANCE, func(sink shopify.Sink) error {
+		return shopify.CancelOrders(config, storeName, query, sink)
+	})
 	if err != nil {
 		log.Printf("ERROR: %s\n", err.Error())
 		return
@@ -0,0 +1,134 @@
+package flow
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/output"
+)
+
+// オーダー取得・キャンセル・オーソリ取消・返金に必要なスコープ
+var requiredAccessScopes = []string{"read_orders", "write_orders"}
+
+// ストアの restock 毎に追加で必要なアクセススコープ
+var restockAccessScopes = map[string][]string{
+	config.RESTOCK_MODE_ADJUST: {"read_products", "read_inventory", "write_inventory"},
+}
+
+// ConfigCheck は設定ファイルの検証結果を表示し、各ストアに変更を伴わない API 呼び出し
+// (ショップ情報とアクセススコープの取得) を行って認証情報と権限を確認する。
+// storeName が空の場合は全ストアを確認する。
+func ConfigCheck(config *config.Config, configPath, storeName string) {
+
+	log.Printf("INFO : %s の検証に成功しました\n", configPath)
+
+	err := runFlow(config, constants.FLOW_TYPE_CONFIG_CHECK, func(sink shopify.Sink) error {
+		return configCheck(config, storeName)
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	log.Println("設定チェック成功")
+}
+
+// configCheck は各ストアの接続を確認し、1つでも失敗した場合はエラーを返す。
+func configCheck(config *config.Config, storeName string) error {
+	storeNames := config.StoreNames()
+	if storeName != "" {
+		storeNames = []string{storeName}
+	}
+
+	isSuccess := true
+	for _, name := range storeNames {
+		result := &StoreResult{Type: output.TYPE_STORE, Store: name}
+		err := checkStore(config, name, result)
+		if err != nil {
+			log.Printf("ERROR: store '%s' %s\n", name, err.Error())
+			isSuccess = false
+			result.Error = err.Error()
+		}
+		result.Success = err == nil
+		output.Write(result)
+	}
+
+	if !isSuccess {
+		return fmt.Errorf("設定チェック失敗")
+	}
+	return nil
+}
+
+// StoreResult は JSON 出力モードで書き出すストア毎の確認結果。
+type StoreResult struct {
+	Type         string   `json:"type"`
+	Store        string   `json:"store"`
+	ShopName     string   `json:"shop_name,omitempty"`
+	Domain       string   `json:"domain,omitempty"`
+	PlanName     string   `json:"plan_name,omitempty"`
+	Currency     string   `json:"currency,omitempty"`
+	AccessScopes []string `json:"access_scopes,omitempty"`
+	Success      bool     `json:"success"`
+	Error        string   `json:"error,omitempty"`
+}
+
+func checkStore(config *config.Config, storeName string, result *StoreResult) error {
+	store, err := config.GetStore(storeName)
+	if err != nil {
+		return err
+	}
+
+	if store.HasPlaceholderCredential() {
+		return fmt.Errorf("apiKey / apiPassword がサンプル値のままです。ストアの認証情報を設定してください")
+	}
+
+	client, err := shopify.NewClient(store)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("INFO : Try to get shop '%s' (backend %s, api version %s)\n", store.Domain, store.Backend, store.ApiVersion)
+	shop, err := client.GetShop()
+	if err != nil {
+		return fmt.Errorf("ショップ情報を取得できませんでした。認証情報を確認してください。%s", err.Error())
+	}
+	log.Printf("INFO : store '%s' : %s (%s) plan %s, currency %s\n", storeName, shop.Name, shop.MyshopifyDomain, shop.PlanName, shop.Currency)
+	result.ShopName = shop.Name
+	result.Domain = shop.MyshopifyDomain
+	result.PlanName = shop.PlanName
+	result.Currency = shop.Currency
+
+	if shop.Currency != "" && shop.Currency != store.Currency {
+		log.Printf("WARN : store '%s' の currency %s がショップの通貨 %s と異なります\n", storeName, store.Currency, shop.Currency)
+	}
+
+	scopes, err := client.GetAccessScopes()
+	if err != nil {
+		return fmt.Errorf("アクセススコープを取得できませんでした。%s", err.Error())
+	}
+	result.AccessScopes = scopes
+
+	var missing []string
+	for _, required := range append(append([]string{}, requiredAccessScopes...), restockAccessScopes[store.Restock]...) {
+		found := false
+		for _, scope := range scopes {
+			if scope == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, required)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("アクセススコープが不足しています : %s", strings.Join(missing, ", "))
+	}
+	log.Printf("INFO : store '%s' access scopes : %s\n", storeName, strings.Join(scopes, ", "))
+
+	return nil
+}
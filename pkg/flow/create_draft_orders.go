@@ -0,0 +1,22 @@
+package flow
+
+import (
+	"log"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+)
+
+func CreateDraftOrders(config *config.Config, storeName string) {
+
+	err := runFlow(config, constants.FLOW_TYPE_CREATE_DRAFT_ORDERS, func(sink shopify.Sink) error {
+		return shopify.CreateDraftOrders(config, storeName, sink)
+	})
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+
+	log.Println("下書き注文作成処理成功")
+}
@@ -0,0 +1,113 @@
+package flow
+
+import (
+	"log"
+	"strings"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/output"
+	"shopify-manager/pkg/storage"
+
+	"github.com/tealeg/xlsx"
+)
+
+// DetectDuplicates は複数のアカウントで購入している同一人物らしきオーダーのまとまりを
+// duplicate-clusters.xlsx に出力する。オーダーは変更しない。
+func DetectDuplicates(config *config.Config, storeName, query string) {
+
+	err := runFlow(config, constants.FLOW_TYPE_DETECT_DUPLICATES, func(sink shopify.Sink) error {
+		return detectDuplicates(config, storeName, query)
+	})
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+
+	log.Println("重複購入者の検出成功")
+}
+
+// ClusterResult は JSON 出力モードで書き出す1つのまとまり。
+type ClusterResult struct {
+	Type         string   `json:"type"`
+	Store        string   `json:"store"`
+	Cluster      int      `json:"cluster"`
+	OrderNumbers []int    `json:"order_numbers"`
+	Accounts     int      `json:"accounts"`
+	MatchedKeys  []string `json:"matched_keys"`
+}
+
+func detectDuplicates(config *config.Config, storeName, query string) error {
+	store, err := config.GetStore(storeName)
+	if err != nil {
+		return err
+	}
+
+	report, err := shopify.FindDuplicateClusters(query, &config.DuplicateCheck, store)
+	if err != nil {
+		return err
+	}
+
+	for i, cluster := range report.Clusters {
+		result := ClusterResult{Type: output.TYPE_CLUSTER, Store: store.Name, Cluster: i + 1, Accounts: cluster.Accounts, MatchedKeys: cluster.MatchedKeys}
+		for _, order := range cluster.Orders {
+			result.OrderNumbers = append(result.OrderNumbers, order.OrderNumber)
+		}
+		log.Printf("INFO : cluster %d : %d accounts, orders %v (%s)\n", result.Cluster, cluster.Accounts, result.OrderNumbers, strings.Join(cluster.MatchedKeys, ", "))
+		output.Write(result)
+	}
+
+	err = writeDuplicateClusters(constants.DUPLICATE_CLUSTERS_FILE_PATH, store.Name, report)
+	if err != nil {
+		return err
+	}
+	log.Printf("INFO : %d 件のまとまりを %s に出力しました。確認後 %s にコピーして cancel-order を実行できます\n", len(report.Clusters), constants.DUPLICATE_CLUSTERS_FILE_PATH, constants.INPUT_EXCEL_FILE_PATH)
+	return nil
+}
+
+// writeDuplicateClusters はまとまり毎のオーダーを、入力エクセルと同じ形式 (A列がオーダー番号、B列がストア名) で出力する。
+func writeDuplicateClusters(filePath, storeName string, report *shopify.DuplicateReport) error {
+	file := xlsx.NewFile()
+	sheet, err := file.AddSheet("clusters")
+	if err != nil {
+		return err
+	}
+
+	header := sheet.AddRow()
+	for _, title := range []string{"OrderID", "Store", "Cluster", "Accounts", "MatchedKeys", "Customer", "Email", "Name", "Phone", "Address", "BrowserIP", "CreatedAt", "TotalPrice"} {
+		header.AddCell().SetString(title)
+	}
+	for i, cluster := range report.Clusters {
+		for _, clusterOrder := range cluster.Orders {
+			order := report.Orders[clusterOrder.OrderNumber]
+			var name, address string
+			if order.ShippingAddress != nil {
+				name = order.ShippingAddress.Name
+				address = strings.Join(strings.Fields(strings.Join([]string{order.ShippingAddress.Zip, order.ShippingAddress.Province, order.ShippingAddress.City, order.ShippingAddress.Address1, order.ShippingAddress.Address2}, " ")), " ")
+			}
+
+			row := sheet.AddRow()
+			row.AddCell().SetInt(order.OrderNumber)
+			row.AddCell().SetString(storeName)
+			row.AddCell().SetInt(i + 1)
+			row.AddCell().SetInt(cluster.Accounts)
+			row.AddCell().SetString(strings.Join(cluster.MatchedKeys, ", "))
+			row.AddCell().SetString(clusterOrder.CustomerKey)
+			row.AddCell().SetString(order.Email)
+			row.AddCell().SetString(name)
+			row.AddCell().SetString(order.Phone)
+			row.AddCell().SetString(address)
+			row.AddCell().SetString(order.BrowserIP)
+			row.AddCell().SetString(order.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+			row.AddCell().SetString(order.TotalPrice.String())
+		}
+	}
+
+	err = storage.SaveXlsx(file, filePath)
+	if err != nil {
+		log.Printf("%sの保存に失敗", filePath)
+		return err
+	}
+	return nil
+}
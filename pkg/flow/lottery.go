@@ -0,0 +1,139 @@
+package flow
+
+import (
+	"log"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/lottery"
+	"shopify-manager/pkg/output"
+	"shopify-manager/pkg/storage"
+
+	"github.com/tealeg/xlsx"
+)
+
+// Lottery は [Lottery] の条件で抽選販売の当選者を選び、当選・落選の一覧を出力する。
+// execute が有効な場合は落選したオーダーをオーソリ取消・キャンセルする。
+func Lottery(config *config.Config, storeName string, execute bool) {
+
+	err := runFlow(config, constants.FLOW_TYPE_LOTTERY, func(sink shopify.Sink) error {
+		return drawLottery(config, storeName, execute, sink)
+	})
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+
+	log.Println("抽選処理成功")
+}
+
+// LotteryResult は JSON 出力モードで書き出す1オーダーの抽選結果。
+// Executed が true の場合、落選したオーダーのキャンセル結果は別途 order レコードとして出力される。
+type LotteryResult struct {
+	Type        string `json:"type"`
+	Store       string `json:"store"`
+	OrderNumber int    `json:"order_number"`
+	OrderID     int64  `json:"order_id"`
+	Rank        int    `json:"rank"`
+	Won         bool   `json:"won"`
+	Reason      string `json:"reason"`
+	Seed        int64  `json:"seed"`
+	Executed    bool   `json:"executed"`
+}
+
+func drawLottery(config *config.Config, storeName string, execute bool, sink shopify.Sink) error {
+	store, err := config.GetStore(storeName)
+	if err != nil {
+		return err
+	}
+
+	draw, err := shopify.DrawLottery(&config.Lottery, store)
+	if err != nil {
+		return err
+	}
+
+	var winners, losers []lottery.Result
+	var loserOrderNumberList []int
+	for _, result := range draw.Results {
+		output.Write(LotteryResult{
+			Type:        output.TYPE_LOTTERY,
+			Store:       store.Name,
+			OrderNumber: result.OrderNumber,
+			OrderID:     result.OrderID,
+			Rank:        result.Rank,
+			Won:         result.Won,
+			Reason:      result.Reason,
+			Seed:        draw.Seed,
+			Executed:    execute && !result.Won,
+		})
+		if result.Won {
+			winners = append(winners, result)
+		} else {
+			losers = append(losers, result)
+			loserOrderNumberList = append(loserOrderNumberList, result.OrderNumber)
+		}
+	}
+	log.Printf("INFO : store '%s' seed %d : 応募 %d 件、当選 %d 件、落選 %d 件\n", store.Name, draw.Seed, len(draw.Results), len(winners), len(losers))
+
+	err = writeLotteryResult(constants.LOTTERY_RESULT_FILE_PATH, store.Name, draw, winners, losers)
+	if err != nil {
+		return err
+	}
+
+	if !execute {
+		log.Printf("INFO : 抽選結果を %s に出力しました。確認後 losers シートを %s にコピーして cancel-order を実行するか、-execute で落選したオーダーをキャンセルしてください\n", constants.LOTTERY_RESULT_FILE_PATH, constants.INPUT_EXCEL_FILE_PATH)
+		return nil
+	}
+	if len(loserOrderNumberList) == 0 {
+		return nil
+	}
+
+	return shopify.CancelOrderNumbersWithSink(loserOrderNumberList, store, &config.Safety, sink)
+}
+
+// writeLotteryResult は当選・落選を別のシートに、入力エクセルと同じ形式 (A列がオーダー番号、B列がストア名) で出力する。
+func writeLotteryResult(filePath, storeName string, draw *shopify.LotteryDraw, winners, losers []lottery.Result) error {
+	file := xlsx.NewFile()
+	for _, sheetResults := range []struct {
+		name    string
+		results []lottery.Result
+	}{
+		{"winners", winners},
+		{"losers", losers},
+	} {
+		sheet, err := file.AddSheet(sheetResults.name)
+		if err != nil {
+			return err
+		}
+
+		header := sheet.AddRow()
+		for _, title := range []string{"OrderID", "Store", "Rank", "Reason", "Email", "Name", "CreatedAt", "Seed"} {
+			header.AddCell().SetString(title)
+		}
+		for _, result := range sheetResults.results {
+			order := draw.Orders[result.OrderNumber]
+			name := ""
+			if order.ShippingAddress != nil {
+				name = order.ShippingAddress.Name
+			}
+
+			row := sheet.AddRow()
+			row.AddCell().SetInt(result.OrderNumber)
+			row.AddCell().SetString(storeName)
+			row.AddCell().SetInt(result.Rank)
+			row.AddCell().SetString(result.Reason)
+			row.AddCell().SetString(order.Email)
+			row.AddCell().SetString(name)
+			row.AddCell().SetString(order.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+			row.AddCell().SetInt64(draw.Seed)
+		}
+	}
+
+	err := storage.SaveXlsx(file, filePath)
+	if err != nil {
+		log.Printf("%sの保存に失敗", filePath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package flow
+
+import (
+	"fmt"
+	"log"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/audit"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/output"
+	"shopify-manager/pkg/snapshot"
+)
+
+// Revert は監査ファイルに記録されたキャンセル・オーソリ取消を、可能な範囲で元に戻す。
+// 対象は -from / -to / -order で絞り込み (どちらかの指定が必須)、execute が false の場合は確認のみ行う。
+func Revert(config *config.Config, storeName, from, to, order string, execute bool) {
+
+	err := runFlow(config, constants.FLOW_TYPE_REVERT, func(sink shopify.Sink) error {
+		return revert(config, storeName, from, to, order, execute)
+	})
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+
+	if !execute {
+		log.Println("取り消しの確認成功。-execute を指定すると実行します")
+		return
+	}
+	log.Println("取り消し成功")
+}
+
+// revertKey は監査記録のストアとオーダー ID。
+type revertKey struct {
+	store   string
+	orderId int64
+}
+
+func revert(config *config.Config, storeName, from, to, order string, execute bool) error {
+	if from == "" && order == "" {
+		return fmt.Errorf("取り消すオーダーを -from または -order で指定してください")
+	}
+	filter, err := newAuditFilter(from, to, order)
+	if err != nil {
+		return err
+	}
+	if storeName != "" {
+		if _, err := config.GetStore(storeName); err != nil {
+			return err
+		}
+	}
+
+	entries, err := audit.Read(audit.AUDIT_FILE_PATH)
+	if err != nil {
+		return err
+	}
+	err = audit.Verify(entries)
+	if err != nil {
+		log.Printf("WARN : 監査ファイルの検証に失敗しました。%s\n", err.Error())
+	}
+
+	var keys []revertKey
+	targets := map[revertKey]*shopify.RevertTarget{}
+	reverted := map[revertKey]bool{}
+	for _, entry := range entries {
+		if entry.Error != "" || entry.Status < 200 || entry.Status >= 300 || entry.OrderID == 0 {
+			continue
+		}
+		if storeName != "" && entry.Store != storeName {
+			continue
+		}
+		key := revertKey{store: entry.Store, orderId: entry.OrderID}
+
+		switch entry.Action {
+		case shopify.AUDIT_ACTION_REOPEN, shopify.AUDIT_ACTION_CREATE_DRAFT_ORDER:
+			reverted[key] = true
+			continue
+		case shopify.AUDIT_ACTION_VOID, shopify.AUDIT_ACTION_CANCEL:
+			// 取り消した後に再度キャンセルされた場合は、改めて取り消しの対象にする
+			delete(reverted, key)
+		default:
+			continue
+		}
+		if !filter.match(entry) {
+			continue
+		}
+
+		target, ok := targets[key]
+		if !ok {
+			target = &shopify.RevertTarget{OrderNumber: entry.OrderNumber, OrderID: entry.OrderID}
+			targets[key] = target
+			keys = append(keys, key)
+		}
+		if entry.Action == shopify.AUDIT_ACTION_VOID {
+			target.Voided = true
+		} else {
+			target.CancelledAt = entry.Time
+		}
+	}
+	log.Printf("INFO : 取り消し対象のオーダーは %d 件です\n", len(keys))
+
+	isSuccess := true
+	for _, key := range keys {
+		target := targets[key]
+		if reverted[key] {
+			log.Printf("INFO : store '%s' orderNumber '%d' は取り消し済みのためスキップします\n", key.store, target.OrderNumber)
+			output.Write(&shopify.RevertResult{Type: output.TYPE_REVERT, Store: key.store, OrderNumber: target.OrderNumber, OrderID: target.OrderID, Action: shopify.REVERT_ACTION_NONE, Status: output.STATUS_SKIPPED, Step: "already reverted"})
+			continue
+		}
+
+		store, err := config.GetStore(key.store)
+		if err != nil {
+			log.Printf("ERROR : orderNumber '%d' failed to revert. %s\n", target.OrderNumber, err.Error())
+			isSuccess = false
+			output.Write(&shopify.RevertResult{Type: output.TYPE_REVERT, Store: key.store, OrderNumber: target.OrderNumber, OrderID: target.OrderID, Action: shopify.REVERT_ACTION_NONE, Status: output.STATUS_FAILED, Step: "get store", Error: err.Error()})
+			continue
+		}
+
+		target.Snapshot, err = findRevertSnapshot(store.Name, target)
+		if err != nil {
+			log.Printf("WARN : orderNumber '%d' のスナップショットを読み込めません。%s\n", target.OrderNumber, err.Error())
+		}
+
+		result := shopify.RevertOrder(target, store, execute)
+		if result.Status == output.STATUS_FAILED {
+			isSuccess = false
+		}
+		output.Write(result)
+	}
+
+	if !isSuccess {
+		return fmt.Errorf("取り消しできなかったオーダーがあります")
+	}
+	return nil
+}
+
+// findRevertSnapshot はキャンセル前に保存した最新のスナップショットを返す。無い場合は nil。
+func findRevertSnapshot(storeName string, target *shopify.RevertTarget) (*snapshot.Snapshot, error) {
+	snapshots, err := snapshot.Find(storeName, target.OrderNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *snapshot.Snapshot
+	for _, s := range snapshots {
+		if s.OrderID != target.OrderID {
+			continue
+		}
+		if !target.CancelledAt.IsZero() && s.TakenAt.After(target.CancelledAt) {
+			continue
+		}
+		found = s
+	}
+	if found != nil {
+		log.Printf("INFO : orderNumber '%d' のスナップショット %s を使います\n", target.OrderNumber, found.Source)
+	}
+	return found, nil
+}
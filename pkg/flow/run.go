@@ -0,0 +1,22 @@
+package flow
+
+import (
+	"time"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/notify"
+	"shopify-manager/pkg/output"
+)
+
+// runFlow は run を実行し、フローの結果の出力 (-output json) と [[Notifiers]] への通知を行う。
+func runFlow(config *config.Config, flow string, run func(sink shopify.Sink) error) error {
+	startedAt := time.Now()
+	sink := &shopify.RecordingSink{}
+
+	err := run(sink)
+
+	output.Finish(flow, err)
+	notify.Send(config.Notifiers, notify.NewSummary(flow, startedAt, err, sink.Results()))
+	return err
+}
@@ -0,0 +1,64 @@
+package flow
+
+import (
+	"fmt"
+	"log"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/schedule"
+	"shopify-manager/pkg/storage"
+)
+
+// Schedule は [[Schedule.Jobs]] のフローを cron 形式の時刻に実行し続ける。
+// 実行は1件ずつ行い、結果は [Schedule] historyPath に記録する。
+func Schedule(config *config.Config) {
+
+	err := runSchedule(config)
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+}
+
+func runSchedule(config *config.Config) error {
+	if len(config.Schedule.Jobs) == 0 {
+		return fmt.Errorf("[[Schedule.Jobs]] が設定されていません")
+	}
+
+	// 定期実行では確認入力ができないため、[Safety] の上限のみ適用する
+	config.Safety.AssumeYes = true
+
+	scheduler := schedule.New(config.Schedule.HistoryPath, storage.AppendFile)
+	for _, job := range config.Schedule.Jobs {
+		err := scheduler.Add(job.Name, job.Cron, scheduledFlow(config, job))
+		if err != nil {
+			return err
+		}
+	}
+
+	scheduler.Start()
+	return nil
+}
+
+// scheduledFlow は job のフローを実行する関数を返す。
+func scheduledFlow(config *config.Config, job config.ScheduleJob) func() error {
+	return func() error {
+		return runFlow(config, job.Flow, func(sink shopify.Sink) error {
+			switch job.Flow {
+			case constants.FLOW_TYPE_CREATE_INSTANCE:
+				return shopify.CancelOrders(config, job.Store, job.Query, sink)
+			case constants.FLOW_TYPE_CANCEL_LINE_ITEMS:
+				return shopify.CancelLineItems(config, job.Store, sink)
+			case constants.FLOW_TYPE_AUTO_CANCEL:
+				return autoCancel(config, job.Store, job.Execute || config.AutoCancel.Execute, sink)
+			case constants.FLOW_TYPE_CONFIG_CHECK:
+				return configCheck(config, job.Store)
+			case constants.FLOW_TYPE_AUDIT_REPORT:
+				return auditReport("", "", "")
+			}
+			return fmt.Errorf("flow '%s' はスケジュール実行できません", job.Flow)
+		})
+	}
+}
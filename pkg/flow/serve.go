@@ -0,0 +1,165 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/rule"
+	"shopify-manager/pkg/storage"
+	"shopify-manager/pkg/webhook"
+)
+
+// Serve は Shopify の Webhook (orders/create, orders/updated, orders/cancelled) を受信するサーバーを起動し、
+// [[Serve.Actions]] の設定に従ってルールによる自動キャンセルやイベントログへの記録を行う。
+// execute が無効な場合、自動キャンセルはルールに一致したオーダーをログに出すのみ。
+func Serve(config *config.Config, execute bool) {
+
+	err := serve(config, execute || config.AutoCancel.Execute)
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+}
+
+// webhookActions は [[Serve.Actions]] の action 名と処理。
+var webhookActions = map[string]func(config *config.Config, execute bool) webhook.Action{
+	config.WEBHOOK_ACTION_AUTO_CANCEL: webhookAutoCancel,
+	config.WEBHOOK_ACTION_EVENT_LOG:   webhookEventLog,
+}
+
+func serve(config *config.Config, execute bool) error {
+	actions := map[string]webhook.Action{}
+	for name, newAction := range webhookActions {
+		actions[name] = newAction(config, execute)
+	}
+
+	handler, err := webhook.NewHandler(config, actions)
+	if err != nil {
+		return err
+	}
+	go handler.Run()
+
+	mux := http.NewServeMux()
+	mux.Handle(webhook.WEBHOOK_PATH, handler)
+
+	log.Printf("INFO : Webhook を %s%s で待ち受けます\n", config.Serve.Listen, webhook.WEBHOOK_PATH)
+	return http.ListenAndServe(config.Serve.Listen, mux)
+}
+
+// webhookAutoCancel は Webhook のオーダーを [AutoCancel] のルールで判定し、
+// 一致した場合は execute が有効ならオーソリ取消・キャンセルする。
+func webhookAutoCancel(config *config.Config, execute bool) webhook.Action {
+	return func(event *webhook.Event) error {
+		order := event.Order
+		if !order.CancelledAt.IsZero() {
+			return nil
+		}
+		if len(config.AutoCancel.Rules) == 0 {
+			return fmt.Errorf("AutoCancel.Rules が設定されていません")
+		}
+
+		// 顧客毎のオーダー数のルールは、期間内の他のオーダーも合わせて判定する
+		orders := []shopify.Order{order}
+		windowHours := customerWindowHours(config.AutoCancel.Rules)
+		if windowHours > 0 {
+			client, err := shopify.NewClient(event.Store)
+			if err != nil {
+				return err
+			}
+			recent, err := client.SearchOrders(&shopify.OrderQuery{
+				Status:       "open",
+				CreatedAtMin: time.Now().Add(-time.Duration(windowHours) * time.Hour),
+			})
+			if err != nil {
+				return err
+			}
+			for _, recentOrder := range recent {
+				if recentOrder.ID != order.ID {
+					orders = append(orders, recentOrder)
+				}
+			}
+		}
+
+		var match *rule.Match
+		for _, m := range rule.Evaluate(config.AutoCancel.Rules, toRuleOrders(orders)) {
+			if m.Order.ID == order.ID {
+				match = &m
+				break
+			}
+		}
+		if match == nil {
+			return nil
+		}
+
+		log.Printf("INFO : orderNumber '%d' matched rule '%s' (%s)\n", order.OrderNumber, match.Rule, strings.Join(match.Reasons, ", "))
+		if !execute {
+			log.Printf("INFO : orderNumber '%d' はキャンセル候補です (-execute または [AutoCancel] execute でキャンセルします)\n", order.OrderNumber)
+			return nil
+		}
+
+		// サーバーでは確認入力ができないため、安全上限のみ適用する
+		safety := config.Safety
+		safety.AssumeYes = true
+		return shopify.CancelOrderNumbers([]int{order.OrderNumber}, event.Store, &safety)
+	}
+}
+
+func customerWindowHours(rules []config.Rule) int {
+	windowHours := 0
+	for _, rule := range rules {
+		if rule.MaxOrdersPerCustomer > 0 && rule.WindowHours > windowHours {
+			windowHours = rule.WindowHours
+		}
+	}
+	return windowHours
+}
+
+// WebhookEventLog はイベントログ (JSON Lines) の1行。
+type WebhookEventLog struct {
+	Time            time.Time `json:"time"`
+	Topic           string    `json:"topic"`
+	WebhookID       string    `json:"webhook_id"`
+	Store           string    `json:"store"`
+	OrderID         int64     `json:"order_id"`
+	OrderNumber     int       `json:"order_number"`
+	FinancialStatus string    `json:"financial_status"`
+	TotalPrice      string    `json:"total_price"`
+	Currency        string    `json:"currency"`
+	Test            bool      `json:"test"`
+	Cancelled       bool      `json:"cancelled"`
+}
+
+// webhookEventLog は Webhook のオーダーの概要を [Serve] eventLogPath に追記する。
+func webhookEventLog(config *config.Config, execute bool) webhook.Action {
+	path := config.Serve.EventLogPath
+	return func(event *webhook.Event) error {
+		jsonBytes, err := json.Marshal(WebhookEventLog{
+			Time:            event.ReceivedAt,
+			Topic:           event.Topic,
+			WebhookID:       event.WebhookID,
+			Store:           event.Store.Name,
+			OrderID:         event.Order.ID,
+			OrderNumber:     event.Order.OrderNumber,
+			FinancialStatus: event.Order.FinancialStatus,
+			TotalPrice:      event.Order.TotalPrice.String(),
+			Currency:        event.Order.Currency,
+			Test:            event.Order.Test,
+			Cancelled:       !event.Order.CancelledAt.IsZero(),
+		})
+		if err != nil {
+			return err
+		}
+
+		err = storage.AppendFile(path, append(jsonBytes, '\n'))
+		if err != nil {
+			log.Printf("%sへの追記に失敗", storage.Location(path))
+		}
+		return err
+	}
+}
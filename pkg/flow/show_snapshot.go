@@ -0,0 +1,132 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/constants"
+	"shopify-manager/pkg/output"
+	"shopify-manager/pkg/snapshot"
+)
+
+// ShowSnapshot は変更を行う前に保存したオーダーの状態 (金額・住所・商品・取引) を表示する。
+// 同じオーダーのスナップショットが複数ある場合は古い順に全て表示する。
+func ShowSnapshot(config *config.Config, storeName, order string) {
+
+	err := runFlow(config, constants.FLOW_TYPE_SHOW_SNAPSHOT, func(sink shopify.Sink) error {
+		return showSnapshot(config, storeName, order)
+	})
+	if err != nil {
+		log.Printf("ERROR: %s\n", err.Error())
+		return
+	}
+
+	log.Println("スナップショット表示成功")
+}
+
+// SnapshotResult は JSON 出力モードで書き出すスナップショット1件。Order / Transactions は保存した JSON そのまま。
+type SnapshotResult struct {
+	Type         string          `json:"type"`
+	Store        string          `json:"store"`
+	OrderNumber  int             `json:"order_number"`
+	OrderID      int64           `json:"order_id"`
+	TakenAt      time.Time       `json:"taken_at"`
+	Source       string          `json:"source"`
+	Order        json.RawMessage `json:"order"`
+	Transactions json.RawMessage `json:"transactions"`
+}
+
+func showSnapshot(config *config.Config, storeName, order string) error {
+	store, err := config.GetStore(storeName)
+	if err != nil {
+		return err
+	}
+	orderNumber, err := strconv.Atoi(order)
+	if err != nil {
+		return fmt.Errorf("-order はオーダー番号を数値で指定してください : %s", order)
+	}
+
+	snapshots, err := snapshot.Find(store.Name, orderNumber)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("store '%s' orderNumber '%d' のスナップショットはありません", store.Name, orderNumber)
+	}
+
+	for _, s := range snapshots {
+		output.Write(SnapshotResult{
+			Type:         output.TYPE_SNAPSHOT,
+			Store:        s.Store,
+			OrderNumber:  s.OrderNumber,
+			OrderID:      s.OrderID,
+			TakenAt:      s.TakenAt,
+			Source:       s.Source,
+			Order:        s.Order,
+			Transactions: s.Transactions,
+		})
+		if !output.IsJSON() {
+			err = printSnapshot(s)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	log.Printf("INFO : %d 件のスナップショットがあります\n", len(snapshots))
+	return nil
+}
+
+// printSnapshot はスナップショットの主な項目を読みやすい形で表示する。全項目は -output json で確認する。
+func printSnapshot(s *snapshot.Snapshot) error {
+	var order shopify.Order
+	err := json.Unmarshal(s.Order, &order)
+	if err != nil {
+		return fmt.Errorf("%s : %s", s.Source, err.Error())
+	}
+	var transactions []shopify.Transaction
+	err = json.Unmarshal(s.Transactions, &transactions)
+	if err != nil {
+		return fmt.Errorf("%s : %s", s.Source, err.Error())
+	}
+
+	lines := []string{
+		fmt.Sprintf("===== %s (%s 時点) %s =====", order.Name, s.TakenAt.Local().Format("2006-01-02 15:04:05"), s.Source),
+		fmt.Sprintf("orderId        : %d", order.ID),
+		fmt.Sprintf("作成日時       : %s", order.CreatedAt.Local().Format("2006-01-02 15:04:05")),
+		fmt.Sprintf("支払状況       : %s / 発送状況 : %s", order.FinancialStatus, order.FulfillmentStatus.String()),
+		fmt.Sprintf("合計金額       : %s %s (小計 %s、税 %s、割引 %s)", order.TotalPrice, order.Currency, order.SubtotalPrice, order.TotalTax, order.TotalDiscounts),
+		fmt.Sprintf("メール         : %s", order.Email),
+		fmt.Sprintf("請求先         : %s", formatAddress(order.BillingAddress)),
+		fmt.Sprintf("配送先         : %s", formatAddress(order.ShippingAddress)),
+	}
+	for _, lineItem := range order.LineItems {
+		lines = append(lines, fmt.Sprintf("商品           : %s × %d (%s) variantId %d", lineItem.Name, lineItem.Quantity, lineItem.Price, lineItem.VariantID))
+	}
+	for _, transaction := range transactions {
+		lines = append(lines, fmt.Sprintf("取引           : %d %s %s %s %s (%s)", transaction.ID, transaction.Kind, transaction.Status, transaction.Amount, transaction.Currency, transaction.Gateway))
+	}
+
+	for _, line := range lines {
+		log.Println(line)
+	}
+	return nil
+}
+
+func formatAddress(address *shopify.Address) string {
+	if address == nil {
+		return "-"
+	}
+	var parts []string
+	for _, part := range []string{address.Zip, address.Province, address.City, address.Address1, address.Address2, address.Company.String(), address.Name, address.Phone} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
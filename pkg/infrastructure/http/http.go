@@ -7,18 +7,24 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"time"
+
+	"shopify-manager/pkg/infrastructure/ratelimit"
 )
 
-func Delete(url string, header map[string]string) error {
-	req, err := createRequest("DELETE", url, header, nil, nil)
-	if err != nil {
-		log.Printf("ERROR : failed create http request. %s\n", err.Error())
-		return err
-	}
+// 429 (Too Many Requests) は処理されていないため、待機後にこの回数まで再送する。
+const MAX_RETRY_ON_TOO_MANY_REQUESTS = 5
 
-	res, err := new(http.Client).Do(req)
+// 応答の無い接続で処理が止まらないように、レスポンスの読み込みまでをこの時間で打ち切る。
+// 変更を伴う呼び出しがタイムアウトした場合、Shopify に反映されたかどうかは分からない。
+const HTTP_TIMEOUT = 60 * time.Second
+
+// 接続を使い回すため、全てのリクエストで共有する
+var client = &http.Client{Timeout: HTTP_TIMEOUT}
+
+func Delete(url string, header map[string]string) error {
+	res, err := do("DELETE", url, header, nil, nil)
 	if err != nil {
-		log.Println("client.Do() error")
 		return err
 	}
 	defer res.Body.Close()
@@ -32,15 +38,8 @@ func Delete(url string, header map[string]string) error {
 }
 
 func Get(url string, header, queryParam map[string]string) ([]byte, error) {
-	req, err := createRequest("GET", url, header, queryParam, nil)
-	if err != nil {
-		log.Printf("ERROR : failed create http request. %s\n", err.Error())
-		return nil, err
-	}
-
-	res, err := new(http.Client).Do(req)
+	res, err := do("GET", url, header, queryParam, nil)
 	if err != nil {
-		log.Println("client.Do() error")
 		return nil, err
 	}
 	defer res.Body.Close()
@@ -52,10 +51,72 @@ func Post(url string, jsonBytes []byte, header map[string]string) ([]byte, error
 	return postOrPut("POST", url, jsonBytes, header)
 }
 
+// Response はステータスやヘッダも必要な呼び出し元向けのレスポンス。
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// PostWithResponse は Post と同じだが、エラー時もステータスとヘッダを返す。
+// 接続自体に失敗した場合の Response は nil になる。
+func PostWithResponse(url string, jsonBytes []byte, header map[string]string) (*Response, error) {
+	res, err := do("POST", url, header, nil, jsonBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	bodyBytes, err := analyzeHttpResponse(res)
+	return &Response{StatusCode: res.StatusCode, Header: res.Header, Body: bodyBytes}, err
+}
+
 func Put(url string, jsonBytes []byte, header map[string]string) ([]byte, error) {
 	return postOrPut("PUT", url, jsonBytes, header)
 }
 
+// PutWithResponse は PostWithResponse の PUT 版。
+func PutWithResponse(url string, jsonBytes []byte, header map[string]string) (*Response, error) {
+	res, err := do("PUT", url, header, nil, jsonBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	bodyBytes, err := analyzeHttpResponse(res)
+	return &Response{StatusCode: res.StatusCode, Header: res.Header, Body: bodyBytes}, err
+}
+
+// do はストア単位のレートリミットに従ってリクエストを送り、429 の場合は待機して再送する。
+func do(httpMethod, url string, header, queryParam map[string]string, jsonBytes []byte) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		var bodyBuffer *bytes.Buffer
+		if jsonBytes != nil {
+			bodyBuffer = bytes.NewBuffer(jsonBytes)
+		}
+		req, err := createRequest(httpMethod, url, header, queryParam, bodyBuffer)
+		if err != nil {
+			log.Printf("ERROR : failed create http request. %s\n", err.Error())
+			return nil, err
+		}
+
+		limiter := ratelimit.For(req.URL.Host)
+		limiter.Wait()
+
+		res, err := client.Do(req)
+		if err != nil {
+			log.Println("client.Do() error")
+			return nil, err
+		}
+		limiter.Observe(res.StatusCode, res.Header)
+
+		if res.StatusCode != http.StatusTooManyRequests || retry >= MAX_RETRY_ON_TOO_MANY_REQUESTS {
+			return res, nil
+		}
+		res.Body.Close()
+	}
+}
+
 func createRequest(httpMethod, url string, header, queryParam map[string]string, bodyBuffer *bytes.Buffer) (*http.Request, error) {
 	var req *http.Request
 	var err error
@@ -86,15 +147,8 @@ func postOrPut(postOrPut, url string, jsonBytes []byte, header map[string]string
 	//log.Printf("HTTP %s to %s\n", postOrPut, url)
 	//log.Printf("HTTP Body is below.\n%s\n", string(jsonBytes))
 
-	req, err := createRequest(postOrPut, url, header, nil, bytes.NewBuffer(jsonBytes))
-	if err != nil {
-		log.Printf("ERROR : failed create http request. %s\n", err.Error())
-		return nil, err
-	}
-
-	res, err := new(http.Client).Do(req)
+	res, err := do(postOrPut, url, header, nil, jsonBytes)
 	if err != nil {
-		log.Println("client.Do() error")
 		return nil, err
 	}
 	defer res.Body.Close()
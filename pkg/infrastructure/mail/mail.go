@@ -0,0 +1,137 @@
+package mail
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP サーバーへの接続と、接続してから送信を終えるまでの時間の上限。
+// 応答しないサーバーで送信が止まり、呼び出し元 (スケジュール実行など) を塞がないようにする。
+const DIAL_TIMEOUT = 10 * time.Second
+const SEND_TIMEOUT = 60 * time.Second
+
+// Server は SMTP サーバーの接続情報。接続は net/smtp の SendMail と同じ手順で、
+// サーバーが対応していれば STARTTLS を使う。Username が空の場合は認証しない。
+type Server struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// Message は UTF-8 のメール。Html が空の場合はテキストのみ、
+// ある場合は text/plain と text/html の multipart/alternative にする。
+type Message struct {
+	From    string
+	To      []string
+	Bcc     []string
+	Subject string
+	Text    string
+	Html    string
+}
+
+// Send は message を送信する。Bcc はヘッダには含めず宛先にのみ加える。
+// 接続は DIAL_TIMEOUT、送信全体は SEND_TIMEOUT を超えるとエラーにする。
+func (s *Server) Send(message *Message) error {
+	addr := s.Host + ":" + strconv.Itoa(s.Port)
+	conn, err := net.DialTimeout("tcp", addr, DIAL_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	err = conn.SetDeadline(time.Now().Add(SEND_TIMEOUT))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: s.Host})
+		if err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(message.From)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range append(append([]string{}, message.To...), message.Bcc...) {
+		err = client.Rcpt(recipient)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(message.Bytes())
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Bytes は RFC 5322 形式のメールを返す。ファイルに保存すると .eml としてメールソフトで開ける。
+func (m *Message) Bytes() []byte {
+	var b strings.Builder
+	b.WriteString("From: " + m.From + "\r\n")
+	b.WriteString("To: " + strings.Join(m.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", m.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if m.Html == "" {
+		writePart(&b, "text/plain", m.Text)
+		return []byte(b.String())
+	}
+
+	boundary := newBoundary()
+	b.WriteString("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString("--" + boundary + "\r\n")
+	writePart(&b, "text/plain", m.Text)
+	b.WriteString("--" + boundary + "\r\n")
+	writePart(&b, "text/html", m.Html)
+	b.WriteString("--" + boundary + "--\r\n")
+	return []byte(b.String())
+}
+
+func writePart(b *strings.Builder, contentType, body string) {
+	b.WriteString("Content-Type: " + contentType + "; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(strings.Replace(body, "\r\n", "\n", -1), "\n", "\r\n", -1))
+	b.WriteString("\r\n")
+}
+
+func newBoundary() string {
+	random := make([]byte, 12)
+	rand.Read(random)
+	return "shopify-manager-" + hex.EncodeToString(random)
+}
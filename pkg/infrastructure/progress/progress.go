@@ -0,0 +1,221 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"shopify-manager/pkg/infrastructure/util"
+)
+
+// 端末以外 (ファイルへのリダイレクトやスケジュール実行) ではこの間隔で1行の概要をログに出す。
+const PLAIN_INTERVAL = 10 * time.Second
+const BAR_WIDTH = 30
+const BAR_REDRAW_INTERVAL = 100 * time.Millisecond
+
+// Reporter はバッチ処理の進捗の通知先。item はオーダー番号など処理対象の識別子。
+// 複数の goroutine から同時に呼び出してよい。
+type Reporter interface {
+	// Step は item の処理が step に進んだことを通知する。
+	Step(item, step string)
+	Succeeded(item string)
+	Failed(item string, err error)
+	// Finish は最終結果を出力する。以降の通知は無視される。
+	Finish()
+}
+
+// New は total 件の処理の Reporter を作る。
+// 標準エラー出力が端末ならプログレスバー、それ以外は定期的な概要行を出力する。
+// プログレスバーの表示中にログを出す場合は、ログの出力先を Console で包むこと。
+func New(title string, total int) Reporter {
+	if util.IsTerminalFile(os.Stderr) {
+		return &bar{counter: counter{title: title, total: total, start: time.Now()}}
+	}
+	return &plain{counter: counter{title: title, total: total, start: time.Now()}, last: time.Now()}
+}
+
+// Nop は何も出力しない Reporter。
+type Nop struct{}
+
+func (Nop) Step(item, step string)        {}
+func (Nop) Succeeded(item string)         {}
+func (Nop) Failed(item string, err error) {}
+func (Nop) Finish()                       {}
+
+type counter struct {
+	mutex     sync.Mutex
+	title     string
+	total     int
+	succeeded int
+	failed    int
+	start     time.Time
+	current   string
+	finished  bool
+}
+
+func (c *counter) processed() int {
+	return c.succeeded + c.failed
+}
+
+func (c *counter) rate() float64 {
+	elapsed := time.Since(c.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(c.processed()) / elapsed
+}
+
+func (c *counter) eta() string {
+	rate := c.rate()
+	if rate <= 0 {
+		return "-"
+	}
+	remaining := time.Duration(float64(c.total-c.processed())/rate) * time.Second
+	return remaining.Round(time.Second).String()
+}
+
+func (c *counter) summary() string {
+	return fmt.Sprintf("%s %d/%d 成功 %d 失敗 %d %.1f件/秒 残り %s", c.title, c.processed(), c.total, c.succeeded, c.failed, c.rate(), c.eta())
+}
+
+type bar struct {
+	counter
+	drawn time.Time
+}
+
+func (b *bar) Step(item, step string) {
+	b.update(func() { b.current = item + " " + step })
+}
+
+func (b *bar) Succeeded(item string) {
+	b.update(func() { b.succeeded++ })
+}
+
+func (b *bar) Failed(item string, err error) {
+	b.update(func() { b.failed++ })
+}
+
+func (b *bar) Finish() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.finished {
+		return
+	}
+	b.current = ""
+	b.draw()
+	done()
+	b.finished = true
+}
+
+func (b *bar) update(f func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.finished {
+		return
+	}
+	f()
+	if time.Since(b.drawn) >= BAR_REDRAW_INTERVAL || b.processed() == b.total {
+		b.draw()
+	}
+}
+
+func (b *bar) draw() {
+	filled := BAR_WIDTH
+	if b.total > 0 {
+		filled = BAR_WIDTH * b.processed() / b.total
+	}
+	show(fmt.Sprintf("[%s%s] %s %s", strings.Repeat("#", filled), strings.Repeat("-", BAR_WIDTH-filled), b.summary(), b.current))
+	b.drawn = time.Now()
+}
+
+type plain struct {
+	counter
+	last time.Time
+}
+
+func (p *plain) Step(item, step string) {
+	p.update(func() { p.current = item + " " + step })
+}
+
+func (p *plain) Succeeded(item string) {
+	p.update(func() { p.succeeded++ })
+}
+
+func (p *plain) Failed(item string, err error) {
+	p.update(func() { p.failed++ })
+}
+
+func (p *plain) Finish() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.finished {
+		return
+	}
+	log.Printf("INFO : %s\n", p.summary())
+	p.finished = true
+}
+
+func (p *plain) update(f func()) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.finished {
+		return
+	}
+	f()
+	if time.Since(p.last) >= PLAIN_INTERVAL {
+		log.Printf("INFO : %s\n", p.summary())
+		p.last = time.Now()
+	}
+}
+
+// screen は表示中のプログレスバーの行。ログの出力とバーの描画が同じ端末の行で混ざらないようにする。
+var screen struct {
+	mutex sync.Mutex
+	line  string
+}
+
+// Console はコンソールへのログの出力先 out を包む。プログレスバーの表示中は
+// バーを消してからログを書き、書いた後にバーを描き直す。
+func Console(out io.Writer) io.Writer {
+	return consoleWriter{out: out}
+}
+
+type consoleWriter struct {
+	out io.Writer
+}
+
+func (w consoleWriter) Write(p []byte) (int, error) {
+	screen.mutex.Lock()
+	defer screen.mutex.Unlock()
+
+	if screen.line == "" {
+		return w.out.Write(p)
+	}
+	fmt.Fprintf(os.Stderr, "\r%-120s\r", "")
+	n, err := w.out.Write(p)
+	fmt.Fprintf(os.Stderr, "\r%-120s", screen.line)
+	return n, err
+}
+
+// show はバーの行を描画して、ログの出力後に描き直せるように覚えておく。
+func show(line string) {
+	screen.mutex.Lock()
+	defer screen.mutex.Unlock()
+
+	// Windows のコンソールでも動くよう、エスケープシーケンスは使わず空白で前の表示を消す
+	fmt.Fprintf(os.Stderr, "\r%-120s", line)
+	screen.line = line
+}
+
+// done はバーの行を確定して改行する。以降のログはそのまま出力する。
+func done() {
+	screen.mutex.Lock()
+	defer screen.mutex.Unlock()
+
+	fmt.Fprintln(os.Stderr)
+	screen.line = ""
+}
@@ -0,0 +1,199 @@
+package ratelimit
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Shopify REST API の標準プランのバケット (容量 40、毎秒 2 回回復)。
+// 実際の容量は X-Shopify-Shop-Api-Call-Limit ヘッダから学習する。
+const DEFAULT_BUCKET_SIZE = 40
+const DEFAULT_LEAK_SECONDS = 20
+const CALL_LIMIT_HEADER = "X-Shopify-Shop-Api-Call-Limit"
+const RETRY_AFTER_HEADER = "Retry-After"
+const DEFAULT_RETRY_AFTER = 2 * time.Second
+
+// バケット使用率がこれを超えたら並列数を半減し、下回っていれば 1 ずつ増やす。
+const HIGH_WATER_RATIO = 0.8
+const LOW_WATER_RATIO = 0.5
+
+var limiters = map[string]*Limiter{}
+var limitersMutex sync.Mutex
+
+// Limiter はホスト (ストア) 単位のトークンバケットと、観測した API の混雑具合から決める推奨並列数を持つ。
+type Limiter struct {
+	mutex       sync.Mutex
+	host        string
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	concurrency int
+}
+
+// For は host 用の Limiter を返す。同じ host には全てのフローで同じ Limiter を使う。
+func For(host string) *Limiter {
+	limitersMutex.Lock()
+	defer limitersMutex.Unlock()
+
+	limiter, ok := limiters[host]
+	if !ok {
+		limiter = &Limiter{
+			host:        host,
+			rate:        float64(DEFAULT_BUCKET_SIZE) / DEFAULT_LEAK_SECONDS,
+			burst:       DEFAULT_BUCKET_SIZE,
+			tokens:      DEFAULT_BUCKET_SIZE,
+			last:        time.Now(),
+			concurrency: 1,
+		}
+		limiters[host] = limiter
+	}
+	return limiter
+}
+
+// Wait はリクエストを1回送れるようになるまで待つ。
+func (l *Limiter) Wait() {
+	for {
+		l.mutex.Lock()
+		now := time.Now()
+		l.refill(now)
+
+		var wait time.Duration
+		if now.Before(l.pausedUntil) {
+			wait = l.pausedUntil.Sub(now)
+		} else if l.tokens >= 1 {
+			l.tokens--
+			l.mutex.Unlock()
+			return
+		} else {
+			wait = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		}
+		l.mutex.Unlock()
+
+		time.Sleep(wait)
+	}
+}
+
+// Observe はレスポンスのステータスとヘッダから、バケットの残量と推奨並列数を更新する。
+func (l *Limiter) Observe(statusCode int, header http.Header) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if statusCode == http.StatusTooManyRequests {
+		retryAfter := parseRetryAfter(header.Get(RETRY_AFTER_HEADER))
+		l.pausedUntil = time.Now().Add(retryAfter)
+		l.tokens = 0
+		l.decrease()
+		log.Printf("WARN : %s returned 429. wait %s and reduce concurrency to %d\n", l.host, retryAfter, l.concurrency)
+		return
+	}
+
+	used, size, ok := parseCallLimit(header.Get(CALL_LIMIT_HEADER))
+	if !ok {
+		return
+	}
+
+	// サーバ側の残量に合わせる。容量が大きいプランでは回復速度も比例して速い。
+	l.refill(time.Now())
+	l.burst = float64(size)
+	l.rate = float64(size) / DEFAULT_LEAK_SECONDS
+	l.tokens = math.Min(l.tokens, float64(size-used))
+
+	l.adjust(float64(used)/float64(size), size)
+}
+
+// ObserveCost は GraphQL のレスポンスの extensions.cost から、送信の一時停止と推奨並列数を更新する。
+// GraphQL はクエリのコストのバケットで制限され、超えた場合も HTTP 200 で THROTTLED エラーを返すため、
+// ステータスとヘッダを見る Observe では検出できない。
+// requested は今回のクエリの要求コスト、available / maximum / restoreRate は throttleStatus の値。
+func (l *Limiter) ObserveCost(requested, available, maximum, restoreRate float64, throttled bool) {
+	if maximum <= 0 || restoreRate <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// 同じコストのクエリをもう1回送れるだけ回復するまで送信を止める
+	if throttled || available < requested {
+		wait := time.Duration((requested - available) / restoreRate * float64(time.Second))
+		if wait <= 0 {
+			wait = DEFAULT_RETRY_AFTER
+		}
+		until := time.Now().Add(wait)
+		if until.After(l.pausedUntil) {
+			l.pausedUntil = until
+		}
+		if throttled {
+			l.tokens = 0
+			l.decrease()
+			log.Printf("WARN : %s GraphQL throttled. wait %s and reduce concurrency to %d\n", l.host, wait, l.concurrency)
+			return
+		}
+	}
+
+	maxConcurrency := int(maximum)
+	if requested > 0 {
+		maxConcurrency = int(maximum / requested)
+	}
+	l.adjust(1-available/maximum, maxConcurrency)
+}
+
+// Concurrency は現在の推奨並列数を返す。
+func (l *Limiter) Concurrency() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.concurrency
+}
+
+func (l *Limiter) refill(now time.Time) {
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// adjust はバケットの使用率 ratio から推奨並列数を増減する。並列数は maxConcurrency を超えない。
+func (l *Limiter) adjust(ratio float64, maxConcurrency int) {
+	if ratio > HIGH_WATER_RATIO {
+		l.decrease()
+	} else if ratio < LOW_WATER_RATIO && l.concurrency < maxConcurrency {
+		l.concurrency++
+	}
+}
+
+func (l *Limiter) decrease() {
+	l.concurrency = l.concurrency / 2
+	if l.concurrency < 1 {
+		l.concurrency = 1
+	}
+}
+
+// parseCallLimit は "32/40" 形式のヘッダを解析する。
+func parseCallLimit(value string) (int, int, bool) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	used, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || size <= 0 {
+		return 0, 0, false
+	}
+	return used, size, true
+}
+
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || seconds <= 0 {
+		return DEFAULT_RETRY_AFTER
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
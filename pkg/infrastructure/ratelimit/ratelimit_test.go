@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(burst, rate float64, concurrency int) *Limiter {
+	return &Limiter{host: "test", rate: rate, burst: burst, tokens: burst, last: time.Now(), concurrency: concurrency}
+}
+
+func TestWaitTokenBucket(t *testing.T) {
+	// 容量 2、毎秒 20 回復 (50ms 毎に1つ)
+	limiter := newTestLimiter(2, 20, 1)
+
+	start := time.Now()
+	limiter.Wait()
+	limiter.Wait()
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("Wait within burst took %s", elapsed)
+	}
+
+	limiter.Wait()
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Wait after burst took %s, want about 50ms", elapsed)
+	}
+}
+
+func TestObserveTooManyRequests(t *testing.T) {
+	limiter := newTestLimiter(40, 1000, 8)
+	header := http.Header{}
+	header.Set(RETRY_AFTER_HEADER, "0.05")
+
+	limiter.Observe(http.StatusTooManyRequests, header)
+	if got := limiter.Concurrency(); got != 4 {
+		t.Errorf("Concurrency = %d, want 4", got)
+	}
+
+	start := time.Now()
+	limiter.Wait()
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Wait after 429 took %s, want about 50ms", elapsed)
+	}
+}
+
+func TestObserveCallLimit(t *testing.T) {
+	limiter := newTestLimiter(DEFAULT_BUCKET_SIZE, 2, 2)
+	header := http.Header{}
+
+	header.Set(CALL_LIMIT_HEADER, "10/80")
+	limiter.Observe(http.StatusOK, header)
+	if limiter.burst != 80 || limiter.rate != 4 {
+		t.Errorf("burst, rate = %v, %v, want 80, 4", limiter.burst, limiter.rate)
+	}
+	if got := limiter.Concurrency(); got != 3 {
+		t.Errorf("Concurrency at low usage = %d, want 3", got)
+	}
+
+	header.Set(CALL_LIMIT_HEADER, "70/80")
+	limiter.Observe(http.StatusOK, header)
+	if got := limiter.Concurrency(); got != 1 {
+		t.Errorf("Concurrency at high usage = %d, want 1", got)
+	}
+	if limiter.tokens > 10 {
+		t.Errorf("tokens = %v, want at most the remaining 10", limiter.tokens)
+	}
+}
+
+func TestObserveCost(t *testing.T) {
+	limiter := newTestLimiter(DEFAULT_BUCKET_SIZE, 1000, 1)
+
+	// 余裕がある間は要求コストで割った数まで並列数を増やす
+	for i := 0; i < 10; i++ {
+		limiter.ObserveCost(250, 1000, 1000, 50, false)
+	}
+	if got := limiter.Concurrency(); got != 4 {
+		t.Errorf("Concurrency = %d, want 4", got)
+	}
+
+	// THROTTLED は要求コストが回復するまで止めて並列数を半減する
+	limiter.ObserveCost(100, 95, 1000, 100, true)
+	if got := limiter.Concurrency(); got != 2 {
+		t.Errorf("Concurrency after throttled = %d, want 2", got)
+	}
+	start := time.Now()
+	limiter.Wait()
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Errorf("Wait after throttled took %s, want about 50ms", elapsed)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	callLimits := []struct {
+		value      string
+		used, size int
+		ok         bool
+	}{
+		{value: "32/40", used: 32, size: 40, ok: true},
+		{value: " 1 / 80 ", used: 1, size: 80, ok: true},
+		{value: "32", ok: false},
+		{value: "a/40", ok: false},
+		{value: "1/0", ok: false},
+	}
+	for _, tt := range callLimits {
+		used, size, ok := parseCallLimit(tt.value)
+		if used != tt.used || size != tt.size || ok != tt.ok {
+			t.Errorf("parseCallLimit(%q) = %d, %d, %v", tt.value, used, size, ok)
+		}
+	}
+
+	retryAfters := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "2.0", want: 2 * time.Second},
+		{value: "0.5", want: 500 * time.Millisecond},
+		{value: "", want: DEFAULT_RETRY_AFTER},
+		{value: "-1", want: DEFAULT_RETRY_AFTER},
+	}
+	for _, tt := range retryAfters {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
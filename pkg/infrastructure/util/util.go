@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 )
 
 const LogFile = "./info.log"
@@ -20,6 +21,29 @@ func FailOnError(errMsg string, err error) {
 	os.Exit(1)
 }
 
+// IsTerminal は標準入力が端末 (対話実行) かどうかを返す。
+func IsTerminal() bool {
+	return IsTerminalFile(os.Stdin)
+}
+
+// IsTerminalFile は file が端末かどうかを返す。
+func IsTerminalFile(file *os.File) bool {
+	stat, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// Prompt は message を標準エラー出力に表示して1行の入力を返す。
+// 標準出力は JSON 出力モードで結果の出力に使うため使わない。
+func Prompt(message string) string {
+	fmt.Fprint(os.Stderr, message)
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Scan()
+	return strings.TrimSpace(scanner.Text())
+}
+
 func WaitEnter() {
 	fmt.Println("エンターを押すと処理を終了します。")
 	scanner := bufio.NewScanner(os.Stdin)
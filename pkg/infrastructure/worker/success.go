@@ -0,0 +1,18 @@
+package worker
+
+import "sync/atomic"
+
+// Success は Run のタスクが1件でも失敗したかどうかを記録する。複数のタスクから同時に呼んでよい。
+type Success struct {
+	failed int32
+}
+
+// Fail はタスクの失敗を記録する。
+func (s *Success) Fail() {
+	atomic.StoreInt32(&s.failed, 1)
+}
+
+// OK は1件も失敗していない場合に true を返す。
+func (s *Success) OK() bool {
+	return atomic.LoadInt32(&s.failed) == 0
+}
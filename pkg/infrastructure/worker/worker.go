@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"sync"
+
+	"shopify-manager/pkg/infrastructure/ratelimit"
+)
+
+// Pool は同じストアへの処理を並列実行する。
+// 同時実行数はストアの Limiter が API の混雑具合から決める推奨並列数に従い、maxWorkers を上限とする。
+type Pool struct {
+	limiter    *ratelimit.Limiter
+	maxWorkers int
+}
+
+// New は host (ストアのドメイン) 向けの Pool を作る。
+func New(host string, maxWorkers int) *Pool {
+	if maxWorkers < 1 {
+		maxWorkers = 1
+	}
+	return &Pool{limiter: ratelimit.For(host), maxWorkers: maxWorkers}
+}
+
+// Run は task(0) ... task(count-1) を実行し、全て終わるまで待つ。
+func (p *Pool) Run(count int, task func(i int)) {
+	var mutex sync.Mutex
+	cond := sync.NewCond(&mutex)
+	running := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		mutex.Lock()
+		for running >= p.workers() {
+			cond.Wait()
+		}
+		running++
+		mutex.Unlock()
+
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				mutex.Lock()
+				running--
+				mutex.Unlock()
+				cond.Broadcast()
+				wg.Done()
+			}()
+			task(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) workers() int {
+	workers := p.limiter.Concurrency()
+	if workers > p.maxWorkers {
+		return p.maxWorkers
+	}
+	return workers
+}
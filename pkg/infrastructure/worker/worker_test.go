@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"shopify-manager/pkg/infrastructure/ratelimit"
+)
+
+// runTasks は count 件のタスクを実行し、同時に動いたタスク数の最大を返す。
+// 0件目のタスクの最初に start を呼ぶ。
+func runTasks(pool *Pool, count int, start func()) int32 {
+	var running, max int32
+	var mutex sync.Mutex
+	pool.Run(count, func(i int) {
+		if i == 0 && start != nil {
+			start()
+		}
+		current := atomic.AddInt32(&running, 1)
+		mutex.Lock()
+		if current > max {
+			max = current
+		}
+		mutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	})
+	return max
+}
+
+func TestRunFollowsLimiterConcurrency(t *testing.T) {
+	var done int32
+	New("worker-test-serial", 4).Run(5, func(i int) { atomic.AddInt32(&done, 1) })
+	if done != 5 {
+		t.Errorf("done = %d, want 5", done)
+	}
+
+	// 新しい Limiter の推奨並列数は 1
+	if max := runTasks(New("worker-test-serial", 4), 5, nil); max != 1 {
+		t.Errorf("max running = %d, want 1", max)
+	}
+}
+
+func TestRunResizesPool(t *testing.T) {
+	host := "worker-test-resize"
+	limiter := ratelimit.For(host)
+
+	// 実行中に API に余裕があると分かれば並列数を増やし、maxWorkers で止める
+	max := runTasks(New(host, 3), 20, func() {
+		for i := 0; i < 10; i++ {
+			limiter.ObserveCost(1, 1000, 1000, 50, false)
+		}
+	})
+	if max != 3 {
+		t.Errorf("max running = %d, want 3", max)
+	}
+
+	// THROTTLED で並列数を減らす
+	for limiter.Concurrency() > 1 {
+		limiter.ObserveCost(1, 0, 1000, 1000, true)
+	}
+	if max := runTasks(New(host, 3), 5, nil); max != 1 {
+		t.Errorf("max running after throttled = %d, want 1", max)
+	}
+}
+
+func TestSuccess(t *testing.T) {
+	var success Success
+	New("worker-test-success", 1).Run(10, func(i int) {
+		if i == 5 {
+			success.Fail()
+		}
+	})
+	if success.OK() {
+		t.Errorf("OK = true after Fail")
+	}
+}
@@ -0,0 +1,197 @@
+package localapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/infrastructure/progress"
+)
+
+const JOB_STATUS_QUEUED = "queued"
+const JOB_STATUS_RUNNING = "running"
+const JOB_STATUS_SUCCEEDED = "succeeded"
+const JOB_STATUS_FAILED = "failed"
+
+// メモリに保持するジョブの件数。超えた場合は古い完了済みジョブから消す。
+const MAX_JOBS = 1000
+
+// Job は API から受け付けたフローの実行。進捗と結果は shopify.Sink として受け取る。
+type Job struct {
+	mutex      sync.Mutex
+	ID         string                 `json:"id"`
+	Flow       string                 `json:"flow"`
+	Store      string                 `json:"store"`
+	Status     string                 `json:"status"`
+	Phase      string                 `json:"phase,omitempty"`
+	Total      int                    `json:"total"`
+	Processed  int                    `json:"processed"`
+	Succeeded  int                    `json:"succeeded"`
+	Failed     int                    `json:"failed"`
+	Error      string                 `json:"error,omitempty"`
+	Results    []*shopify.OrderResult `json:"results"`
+	CreatedAt  time.Time              `json:"created_at"`
+	StartedAt  *time.Time             `json:"started_at,omitempty"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+	run        func(job *Job) error
+}
+
+// Snapshot は JSON で返すためのジョブの複製。
+func (j *Job) Snapshot() *Job {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return &Job{
+		ID:         j.ID,
+		Flow:       j.Flow,
+		Store:      j.Store,
+		Status:     j.Status,
+		Phase:      j.Phase,
+		Total:      j.Total,
+		Processed:  j.Processed,
+		Succeeded:  j.Succeeded,
+		Failed:     j.Failed,
+		Error:      j.Error,
+		Results:    append([]*shopify.OrderResult{}, j.Results...),
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+	}
+}
+
+func (j *Job) Progress(title string, total int) progress.Reporter {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.Phase = title
+	j.Total = total
+	j.Processed = 0
+	j.Succeeded = 0
+	j.Failed = 0
+	return &jobReporter{job: j}
+}
+
+func (j *Job) Result(result *shopify.OrderResult) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.Results = append(j.Results, result)
+}
+
+func (j *Job) update(f func()) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	f()
+}
+
+type jobReporter struct {
+	job *Job
+}
+
+func (r *jobReporter) Step(item, step string) {}
+
+func (r *jobReporter) Succeeded(item string) {
+	r.job.update(func() {
+		r.job.Processed++
+		r.job.Succeeded++
+	})
+}
+
+func (r *jobReporter) Failed(item string, err error) {
+	r.job.update(func() {
+		r.job.Processed++
+		r.job.Failed++
+	})
+}
+
+func (r *jobReporter) Finish() {}
+
+// Queue はジョブを受付順に1件ずつ実行する。
+// 同じストアへのキャンセルが並行して走らないよう、ジョブ同士は並列に実行しない。
+type Queue struct {
+	mutex sync.Mutex
+	jobs  map[string]*Job
+	order []string
+	ch    chan *Job
+}
+
+func NewQueue() *Queue {
+	queue := &Queue{jobs: map[string]*Job{}, ch: make(chan *Job, MAX_JOBS)}
+	go queue.loop()
+	return queue
+}
+
+// Submit はジョブを登録して返す。run はジョブの実行時に呼ばれる。
+func (q *Queue) Submit(flow, store string, run func(job *Job) error) (*Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{ID: id, Flow: flow, Store: store, Status: JOB_STATUS_QUEUED, Results: []*shopify.OrderResult{}, CreatedAt: time.Now(), run: run}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	select {
+	case q.ch <- job:
+	default:
+		return nil, fmt.Errorf("実行待ちのジョブが多すぎます")
+	}
+	q.jobs[id] = job
+	q.order = append(q.order, id)
+	q.evict()
+	return job, nil
+}
+
+// Get は id のジョブを返す。
+func (q *Queue) Get(id string) (*Job, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	job, ok := q.jobs[id]
+	return job, ok
+}
+
+func (q *Queue) loop() {
+	for job := range q.ch {
+		now := time.Now()
+		job.update(func() {
+			job.Status = JOB_STATUS_RUNNING
+			job.StartedAt = &now
+		})
+
+		err := job.run(job)
+
+		finished := time.Now()
+		job.update(func() {
+			job.FinishedAt = &finished
+			if err != nil {
+				job.Status = JOB_STATUS_FAILED
+				job.Error = err.Error()
+			} else {
+				job.Status = JOB_STATUS_SUCCEEDED
+			}
+		})
+	}
+}
+
+// evict は保持件数を超えた完了済みのジョブを古い順に消す。mutex を取得して呼ぶ。
+func (q *Queue) evict() {
+	for i := 0; len(q.order) > MAX_JOBS && i < len(q.order); {
+		job := q.jobs[q.order[i]]
+		status := job.Snapshot().Status
+		if status == JOB_STATUS_QUEUED || status == JOB_STATUS_RUNNING {
+			i++
+			continue
+		}
+		delete(q.jobs, q.order[i])
+		q.order = append(q.order[:i], q.order[i+1:]...)
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
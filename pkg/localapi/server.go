@@ -0,0 +1,124 @@
+package localapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const FLOWS_PATH = "/flows/"
+const JOBS_PATH = "/jobs/"
+
+const MAX_REQUEST_SIZE = 1 << 20
+
+// CancelOrdersRequest は POST /flows/cancel-orders のリクエスト。
+// order_numbers と query はどちらか一方を指定する。
+type CancelOrdersRequest struct {
+	Store        string `json:"store"`
+	OrderNumbers []int  `json:"order_numbers"`
+	Query        string `json:"query"`
+}
+
+type SubmitResponse struct {
+	JobID string `json:"job_id"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// FlowHandler は POST /flows/{flow} のリクエストボディを検証し、ジョブとして登録する処理を返す。
+// 返したエラーは 400 Bad Request になる。
+type FlowHandler func(body []byte) (store string, run func(job *Job) error, err error)
+
+// Server はトークン認証付きのローカル API。
+type Server struct {
+	token string
+	flows map[string]FlowHandler
+	queue *Queue
+}
+
+func NewServer(token string, flows map[string]FlowHandler) (*Server, error) {
+	if token == "" {
+		return nil, fmt.Errorf("[LocalApi] token が設定されていません")
+	}
+	return &Server{token: token, flows: flows, queue: NewQueue()}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, FLOWS_PATH) && r.Method == http.MethodPost {
+		s.submit(w, r, strings.TrimPrefix(r.URL.Path, FLOWS_PATH))
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, JOBS_PATH) && r.Method == http.MethodGet {
+		s.getJob(w, strings.TrimPrefix(r.URL.Path, JOBS_PATH))
+		return
+	}
+	writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request, flow string) {
+	handler, ok := s.flows[flow]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: fmt.Sprintf("unknown flow '%s'", flow)})
+		return
+	}
+
+	var body json.RawMessage
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAX_REQUEST_SIZE)).Decode(&body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid json. %s", err.Error())})
+		return
+	}
+
+	store, run, err := handler(body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	job, err := s.queue.Submit(flow, store, run)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	log.Printf("INFO : job '%s' %s store '%s' accepted from %s\n", job.ID, flow, store, r.RemoteAddr)
+	writeJSON(w, http.StatusAccepted, SubmitResponse{JobID: job.ID})
+}
+
+func (s *Server) getJob(w http.ResponseWriter, id string) {
+	job, ok := s.queue.Get(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: fmt.Sprintf("job '%s' not found", id)})
+		return
+	}
+	writeJSON(w, http.StatusOK, job.Snapshot())
+}
+
+// authorized は Authorization: Bearer <token> を検証する。
+func (s *Server) authorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Printf("ERROR : failed to write response. %s\n", err.Error())
+	}
+}
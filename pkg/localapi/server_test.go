@@ -0,0 +1,123 @@
+package localapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const TEST_TOKEN = "secret-token"
+
+func newTestServer(t *testing.T) *Server {
+	server, err := NewServer(TEST_TOKEN, map[string]FlowHandler{
+		"noop": func(body []byte) (string, func(job *Job) error, error) {
+			return "jp", func(job *Job) error { return nil }, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func request(server *Server, method, path, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	return rec
+}
+
+func submit(t *testing.T, server *Server) string {
+	rec := request(server, http.MethodPost, FLOWS_PATH+"noop", "Bearer "+TEST_TOKEN)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("submit status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	var res SubmitResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res.JobID
+}
+
+// waitFinished はジョブが完了するまで待ち、完了時の状態を返す。
+func waitFinished(t *testing.T, server *Server, id string) string {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, ok := server.queue.Get(id)
+		if !ok {
+			t.Fatalf("job '%s' not found", id)
+		}
+		status := job.Snapshot().Status
+		if status != JOB_STATUS_QUEUED && status != JOB_STATUS_RUNNING {
+			return status
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job '%s' did not finish", id)
+	return ""
+}
+
+func TestUnauthorized(t *testing.T) {
+	server := newTestServer(t)
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{name: "missing", authorization: ""},
+		{name: "wrong token", authorization: "Bearer other-token"},
+		{name: "without bearer", authorization: TEST_TOKEN},
+		{name: "other scheme", authorization: "Basic " + TEST_TOKEN},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{FLOWS_PATH + "noop", JOBS_PATH + "unknown"} {
+			method := http.MethodGet
+			if strings.HasPrefix(path, FLOWS_PATH) {
+				method = http.MethodPost
+			}
+			if rec := request(server, method, path, tt.authorization); rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s : status = %d, want 401", tt.name, path, rec.Code)
+			}
+		}
+	}
+	if len(server.queue.order) != 0 {
+		t.Errorf("unauthorized request submitted %d jobs", len(server.queue.order))
+	}
+}
+
+func TestJobStatusAfterEviction(t *testing.T) {
+	server := newTestServer(t)
+
+	first := submit(t, server)
+	if status := waitFinished(t, server, first); status != JOB_STATUS_SUCCEEDED {
+		t.Fatalf("status = %s", status)
+	}
+
+	var last string
+	for i := 0; i < MAX_JOBS; i++ {
+		last = submit(t, server)
+	}
+	waitFinished(t, server, last)
+
+	// 保持件数を超えたため、最も古い完了済みのジョブは消えて 404 になる
+	if rec := request(server, http.MethodGet, JOBS_PATH+first, "Bearer "+TEST_TOKEN); rec.Code != http.StatusNotFound {
+		t.Errorf("evicted job status = %d, want 404", rec.Code)
+	}
+
+	rec := request(server, http.MethodGet, JOBS_PATH+last, "Bearer "+TEST_TOKEN)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("job status = %d, want 200", rec.Code)
+	}
+	job := &Job{}
+	if err := json.Unmarshal(rec.Body.Bytes(), job); err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != last || job.Status != JOB_STATUS_SUCCEEDED || job.Store != "jp" || job.FinishedAt == nil {
+		t.Errorf("job = %+v", job)
+	}
+}
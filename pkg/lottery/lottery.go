@@ -0,0 +1,96 @@
+package lottery
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// 抽選結果の理由
+const REASON_WON = "当選"
+const REASON_NOT_DRAWN = "落選"
+
+// Entry は抽選の応募 (1オーダー)。
+// Key は1件までしか当選させない単位 (顧客・配送先) で、空の場合は重複を判定しない。
+// Excluded は除外する理由で、空でない場合は落選にする。
+type Entry struct {
+	OrderNumber int
+	OrderID     int64
+	Key         string
+	Excluded    string
+}
+
+// Result は1件の応募の抽選結果。Rank は抽選順 (1始まり)。
+type Result struct {
+	Entry
+	Rank   int
+	Won    bool
+	Reason string
+}
+
+// Draw は seed で応募を並べ替え、先頭から winners 件を当選にする。
+// 除外された応募と、同じ Key で先に抽選された応募がある場合は落選にする。
+// 並び順は応募毎に seed とオーダー ID の HMAC-SHA256 で決めるため、応募の順序や件数に依らず、
+// 応募が増減しても他の応募同士の順番は変わらない。
+func Draw(entries []Entry, winners int, seed int64) []Result {
+	sorted := make([]rankedEntry, 0, len(entries))
+	for _, entry := range entries {
+		sorted = append(sorted, rankedEntry{Entry: entry, score: score(seed, entry.OrderID)})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := bytes.Compare(sorted[i].score, sorted[j].score); c != 0 {
+			return c < 0
+		}
+		return sorted[i].OrderNumber < sorted[j].OrderNumber
+	})
+
+	var results []Result
+	drawn := map[string]int{}
+	wonCount := 0
+	for i, ranked := range sorted {
+		entry := ranked.Entry
+		result := Result{Entry: entry, Rank: i + 1}
+		if entry.Excluded != "" {
+			result.Reason = entry.Excluded
+			results = append(results, result)
+			continue
+		}
+		if entry.Key != "" {
+			if orderNumber, ok := drawn[entry.Key]; ok {
+				result.Reason = fmt.Sprintf("重複 (#%d と同じ応募者)", orderNumber)
+				results = append(results, result)
+				continue
+			}
+			drawn[entry.Key] = entry.OrderNumber
+		}
+		if wonCount < winners {
+			result.Won = true
+			result.Reason = REASON_WON
+			wonCount++
+		} else {
+			result.Reason = REASON_NOT_DRAWN
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+type rankedEntry struct {
+	Entry
+	score []byte
+}
+
+// score は seed を鍵にしたオーダー ID の HMAC-SHA256。小さい順に抽選する。
+func score(seed int64, orderId int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(seed))
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(orderId))
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,144 @@
+package lottery
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func entries(orderNumbers ...int) []Entry {
+	var list []Entry
+	for _, orderNumber := range orderNumbers {
+		list = append(list, Entry{OrderNumber: orderNumber, OrderID: int64(orderNumber) * 1000})
+	}
+	return list
+}
+
+func ranked(results []Result) []int {
+	var orderNumbers []int
+	for _, result := range results {
+		orderNumbers = append(orderNumbers, result.OrderNumber)
+	}
+	return orderNumbers
+}
+
+func winners(results []Result) []int {
+	var orderNumbers []int
+	for _, result := range results {
+		if result.Won {
+			orderNumbers = append(orderNumbers, result.OrderNumber)
+		}
+	}
+	return orderNumbers
+}
+
+func TestDrawIsDeterministic(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  []Entry
+		equal bool
+	}{
+		{name: "same seed same entries", a: entries(1, 2, 3, 4, 5), b: entries(1, 2, 3, 4, 5), equal: true},
+		{name: "entry order does not matter", a: entries(1, 2, 3, 4, 5), b: entries(5, 3, 1, 4, 2), equal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Draw(tt.a, 2, 42), Draw(tt.b, 2, 42)
+			if reflect.DeepEqual(a, b) != tt.equal {
+				t.Errorf("Draw = %v / %v", ranked(a), ranked(b))
+			}
+		})
+	}
+
+	if reflect.DeepEqual(ranked(Draw(entries(1, 2, 3, 4, 5, 6, 7, 8), 2, 1)), ranked(Draw(entries(1, 2, 3, 4, 5, 6, 7, 8), 2, 2))) {
+		t.Errorf("different seeds should rank differently")
+	}
+}
+
+// 応募が増減しても、他の応募同士の順番は変わらない
+func TestDrawRanksEachEntryIndependently(t *testing.T) {
+	all := ranked(Draw(entries(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 3, 7))
+	fewer := ranked(Draw(entries(1, 2, 3, 5, 6, 7, 8, 9, 10), 3, 7))
+
+	var want []int
+	for _, orderNumber := range all {
+		if orderNumber != 4 {
+			want = append(want, orderNumber)
+		}
+	}
+	if !reflect.DeepEqual(fewer, want) {
+		t.Errorf("rank without #4 = %v, want %v", fewer, want)
+	}
+}
+
+func TestDrawReasons(t *testing.T) {
+	base := ranked(Draw(entries(1, 2, 3, 4), 0, 3))
+	first, second := base[0], base[1]
+
+	tests := []struct {
+		name        string
+		winners     int
+		modify      func(list []Entry)
+		wantWinners []int
+		wantReasons map[int]string
+	}{
+		{
+			name:        "winners from the top",
+			winners:     2,
+			wantWinners: []int{base[0], base[1]},
+			wantReasons: map[int]string{base[0]: REASON_WON, base[2]: REASON_NOT_DRAWN},
+		},
+		{
+			name:    "excluded entry is skipped",
+			winners: 2,
+			modify: func(list []Entry) {
+				for i := range list {
+					if list[i].OrderNumber == first {
+						list[i].Excluded = "除外"
+					}
+				}
+			},
+			wantWinners: []int{base[1], base[2]},
+			wantReasons: map[int]string{first: "除外"},
+		},
+		{
+			name:    "same key wins once",
+			winners: 2,
+			modify: func(list []Entry) {
+				for i := range list {
+					if list[i].OrderNumber == first || list[i].OrderNumber == second {
+						list[i].Key = "customer"
+					}
+				}
+			},
+			wantWinners: []int{base[0], base[2]},
+			wantReasons: map[int]string{second: "重複 (#" + strconv.Itoa(first) + " と同じ応募者)"},
+		},
+		{
+			name:        "more winners than entries",
+			winners:     10,
+			wantWinners: base,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := entries(1, 2, 3, 4)
+			if tt.modify != nil {
+				tt.modify(list)
+			}
+			results := Draw(list, tt.winners, 3)
+
+			if got := winners(results); !reflect.DeepEqual(got, tt.wantWinners) {
+				t.Errorf("winners = %v, want %v", got, tt.wantWinners)
+			}
+			for i, result := range results {
+				if result.Rank != i+1 {
+					t.Errorf("#%d Rank = %d, want %d", result.OrderNumber, result.Rank, i+1)
+				}
+				if want, ok := tt.wantReasons[result.OrderNumber]; ok && result.Reason != want {
+					t.Errorf("#%d Reason = %s, want %s", result.OrderNumber, result.Reason, want)
+				}
+			}
+		})
+	}
+}
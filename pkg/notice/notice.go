@@ -0,0 +1,207 @@
+package notice
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/infrastructure/mail"
+)
+
+// テンプレートのファイル名。cancel.ja.txt の様に <locale> は言語コード。
+const TEXT_TEMPLATE_FILE_TEMPLATE = "cancel.%s.txt"
+const HTML_TEMPLATE_FILE_TEMPLATE = "cancel.%s.html"
+
+// テキストテンプレートで件名を定義するテンプレート名 ({{define "subject"}}...{{end}})
+const SUBJECT_TEMPLATE_NAME = "subject"
+
+// Data はテンプレートに渡す値。
+type Data struct {
+	Store     *config.Store
+	Order     *shopify.Order
+	FirstName string
+	LastName  string
+	Locale    string
+}
+
+type templates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Sender はキャンセルのお知らせを customer_locale に応じたテンプレートで作り、SMTP またはファイルに送る。
+type Sender struct {
+	setting   config.CustomerNotice
+	templates map[string]*templates
+}
+
+// Register は [CustomerNotice] が有効な場合に、キャンセル成功時にお知らせを送るよう設定する。
+func Register(setting config.CustomerNotice) error {
+	if !setting.Enabled {
+		return nil
+	}
+
+	sender, err := NewSender(setting)
+	if err != nil {
+		return err
+	}
+
+	shopify.OnCancelled = func(store *config.Store, order *shopify.Order) {
+		err := sender.Send(store, order)
+		if err != nil {
+			log.Printf("WARN : orderNumber '%d' のお知らせの送信に失敗しました。%s\n", order.OrderNumber, err.Error())
+		}
+	}
+	return nil
+}
+
+// NewSender は templateDir のテンプレートを全て読み込む。defaultLocale のテンプレートは必須。
+func NewSender(setting config.CustomerNotice) (*Sender, error) {
+	paths, err := filepath.Glob(filepath.Join(setting.TemplateDir, fmt.Sprintf(TEXT_TEMPLATE_FILE_TEMPLATE, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	sender := &Sender{setting: setting, templates: map[string]*templates{}}
+	for _, path := range paths {
+		locale := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "cancel."), ".txt")
+		t, err := loadTemplates(setting.TemplateDir, locale)
+		if err != nil {
+			return nil, err
+		}
+		sender.templates[locale] = t
+	}
+
+	if _, ok := sender.templates[setting.DefaultLocale]; !ok {
+		return nil, fmt.Errorf("[CustomerNotice] defaultLocale '%s' のテンプレート %s がありません", setting.DefaultLocale, filepath.Join(setting.TemplateDir, fmt.Sprintf(TEXT_TEMPLATE_FILE_TEMPLATE, setting.DefaultLocale)))
+	}
+	return sender, nil
+}
+
+func loadTemplates(dir, locale string) (*templates, error) {
+	textPath := filepath.Join(dir, fmt.Sprintf(TEXT_TEMPLATE_FILE_TEMPLATE, locale))
+	text, err := texttemplate.ParseFiles(textPath)
+	if err != nil {
+		return nil, fmt.Errorf("テンプレート %s の読み込みに失敗しました。%s", textPath, err.Error())
+	}
+	if text.Lookup(SUBJECT_TEMPLATE_NAME) == nil {
+		return nil, fmt.Errorf("テンプレート %s に {{define \"%s\"}} がありません", textPath, SUBJECT_TEMPLATE_NAME)
+	}
+
+	t := &templates{text: text}
+	htmlPath := filepath.Join(dir, fmt.Sprintf(HTML_TEMPLATE_FILE_TEMPLATE, locale))
+	if _, err := os.Stat(htmlPath); err == nil {
+		t.html, err = htmltemplate.ParseFiles(htmlPath)
+		if err != nil {
+			return nil, fmt.Errorf("テンプレート %s の読み込みに失敗しました。%s", htmlPath, err.Error())
+		}
+	}
+	return t, nil
+}
+
+// Send は order の顧客にお知らせを送る。メールアドレスが無いオーダーは送らない。
+func (s *Sender) Send(store *config.Store, order *shopify.Order) error {
+	to := order.Email
+	if to == "" {
+		to = order.ContactEmail
+	}
+	if to == "" {
+		log.Printf("INFO : orderNumber '%d' はメールアドレスが無いためお知らせを送りません\n", order.OrderNumber)
+		return nil
+	}
+
+	message, err := s.Build(store, order)
+	if err != nil {
+		return err
+	}
+	message.To = []string{to}
+
+	if s.setting.Sink == config.NOTICE_SINK_SMTP {
+		server := mail.Server{Host: s.setting.Host, Port: s.setting.Port, Username: s.setting.Username, Password: s.setting.Password}
+		err = server.Send(message)
+	} else {
+		err = s.writeFile(store, order, message)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("INFO : orderNumber '%d' のお知らせを %s に送りました\n", order.OrderNumber, s.setting.Sink)
+	return nil
+}
+
+// Build は order のお知らせメールを作る。宛先は呼び出し元で設定する。
+func (s *Sender) Build(store *config.Store, order *shopify.Order) (*mail.Message, error) {
+	locale := s.locale(order)
+	t := s.templates[locale]
+	data := newData(store, order, locale)
+
+	var subject, text bytes.Buffer
+	err := t.text.ExecuteTemplate(&subject, SUBJECT_TEMPLATE_NAME, data)
+	if err != nil {
+		return nil, err
+	}
+	err = t.text.Execute(&text, data)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &mail.Message{
+		From:    s.setting.From,
+		Bcc:     s.setting.Bcc,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}
+	if t.html != nil {
+		var html bytes.Buffer
+		err = t.html.Execute(&html, data)
+		if err != nil {
+			return nil, err
+		}
+		message.Html = html.String()
+	}
+	return message, nil
+}
+
+// locale は customer_locale (ja / en-US など) の言語に合うテンプレートを選ぶ。無ければ defaultLocale。
+func (s *Sender) locale(order *shopify.Order) string {
+	customerLocale := strings.ToLower(order.CustomerLocale.String())
+	if _, ok := s.templates[customerLocale]; ok {
+		return customerLocale
+	}
+	language := strings.SplitN(customerLocale, "-", 2)[0]
+	if _, ok := s.templates[language]; ok {
+		return language
+	}
+	return s.setting.DefaultLocale
+}
+
+func newData(store *config.Store, order *shopify.Order, locale string) *Data {
+	data := &Data{Store: store, Order: order, Locale: locale}
+	if order.Customer != nil {
+		data.FirstName = order.Customer.FirstName
+		data.LastName = order.Customer.LastName
+	}
+	if data.FirstName == "" && data.LastName == "" && order.BillingAddress != nil {
+		data.FirstName = order.BillingAddress.FirstName
+		data.LastName = order.BillingAddress.LastName
+	}
+	return data
+}
+
+func (s *Sender) writeFile(store *config.Store, order *shopify.Order, message *mail.Message) error {
+	err := os.MkdirAll(s.setting.FileDir, 0755)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.setting.FileDir, fmt.Sprintf("%s-%d.eml", store.Name, order.OrderNumber))
+	return ioutil.WriteFile(path, message.Bytes(), 0644)
+}
@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileNotifier は dir に結果の JSON ファイル (<flow>-<開始日時>.json) を置く。
+// 他のツールがフォルダを監視して取り込むことを想定している。
+type FileNotifier struct {
+	Dir string
+}
+
+func (n *FileNotifier) Notify(summary *Summary) error {
+	err := os.MkdirAll(n.Dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// 書き込み途中のファイルを読まれないよう、一時ファイルに書いてから名前を変える
+	name := fmt.Sprintf("%s-%s.json", summary.Flow, summary.StartedAt.Format("20060102-150405"))
+	tmpPath := filepath.Join(n.Dir, "."+name+".tmp")
+	err = ioutil.WriteFile(tmpPath, jsonBytes, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(n.Dir, name))
+}
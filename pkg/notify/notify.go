@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/infrastructure/mail"
+	"shopify-manager/pkg/output"
+)
+
+// 通知の本文に載せる失敗オーダーの最大件数。全件は webhook / file の JSON に含める。
+const MAX_FAILED_ORDERS_IN_TEXT = 20
+
+// Summary はフロー1回分の結果の概要。
+type Summary struct {
+	Flow         string         `json:"flow"`
+	Host         string         `json:"host"`
+	Success      bool           `json:"success"`
+	Error        string         `json:"error,omitempty"`
+	StartedAt    time.Time      `json:"started_at"`
+	FinishedAt   time.Time      `json:"finished_at"`
+	Duration     string         `json:"duration"`
+	Succeeded    int            `json:"succeeded"`
+	Failed       int            `json:"failed"`
+	Skipped      int            `json:"skipped"`
+	FailedOrders []*FailedOrder `json:"failed_orders"`
+}
+
+type FailedOrder struct {
+	Store       string `json:"store"`
+	OrderNumber int    `json:"order_number"`
+	Status      string `json:"status"`
+	Step        string `json:"step,omitempty"`
+	Error       string `json:"error"`
+}
+
+// Notifier は Summary の送信先。
+type Notifier interface {
+	Notify(summary *Summary) error
+}
+
+// NewSummary は flow の実行結果を集計する。
+func NewSummary(flow string, startedAt time.Time, err error, results []*shopify.OrderResult) *Summary {
+	host, _ := os.Hostname()
+	finishedAt := time.Now()
+	summary := &Summary{
+		Flow:         flow,
+		Host:         host,
+		Success:      err == nil,
+		StartedAt:    startedAt,
+		FinishedAt:   finishedAt,
+		Duration:     finishedAt.Sub(startedAt).Round(time.Second).String(),
+		FailedOrders: []*FailedOrder{},
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+
+	for _, result := range results {
+		switch result.Status {
+		case output.STATUS_SUCCEEDED:
+			summary.Succeeded++
+			continue
+		case output.STATUS_SKIPPED:
+			summary.Skipped++
+		default:
+			summary.Failed++
+		}
+		summary.FailedOrders = append(summary.FailedOrders, &FailedOrder{
+			Store:       result.Store,
+			OrderNumber: result.OrderNumber,
+			Status:      result.Status,
+			Step:        result.Step,
+			Error:       result.Error,
+		})
+	}
+	return summary
+}
+
+// Subject は通知の件名 (1行目)。
+func (s *Summary) Subject() string {
+	result := "成功"
+	if !s.Success {
+		result = "失敗"
+	}
+	return fmt.Sprintf("[shopify-manager] %s %s (%s)", s.Flow, result, s.Host)
+}
+
+// Text は通知の本文。
+func (s *Summary) Text() string {
+	var lines []string
+	lines = append(lines, s.Subject())
+	lines = append(lines, fmt.Sprintf("開始 %s / 所要時間 %s", s.StartedAt.Local().Format("2006-01-02 15:04:05"), s.Duration))
+	lines = append(lines, fmt.Sprintf("成功 %d / 失敗 %d / スキップ %d", s.Succeeded, s.Failed, s.Skipped))
+	if s.Error != "" {
+		lines = append(lines, "エラー : "+s.Error)
+	}
+	if len(s.FailedOrders) > 0 {
+		lines = append(lines, "失敗・スキップしたオーダー :")
+		for i, order := range s.FailedOrders {
+			if i >= MAX_FAILED_ORDERS_IN_TEXT {
+				lines = append(lines, fmt.Sprintf("  ほか %d 件", len(s.FailedOrders)-i))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("  %s #%d %s %s : %s", order.Store, order.OrderNumber, order.Status, order.Step, order.Error))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// NewNotifier は設定から Notifier を作る。
+func NewNotifier(notifier config.Notifier) (Notifier, error) {
+	switch notifier.Type {
+	case config.NOTIFIER_TYPE_WEBHOOK:
+		return &WebhookNotifier{Url: notifier.Url}, nil
+	case config.NOTIFIER_TYPE_SMTP:
+		return &SmtpNotifier{
+			Server: mail.Server{
+				Host:     notifier.Host,
+				Port:     notifier.Port,
+				Username: notifier.Username,
+				Password: notifier.Password,
+			},
+			From: notifier.From,
+			To:   notifier.To,
+		}, nil
+	case config.NOTIFIER_TYPE_FILE:
+		return &FileNotifier{Dir: notifier.Dir}, nil
+	}
+	return nil, fmt.Errorf("未対応の通知先です : %s", notifier.Type)
+}
+
+// Send は [[Notifiers]] の全ての通知先に summary を送る。
+// 通知の失敗はログに出すのみで、フローの結果には影響させない。
+func Send(notifiers []config.Notifier, summary *Summary) {
+	for i, setting := range notifiers {
+		if setting.On == config.NOTIFY_ON_FAILURE && summary.Success && summary.Failed == 0 {
+			continue
+		}
+
+		err := send(setting, summary)
+		if err != nil {
+			log.Printf("WARN : [[Notifiers]] %d番目 (%s) への通知に失敗しました。%s\n", i+1, setting.Type, err.Error())
+			continue
+		}
+		log.Printf("INFO : [[Notifiers]] %d番目 (%s) に通知しました\n", i+1, setting.Type)
+	}
+}
+
+func send(setting config.Notifier, summary *Summary) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic : %v", r)
+		}
+	}()
+
+	notifier, err := NewNotifier(setting)
+	if err != nil {
+		return err
+	}
+	return notifier.Notify(summary)
+}
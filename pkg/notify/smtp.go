@@ -0,0 +1,21 @@
+package notify
+
+import (
+	"shopify-manager/pkg/infrastructure/mail"
+)
+
+// SmtpNotifier はメールで通知する。
+type SmtpNotifier struct {
+	Server mail.Server
+	From   string
+	To     []string
+}
+
+func (n *SmtpNotifier) Notify(summary *Summary) error {
+	return n.Server.Send(&mail.Message{
+		From:    n.From,
+		To:      n.To,
+		Subject: summary.Subject(),
+		Text:    summary.Text(),
+	})
+}
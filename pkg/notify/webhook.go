@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const WEBHOOK_TIMEOUT = 30 * time.Second
+
+// WebhookNotifier は Slack の Incoming Webhook 互換の JSON ({"text": ...}) を POST する。
+// Slack 以外の受け手向けに、集計結果を summary に含める。
+type WebhookNotifier struct {
+	Url string
+}
+
+type WebhookPayload struct {
+	Text    string   `json:"text"`
+	Summary *Summary `json:"summary"`
+}
+
+func (n *WebhookNotifier) Notify(summary *Summary) error {
+	jsonBytes, err := json.Marshal(WebhookPayload{Text: summary.Text(), Summary: summary})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: WEBHOOK_TIMEOUT}
+	res, err := client.Post(n.Url, "application/json", bytes.NewReader(jsonBytes))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || 300 <= res.StatusCode {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("http status error. status %d. %s", res.StatusCode, string(body))
+	}
+	return nil
+}
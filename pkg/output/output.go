@@ -0,0 +1,83 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+const FORMAT_TEXT = "text"
+const FORMAT_JSON = "json"
+
+// レコードの種類 (type フィールド)
+const TYPE_ORDER = "order"
+const TYPE_MATCH = "match"
+const TYPE_STORE = "store"
+const TYPE_AUDIT = "audit"
+const TYPE_VERIFY = "verify"
+const TYPE_RESTOCK = "restock"
+const TYPE_SNAPSHOT = "snapshot"
+const TYPE_REVERT = "revert"
+const TYPE_DRAFT_ORDER = "draft-order"
+const TYPE_LOTTERY = "lottery"
+const TYPE_CLUSTER = "cluster"
+const TYPE_SUMMARY = "summary"
+
+// 1オーダーの処理結果 (status フィールド)
+const STATUS_SUCCEEDED = "succeeded"
+const STATUS_FAILED = "failed"
+const STATUS_SKIPPED = "skipped"
+
+var format = FORMAT_TEXT
+var mutex sync.Mutex
+
+// SetFormat は -output の値を設定する。
+func SetFormat(value string) error {
+	if value != FORMAT_TEXT && value != FORMAT_JSON {
+		return fmt.Errorf("-output は %s または %s を指定してください : %s", FORMAT_TEXT, FORMAT_JSON, value)
+	}
+	format = value
+	return nil
+}
+
+// IsJSON は JSON 出力モードかどうかを返す。
+func IsJSON() bool {
+	return format == FORMAT_JSON
+}
+
+// Summary はフロー全体の結果。各フローの最後に1行出力する。
+type Summary struct {
+	Type    string `json:"type"`
+	Flow    string `json:"flow"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Write は JSON 出力モードの場合に record を1行の JSON (JSONL) として標準出力に書き出す。
+// テキストモードでは何もしない。
+func Write(record interface{}) {
+	if !IsJSON() {
+		return
+	}
+
+	jsonBytes, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("ERROR : failed to marshal output record. %s\n", err.Error())
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	os.Stdout.Write(append(jsonBytes, '\n'))
+}
+
+// Finish はフローの結果を Summary として書き出す。
+func Finish(flow string, err error) {
+	summary := Summary{Type: TYPE_SUMMARY, Flow: flow, Success: err == nil}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	Write(summary)
+}
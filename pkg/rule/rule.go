@@ -0,0 +1,139 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"shopify-manager/pkg/config"
+)
+
+// Order はルール判定に使うオーダーの項目。
+type Order struct {
+	ID            int64
+	OrderNumber   int
+	Email         string
+	CustomerKey   string
+	Test          bool
+	BrowserIP     string
+	CvvResultCode string
+	AvsResultCode string
+	TotalPrice    float64
+	CreatedAt     time.Time
+}
+
+type Match struct {
+	Order   Order
+	Rule    string
+	Reasons []string
+}
+
+// Evaluate は orders をルールで判定し、一致したオーダーを返す。
+// 1つのオーダーが複数ルールに一致した場合は最初のルールのみ返す。
+func Evaluate(rules []config.Rule, orders []Order) []Match {
+	var matches []Match
+	for _, order := range orders {
+		for _, rule := range rules {
+			reasons, ok := matchRule(rule, order, orders)
+			if !ok {
+				continue
+			}
+			matches = append(matches, Match{Order: order, Rule: rule.Name, Reasons: reasons})
+			break
+		}
+	}
+	return matches
+}
+
+func matchRule(rule config.Rule, order Order, orders []Order) ([]string, bool) {
+	var reasons []string
+
+	if rule.Test != nil {
+		if order.Test != *rule.Test {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("test=%t", order.Test))
+	}
+	if len(rule.BrowserIPs) > 0 {
+		if !containsFold(rule.BrowserIPs, order.BrowserIP) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("browser_ip=%s", order.BrowserIP))
+	}
+	if len(rule.EmailDomains) > 0 {
+		domain := ""
+		if at := strings.LastIndex(order.Email, "@"); at >= 0 {
+			domain = order.Email[at+1:]
+		}
+		if !containsFold(rule.EmailDomains, domain) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("email_domain=%s", domain))
+	}
+	if len(rule.CvvResultCodes) > 0 {
+		if !containsFold(rule.CvvResultCodes, order.CvvResultCode) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("cvv_result_code=%s", order.CvvResultCode))
+	}
+	if len(rule.AvsResultCodes) > 0 {
+		if !containsFold(rule.AvsResultCodes, order.AvsResultCode) {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("avs_result_code=%s", order.AvsResultCode))
+	}
+	if rule.MinTotalPrice > 0 {
+		if order.TotalPrice < rule.MinTotalPrice {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("total_price=%.2f", order.TotalPrice))
+	}
+	if rule.MaxOrdersPerCustomer > 0 {
+		count := countCustomerOrders(order, orders, time.Duration(rule.WindowHours)*time.Hour)
+		if count <= rule.MaxOrdersPerCustomer {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("orders_per_customer=%d", count))
+	}
+
+	// 条件が1つも無いルールは何にも一致させない
+	if len(reasons) == 0 {
+		return nil, false
+	}
+	return reasons, true
+}
+
+// countCustomerOrders は order と同じ顧客が window 内に作成したオーダー数を数える。
+// window が 0 の場合は orders 全体を対象にする。
+func countCustomerOrders(order Order, orders []Order, window time.Duration) int {
+	if order.CustomerKey == "" {
+		return 1
+	}
+
+	count := 0
+	for _, other := range orders {
+		if other.CustomerKey != order.CustomerKey {
+			continue
+		}
+		if window > 0 {
+			diff := order.CreatedAt.Sub(other.CreatedAt)
+			if diff < -window || window < diff {
+				continue
+			}
+		}
+		count++
+	}
+	return count
+}
+
+func containsFold(list []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
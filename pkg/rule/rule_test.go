@@ -0,0 +1,118 @@
+package rule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"shopify-manager/pkg/config"
+)
+
+func TestMatchRule(t *testing.T) {
+	isTest := true
+	base := Order{
+		OrderNumber:   1001,
+		Email:         "user@Example.com",
+		CustomerKey:   "10",
+		Test:          true,
+		BrowserIP:     "203.0.113.1",
+		CvvResultCode: "N",
+		AvsResultCode: "y",
+		TotalPrice:    5000,
+	}
+
+	tests := []struct {
+		name  string
+		rule  config.Rule
+		order Order
+		want  []string
+	}{
+		{name: "test", rule: config.Rule{Test: &isTest}, order: base, want: []string{"test=true"}},
+		{name: "test mismatch", rule: config.Rule{Test: &isTest}, order: Order{Test: false}},
+		{name: "browser ip", rule: config.Rule{BrowserIPs: []string{"203.0.113.1"}}, order: base, want: []string{"browser_ip=203.0.113.1"}},
+		{name: "browser ip mismatch", rule: config.Rule{BrowserIPs: []string{"203.0.113.2"}}, order: base},
+		{name: "browser ip empty", rule: config.Rule{BrowserIPs: []string{"203.0.113.1"}}, order: Order{}},
+		{name: "email domain ignores case", rule: config.Rule{EmailDomains: []string{"example.com"}}, order: base, want: []string{"email_domain=Example.com"}},
+		{name: "email domain mismatch", rule: config.Rule{EmailDomains: []string{"example.org"}}, order: base},
+		{name: "email without domain", rule: config.Rule{EmailDomains: []string{"example.com"}}, order: Order{Email: "example.com"}},
+		{name: "cvv", rule: config.Rule{CvvResultCodes: []string{"N", "P"}}, order: base, want: []string{"cvv_result_code=N"}},
+		{name: "cvv mismatch", rule: config.Rule{CvvResultCodes: []string{"M"}}, order: base},
+		{name: "avs ignores case", rule: config.Rule{AvsResultCodes: []string{"Y"}}, order: base, want: []string{"avs_result_code=y"}},
+		{name: "avs mismatch", rule: config.Rule{AvsResultCodes: []string{"N"}}, order: base},
+		{name: "min total price boundary", rule: config.Rule{MinTotalPrice: 5000}, order: base, want: []string{"total_price=5000.00"}},
+		{name: "min total price below", rule: config.Rule{MinTotalPrice: 5000.01}, order: base},
+		{name: "all conditions", rule: config.Rule{Test: &isTest, EmailDomains: []string{"example.com"}, MinTotalPrice: 1000}, order: base,
+			want: []string{"test=true", "email_domain=Example.com", "total_price=5000.00"}},
+		{name: "one condition mismatch", rule: config.Rule{Test: &isTest, EmailDomains: []string{"example.org"}}, order: base},
+		{name: "empty rule", rule: config.Rule{Name: "empty"}, order: base},
+	}
+	for _, tt := range tests {
+		reasons, ok := matchRule(tt.rule, tt.order, []Order{tt.order})
+		if ok != (tt.want != nil) || !reflect.DeepEqual(reasons, tt.want) {
+			t.Errorf("%s : matchRule = %v, %v, want %v", tt.name, reasons, ok, tt.want)
+		}
+	}
+}
+
+func TestMaxOrdersPerCustomer(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	orders := []Order{
+		{OrderNumber: 1, CustomerKey: "a", CreatedAt: now},
+		{OrderNumber: 2, CustomerKey: "a", CreatedAt: now.Add(-24 * time.Hour)},
+		{OrderNumber: 3, CustomerKey: "a", CreatedAt: now.Add(-24*time.Hour - time.Second)},
+		{OrderNumber: 4, CustomerKey: "b", CreatedAt: now},
+		{OrderNumber: 5, CreatedAt: now},
+		{OrderNumber: 6, CreatedAt: now},
+	}
+
+	tests := []struct {
+		name   string
+		rule   config.Rule
+		order  Order
+		want   int
+		wantOk bool
+	}{
+		{name: "no window counts all", rule: config.Rule{MaxOrdersPerCustomer: 2}, order: orders[0], want: 3, wantOk: true},
+		{name: "at limit", rule: config.Rule{MaxOrdersPerCustomer: 3}, order: orders[0], want: 3},
+		// 24時間ちょうど前は含み、1秒でも超えると含まない
+		{name: "window boundary", rule: config.Rule{MaxOrdersPerCustomer: 1, WindowHours: 24}, order: orders[0], want: 2, wantOk: true},
+		{name: "window before", rule: config.Rule{MaxOrdersPerCustomer: 1, WindowHours: 24}, order: orders[2], want: 2, wantOk: true},
+		{name: "window excludes old", rule: config.Rule{MaxOrdersPerCustomer: 2, WindowHours: 24}, order: orders[0], want: 2},
+		{name: "other customer", rule: config.Rule{MaxOrdersPerCustomer: 1}, order: orders[3], want: 1},
+		{name: "no customer key", rule: config.Rule{MaxOrdersPerCustomer: 1}, order: orders[4], want: 1},
+	}
+	for _, tt := range tests {
+		window := time.Duration(tt.rule.WindowHours) * time.Hour
+		if got := countCustomerOrders(tt.order, orders, window); got != tt.want {
+			t.Errorf("%s : countCustomerOrders = %d, want %d", tt.name, got, tt.want)
+		}
+		if _, ok := matchRule(tt.rule, tt.order, orders); ok != tt.wantOk {
+			t.Errorf("%s : matchRule = %v, want %v", tt.name, ok, tt.wantOk)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	isTest := true
+	rules := []config.Rule{
+		{Name: "empty"},
+		{Name: "test", Test: &isTest},
+		{Name: "cvv", CvvResultCodes: []string{"N"}},
+	}
+	orders := []Order{
+		{OrderNumber: 1, Test: true, CvvResultCode: "N"},
+		{OrderNumber: 2, CvvResultCode: "N"},
+		{OrderNumber: 3, CvvResultCode: "M"},
+	}
+
+	matches := Evaluate(rules, orders)
+	var got []string
+	for _, match := range matches {
+		got = append(got, match.Rule)
+	}
+	// 空のルールは何にも一致せず、複数のルールに一致したオーダーは最初のルールだけ返す
+	want := []string{"test", "cvv"}
+	if !reflect.DeepEqual(got, want) || matches[0].Order.OrderNumber != 1 || matches[1].Order.OrderNumber != 2 {
+		t.Errorf("Evaluate = %+v, want rules %v for orders 1, 2", matches, want)
+	}
+}
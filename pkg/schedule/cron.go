@@ -0,0 +1,118 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron は "分 時 日 月 曜日" の5項目の cron 形式のスケジュール。
+// 各項目は * / 数値 / 範囲 (1-5) / 間隔 (*/15, 0-30/10) / カンマ区切りのリストで指定する。
+// 曜日は 0 (日曜) から 6 (土曜)、7 も日曜として扱う。
+type Cron struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+	anyDay   bool
+	anyWeek  bool
+}
+
+// ParseCron は cron 形式の文字列を解析する。
+func ParseCron(spec string) (*Cron, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron '%s' は「分 時 日 月 曜日」の5項目で指定してください", spec)
+	}
+
+	var err error
+	cron := &Cron{anyDay: fields[2] == "*", anyWeek: fields[4] == "*"}
+	if cron.minutes, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("cron '%s' の分が不正です。%s", spec, err.Error())
+	}
+	if cron.hours, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("cron '%s' の時が不正です。%s", spec, err.Error())
+	}
+	if cron.days, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("cron '%s' の日が不正です。%s", spec, err.Error())
+	}
+	if cron.months, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("cron '%s' の月が不正です。%s", spec, err.Error())
+	}
+	if cron.weekdays, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("cron '%s' の曜日が不正です。%s", spec, err.Error())
+	}
+	if cron.weekdays[7] {
+		cron.weekdays[0] = true
+	}
+	return cron, nil
+}
+
+// Match は t (分単位) がスケジュールに一致するかを返す。
+// 日と曜日の両方が指定された場合は、標準の cron と同じくどちらかに一致すればよい。
+func (c *Cron) Match(t time.Time) bool {
+	if !c.minutes[t.Minute()] || !c.hours[t.Hour()] || !c.months[int(t.Month())] {
+		return false
+	}
+	dayMatch := c.days[t.Day()]
+	weekMatch := c.weekdays[int(t.Weekday())]
+	if c.anyDay || c.anyWeek {
+		return dayMatch && weekMatch
+	}
+	return dayMatch || weekMatch
+}
+
+// Next は after より後の最初の実行時刻を返す。1年以内に無い場合はゼロ値を返す。
+func (c *Cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.AddDate(1, 0, 0); t.Before(limit); t = t.Add(time.Minute) {
+		if c.Match(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func parseField(field string, min, max int) (map[int]bool, error) {
+	values := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[slash+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("間隔 '%s' は1以上の数値で指定してください", part)
+			}
+			part = part[:slash]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("'%s' は数値で指定してください", part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("'%s' は数値で指定してください", part)
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("'%s' は %d から %d の範囲で指定してください", field, min, max)
+		}
+
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
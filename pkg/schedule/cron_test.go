@@ -0,0 +1,90 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "* * * * *"},
+		{spec: "0 9 * * 1-5"},
+		{spec: "*/15 0-6/2 1,15 * 0"},
+		{spec: "0 0 * * 7"},
+		{spec: "0 9 * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "* * * 13 *", wantErr: true},
+		{spec: "* * * * 8", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "a * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := ParseCron(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCron(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCronMatch(t *testing.T) {
+	// 2026-10-19 は月曜日
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		spec string
+		t    time.Time
+		want bool
+	}{
+		{spec: "0 9 * * 1-5", t: at(19, 9, 0), want: true},
+		{spec: "0 9 * * 1-5", t: at(18, 9, 0), want: false},
+		{spec: "0 9 * * 1-5", t: at(19, 9, 1), want: false},
+		{spec: "*/15 * * * *", t: at(19, 10, 45), want: true},
+		{spec: "*/15 * * * *", t: at(19, 10, 50), want: false},
+		{spec: "10/20 * * * *", t: at(19, 10, 50), want: true},
+		{spec: "0 0 * * 7", t: at(18, 0, 0), want: true},
+		// 日と曜日の両方を指定した場合はどちらかに一致すればよい
+		{spec: "0 0 1 * 1", t: at(19, 0, 0), want: true},
+		{spec: "0 0 1 * 1", t: at(20, 0, 0), want: false},
+		{spec: "0 0 20 * 1", t: at(20, 0, 0), want: true},
+		// 片方が * の場合は両方に一致する必要がある
+		{spec: "0 0 * * 1", t: at(20, 0, 0), want: false},
+	}
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cron.Match(tt.t); got != tt.want {
+			t.Errorf("ParseCron(%q).Match(%s) = %v, want %v", tt.spec, tt.t.Format("2006-01-02 15:04 Mon"), got, tt.want)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	after := time.Date(2026, 10, 19, 9, 0, 30, 0, time.Local)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{spec: "* * * * *", want: time.Date(2026, 10, 19, 9, 1, 0, 0, time.Local)},
+		{spec: "0 9 * * 1-5", want: time.Date(2026, 10, 20, 9, 0, 0, 0, time.Local)},
+		{spec: "0 0 1 1 *", want: time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)},
+		{spec: "0 0 31 2 *", want: time.Time{}},
+	}
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cron.Next(after); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package schedule
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+const HISTORY_STATUS_SUCCEEDED = "succeeded"
+const HISTORY_STATUS_FAILED = "failed"
+const HISTORY_STATUS_SKIPPED = "skipped"
+
+// Job はスケジュール実行するジョブ。
+type Job struct {
+	Name string
+	Cron *Cron
+	Run  func() error
+}
+
+// History は実行履歴 (JSON Lines) の1行。
+type History struct {
+	Job         string     `json:"job"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Scheduler は毎分ジョブの cron を判定して実行する。
+// 実行は1件ずつ順番に行い、前回の実行 (実行待ちを含む) が終わっていないジョブの実行はスキップする。
+// 実行履歴は appendFile で historyPath に追記する (config から参照されるため保存先のパッケージは使わずに受け取る)。
+type Scheduler struct {
+	historyPath  string
+	appendFile   func(name string, data []byte) error
+	jobs         []*Job
+	runMutex     sync.Mutex
+	mutex        sync.Mutex
+	pending      map[string]bool
+	historyMutex sync.Mutex
+}
+
+func New(historyPath string, appendFile func(name string, data []byte) error) *Scheduler {
+	return &Scheduler{historyPath: historyPath, appendFile: appendFile, pending: map[string]bool{}}
+}
+
+// Add は spec (cron 形式) で run を実行するジョブを登録する。
+func (s *Scheduler) Add(name, spec string, run func() error) error {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	s.jobs = append(s.jobs, &Job{Name: name, Cron: cron, Run: run})
+	return nil
+}
+
+// Start はスケジュールを開始し、戻らない。
+func (s *Scheduler) Start() {
+	for _, job := range s.jobs {
+		log.Printf("INFO : schedule job '%s' next run at %s\n", job.Name, job.Cron.Next(time.Now()).Format("2006-01-02 15:04"))
+	}
+
+	last := time.Now().Truncate(time.Minute)
+	for {
+		next := last.Add(time.Minute)
+		time.Sleep(time.Until(next))
+
+		// スリープが延びた場合も途中の分を飛ばさないよう、前回の判定から現在までの各分を判定する
+		now := time.Now().Truncate(time.Minute)
+		for t := next; !t.After(now); t = t.Add(time.Minute) {
+			for _, job := range s.jobs {
+				if job.Cron.Match(t) {
+					s.trigger(job, t)
+				}
+			}
+		}
+		if now.After(last) {
+			last = now
+		}
+	}
+}
+
+func (s *Scheduler) trigger(job *Job, scheduledAt time.Time) {
+	s.mutex.Lock()
+	if s.pending[job.Name] {
+		s.mutex.Unlock()
+		log.Printf("WARN : schedule job '%s' skipped due to previous run is not finished\n", job.Name)
+		s.record(History{Job: job.Name, ScheduledAt: scheduledAt, Status: HISTORY_STATUS_SKIPPED, Error: "previous run is not finished"})
+		return
+	}
+	s.pending[job.Name] = true
+	s.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			s.mutex.Lock()
+			s.pending[job.Name] = false
+			s.mutex.Unlock()
+		}()
+
+		s.runMutex.Lock()
+		defer s.runMutex.Unlock()
+
+		startedAt := time.Now()
+		history := History{Job: job.Name, ScheduledAt: scheduledAt, StartedAt: &startedAt}
+		log.Printf("INFO : schedule job '%s' started\n", job.Name)
+		err := job.Run()
+		finishedAt := time.Now()
+		history.FinishedAt = &finishedAt
+		if err != nil {
+			history.Status = HISTORY_STATUS_FAILED
+			history.Error = err.Error()
+			log.Printf("ERROR : schedule job '%s' failed. %s\n", job.Name, err.Error())
+		} else {
+			history.Status = HISTORY_STATUS_SUCCEEDED
+			log.Printf("INFO : schedule job '%s' succeeded\n", job.Name)
+		}
+		s.record(history)
+		log.Printf("INFO : schedule job '%s' next run at %s\n", job.Name, job.Cron.Next(time.Now()).Format("2006-01-02 15:04"))
+	}()
+}
+
+// record は実行履歴を追記する。書き込みに失敗してもスケジュールは止めない。
+func (s *Scheduler) record(history History) {
+	s.historyMutex.Lock()
+	defer s.historyMutex.Unlock()
+
+	jsonBytes, err := json.Marshal(history)
+	if err == nil {
+		err = s.appendFile(s.historyPath, append(jsonBytes, '\n'))
+	}
+	if err != nil {
+		log.Printf("ERROR : failed to write schedule history to %s. %s\n", s.historyPath, err.Error())
+	}
+}
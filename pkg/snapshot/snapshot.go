@@ -0,0 +1,249 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/storage"
+)
+
+// 実行毎のディレクトリ・アーカイブ名に使う開始日時
+const RUN_TIME_LAYOUT = "20060102-150405.000"
+
+const JSON_EXT = ".json"
+const TAR_GZ_EXT = ".tar.gz"
+
+var setting = config.Snapshot{Dir: config.DEFAULT_SNAPSHOT_DIR, Format: config.SNAPSHOT_FORMAT_DIR}
+
+// Configure は [Snapshot] の保存先と形式を設定する。
+func Configure(value config.Snapshot) {
+	setting = value
+}
+
+// Snapshot は変更を行う前のオーダー1件の状態。Order / Transactions は API のレスポンスそのまま。
+// Source は Find で読み込んだファイル (アーカイブの場合は "<アーカイブ>:<エントリ>")。
+type Snapshot struct {
+	Store        string          `json:"store"`
+	OrderNumber  int             `json:"order_number"`
+	OrderID      int64           `json:"order_id"`
+	TakenAt      time.Time       `json:"taken_at"`
+	Order        json.RawMessage `json:"order"`
+	Transactions json.RawMessage `json:"transactions"`
+	Source       string          `json:"-"`
+}
+
+// Archive は1回の実行で保存するスナップショットの書き込み先。path は保存先 ([Storage]) の name。
+// [Snapshot] format が off の場合は nil で、全てのメソッドは何もしない。
+// tar.gz 形式では buffer に書いた分を1件毎に保存先のアーカイブへ追記する。
+type Archive struct {
+	format string
+	path   string
+	mutex  sync.Mutex
+	buffer *bytes.Buffer
+	gzip   *gzip.Writer
+	tar    *tar.Writer
+}
+
+// Begin はストアの1回の実行分の書き込み先を用意する。ファイルは最初の Save で作る。
+func Begin(storeName string) *Archive {
+	if setting.Format == config.SNAPSHOT_FORMAT_OFF {
+		return nil
+	}
+
+	name := path.Join(filepath.ToSlash(setting.Dir), storeName, time.Now().Format(RUN_TIME_LAYOUT))
+	if setting.Format == config.SNAPSHOT_FORMAT_TAR_GZ {
+		name += TAR_GZ_EXT
+	}
+	return &Archive{format: setting.Format, path: name}
+}
+
+// Save はスナップショットを1件保存する。並行に呼び出してよい。
+func (a *Archive) Save(snapshot *Snapshot) error {
+	if a == nil {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := strconv.Itoa(snapshot.OrderNumber) + JSON_EXT
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.format == config.SNAPSHOT_FORMAT_DIR {
+		return storage.WriteFile(path.Join(a.path, name), data)
+	}
+
+	if a.tar == nil {
+		// 既存のアーカイブに追記すると壊れるため、同じ名前のアーカイブがある場合は保存しない
+		_, err = storage.ReadFile(a.path)
+		if err == nil {
+			return fmt.Errorf("%s は既に存在します", storage.Location(a.path))
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		a.buffer = new(bytes.Buffer)
+		a.gzip = gzip.NewWriter(a.buffer)
+		a.tar = tar.NewWriter(a.gzip)
+	}
+
+	err = a.tar.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: snapshot.TakenAt})
+	if err != nil {
+		return err
+	}
+	_, err = a.tar.Write(data)
+	if err != nil {
+		return err
+	}
+	// 途中で異常終了しても保存済みの分を読めるように、1件毎に書き出す
+	err = a.tar.Flush()
+	if err != nil {
+		return err
+	}
+	err = a.gzip.Flush()
+	if err != nil {
+		return err
+	}
+	return a.flush()
+}
+
+// flush は buffer に書いた分を保存先のアーカイブに追記する。
+func (a *Archive) flush() error {
+	err := storage.AppendFile(a.path, a.buffer.Bytes())
+	a.buffer.Reset()
+	return err
+}
+
+// Close はアーカイブを閉じる。dir 形式では何もしない。
+func (a *Archive) Close() error {
+	if a == nil || a.tar == nil {
+		return nil
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	err := a.tar.Close()
+	if err == nil {
+		err = a.gzip.Close()
+	}
+	if err == nil {
+		err = a.flush()
+	}
+	a.tar = nil
+	return err
+}
+
+// Path は保存先のディレクトリまたはアーカイブの場所 (ローカルのパスや s3://bucket/key)。
+func (a *Archive) Path() string {
+	if a == nil {
+		return ""
+	}
+	return storage.Location(a.path)
+}
+
+// Find は [Snapshot] dir に保存されたストアとオーダー番号のスナップショットを、古い順に返す。
+// dir 形式・tar.gz 形式のどちらも読む。
+func Find(storeName string, orderNumber int) ([]*Snapshot, error) {
+	names, err := storage.ListFiles(path.Join(filepath.ToSlash(setting.Dir), storeName))
+	if err != nil {
+		return nil, err
+	}
+
+	jsonName := strconv.Itoa(orderNumber) + JSON_EXT
+	var snapshots []*Snapshot
+	for _, name := range names {
+		if path.Base(name) == jsonName {
+			snapshot, err := readFile(name)
+			if err != nil {
+				return nil, err
+			}
+			snapshots = append(snapshots, snapshot)
+		} else if strings.HasSuffix(name, TAR_GZ_EXT) {
+			found, err := readArchive(name, jsonName)
+			if err != nil {
+				return nil, err
+			}
+			snapshots = append(snapshots, found...)
+		}
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].TakenAt.Before(snapshots[j].TakenAt) })
+	return snapshots, nil
+}
+
+func readFile(name string) (*Snapshot, error) {
+	data, err := storage.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data, storage.Location(name))
+}
+
+func readArchive(name, jsonName string) ([]*Snapshot, error) {
+	data, err := storage.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	location := storage.Location(name)
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s : %s", location, err.Error())
+	}
+	defer gzipReader.Close()
+
+	var snapshots []*Snapshot
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// 書き込み中・異常終了したアーカイブは読めた所まで使う
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s : %s", location, err.Error())
+		}
+		if header.Name != jsonName {
+			continue
+		}
+		entry, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("%s : %s", location, err.Error())
+		}
+		snapshot, err := parse(entry, location+":"+header.Name)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+func parse(data []byte, source string) (*Snapshot, error) {
+	snapshot := new(Snapshot)
+	err := json.Unmarshal(data, snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("%s : %s", source, err.Error())
+	}
+	snapshot.Source = source
+	return snapshot, nil
+}
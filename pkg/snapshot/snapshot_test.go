@@ -0,0 +1,63 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"shopify-manager/pkg/config"
+	"shopify-manager/pkg/storage"
+)
+
+func TestSaveAndFind(t *testing.T) {
+	defer Configure(setting)
+	defer storage.Use(storage.NewLocal(config.DEFAULT_STORAGE_DIR))
+
+	for _, format := range []string{config.SNAPSHOT_FORMAT_DIR, config.SNAPSHOT_FORMAT_TAR_GZ} {
+		t.Run(format, func(t *testing.T) {
+			storage.Use(storage.NewMemory())
+			Configure(config.Snapshot{Dir: "./snapshots", Format: format})
+
+			takenAt := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+			var archives []*Archive
+			for run := 0; run < 2; run++ {
+				archive := Begin("jp")
+				archives = append(archives, archive)
+				for _, orderNumber := range []int{1001, 1002} {
+					err := archive.Save(&Snapshot{Store: "jp", OrderNumber: orderNumber, TakenAt: takenAt.Add(time.Duration(run) * time.Hour), Order: json.RawMessage(`{"id":1}`)})
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				// 閉じる前 (書き込み中) のアーカイブも読めるように、1回目は閉じずに検索する
+				if run == 1 {
+					if err := archive.Close(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				time.Sleep(2 * time.Millisecond)
+			}
+
+			found, err := Find("jp", 1002)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var takenAts []time.Time
+			for _, snapshot := range found {
+				if snapshot.OrderNumber != 1002 || snapshot.Source == "" {
+					t.Errorf("snapshot = %+v", snapshot)
+				}
+				takenAts = append(takenAts, snapshot.TakenAt)
+			}
+			if want := []time.Time{takenAt, takenAt.Add(time.Hour)}; !reflect.DeepEqual(takenAts, want) {
+				t.Errorf("TakenAt = %v, want %v", takenAts, want)
+			}
+
+			if found, _ := Find("us", 1002); len(found) != 0 {
+				t.Errorf("Find(us) = %d snapshots, want 0", len(found))
+			}
+			archives[0].Close()
+		})
+	}
+}
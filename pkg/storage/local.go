@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ロックファイルを取れるまで待つ時間と、異常終了で残ったとみなして消すまでの時間
+const LOCK_TIMEOUT = 30 * time.Second
+const LOCK_STALE_AGE = 2 * time.Minute
+const LOCK_RETRY_INTERVAL = 10 * time.Millisecond
+
+// Local は dir 以下のローカルファイルに読み書きする。絶対パスの name は dir に関わらずそのパスを使う。
+type Local struct {
+	dir string
+}
+
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+func (l *Local) Read(name string) ([]byte, error) {
+	return ioutil.ReadFile(l.Location(name))
+}
+
+// Write は一時ファイルに書いてから置き換え、途中で失敗しても元のファイルを壊さない。
+func (l *Local) Write(name string, data []byte) error {
+	path := l.Location(name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Append はファイルの末尾に追記し、異常終了しても追記した分が残るようにディスクに書き出す。
+func (l *Local) Append(name string, data []byte) error {
+	path := l.Location(name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	if err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// Lock は name に ".lock" を付けたロックファイルを作り、別のプロセスとも排他する。
+// LOCK_STALE_AGE より古いロックファイルは異常終了で残ったものとみなして消す。
+func (l *Local) Lock(name string) (func(), error) {
+	lockPath := l.Location(name) + ".lock"
+	err := os.MkdirAll(filepath.Dir(lockPath), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(LOCK_TIMEOUT)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		info, statErr := os.Stat(lockPath)
+		if statErr == nil && time.Since(info.ModTime()) > LOCK_STALE_AGE {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s のロックを取得できません。他のプロセスが使用中です", lockPath)
+		}
+		time.Sleep(LOCK_RETRY_INTERVAL)
+	}
+}
+
+func (l *Local) List(dir string) ([]string, error) {
+	root := l.Location(dir)
+	var names []string
+	err := filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		names = append(names, path.Join(dir, filepath.ToSlash(rel)))
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (l *Local) Location(name string) string {
+	localPath := filepath.FromSlash(name)
+	if filepath.IsAbs(localPath) {
+		return localPath
+	}
+	return filepath.Join(l.dir, localPath)
+}
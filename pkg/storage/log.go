@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// ログをまとめて書き出す大きさと間隔
+const LOG_FLUSH_SIZE = 64 * 1024
+const LOG_FLUSH_INTERVAL = time.Minute
+
+// 1つのログのファイルの上限。超えたら同じ実行の次のファイルに書く
+const LOG_PART_SIZE = 8 * 1024 * 1024
+
+// LogWriter はログを保存先の dir に実行毎のファイルとして書く io.Writer。
+// 追記できない保存先 (S3 など) で1つのファイルに追記すると、書き出す度にそれまでの全てのログを読み直して
+// 書き直すことになるため、実行開始時刻とプロセス ID の名前 (dir/20060102-150405-1234.log) で実行毎に分け、
+// LOG_PART_SIZE を超えたら -2.log, -3.log と次のファイルに移る。
+// 1行毎に保存先へ書き込むと遅いため、LOG_FLUSH_SIZE か LOG_FLUSH_INTERVAL 毎にまとめて書き出す。
+// log の出力先に使うため、書き出しの失敗は log ではなく標準エラー出力に出し、Write はエラーを返さない。
+type LogWriter struct {
+	prefix    string
+	part      int
+	mutex     sync.Mutex
+	content   bytes.Buffer
+	pending   int
+	flushedAt time.Time
+}
+
+func NewLogWriter(dir string) *LogWriter {
+	now := time.Now()
+	prefix := path.Join(dir, fmt.Sprintf("%s-%d", now.Format("20060102-150405"), os.Getpid()))
+	return &LogWriter{prefix: prefix, part: 1, flushedAt: now}
+}
+
+// Name は現在書き出しているファイルの name。
+func (w *LogWriter) Name() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.name()
+}
+
+func (w *LogWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.content.Write(p)
+	w.pending += len(p)
+	if w.pending >= LOG_FLUSH_SIZE || time.Since(w.flushedAt) >= LOG_FLUSH_INTERVAL {
+		w.flush()
+	}
+	return len(p), nil
+}
+
+// Close は残りのログを書き出す。
+func (w *LogWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.flush()
+}
+
+// flush は現在のファイルにこの実行のログを書き出す。
+// 失敗した場合も溜めたログは残し、次の書き出しで合わせて書く (ローカルの info.log にも残っている)。
+func (w *LogWriter) flush() error {
+	w.flushedAt = time.Now()
+	if w.pending == 0 {
+		return nil
+	}
+
+	name := w.name()
+	err := WriteFile(name, w.content.Bytes())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR : %s へのログの書き出しに失敗しました。%s\n", Location(name), err.Error())
+		return err
+	}
+
+	w.pending = 0
+	if w.content.Len() >= LOG_PART_SIZE {
+		w.part++
+		w.content.Reset()
+	}
+	return nil
+}
+
+func (w *LogWriter) name() string {
+	if w.part == 1 {
+		return w.prefix + ".log"
+	}
+	return fmt.Sprintf("%s-%d.log", w.prefix, w.part)
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory はメモリ上に保持する保存先。S3 などを使わずに読み書きを確認する場合に使う。
+// name は path.Clean した形で保持するため、"./a.xlsx" と "a.xlsx" は同じファイルになる。
+type Memory struct {
+	mutex sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{files: map[string][]byte{}}
+}
+
+func (m *Memory) Read(name string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	data, ok := m.files[path.Clean(name)]
+	if !ok {
+		return nil, fmt.Errorf("%s : %w", m.Location(name), os.ErrNotExist)
+	}
+	return append([]byte{}, data...), nil
+}
+
+func (m *Memory) Write(name string, data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.files[path.Clean(name)] = append([]byte{}, data...)
+	return nil
+}
+
+func (m *Memory) List(dir string) ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	prefix := path.Clean(dir) + "/"
+	var names []string
+	for name := range m.files {
+		if prefix == "./" || strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *Memory) Location(name string) string {
+	return "memory:" + path.Clean(name)
+}
+
+// Names は保存されている name の一覧を返す。
+func (m *Memory) Names() []string {
+	names, _ := m.List(".")
+	return names
+}
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"shopify-manager/pkg/config"
+)
+
+const S3_TIMEOUT = 60 * time.Second
+const S3_SERVICE = "s3"
+const AMZ_DATE_LAYOUT = "20060102T150405Z"
+
+// S3 は S3 互換のオブジェクトストレージ (AWS S3 / MinIO など) に読み書きする。
+// リクエストは AWS Signature Version 4 で署名する。
+type S3 struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+}
+
+func NewS3(value config.Storage) *S3 {
+	endpoint := value.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", value.Region)
+	}
+	// endpoint の形式は設定の読み込み時に検証済み
+	endpointUrl, _ := url.Parse(endpoint)
+
+	return &S3{
+		endpoint:  endpointUrl,
+		region:    value.Region,
+		bucket:    value.Bucket,
+		prefix:    strings.Trim(value.Prefix, "/"),
+		accessKey: value.AccessKey,
+		secretKey: value.SecretKey,
+		pathStyle: value.PathStyle,
+		client:    &http.Client{Timeout: S3_TIMEOUT},
+	}
+}
+
+// S3ListResult は ListObjectsV2 のレスポンス。
+type S3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) Read(name string) ([]byte, error) {
+	res, err := s.do("GET", "/"+s.key(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s : %w", s.Location(name), os.ErrNotExist)
+	}
+	if res.StatusCode < 200 || 300 <= res.StatusCode {
+		return nil, fmt.Errorf("%s : s3 status error. status %d %s", s.Location(name), res.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+func (s *S3) Write(name string, data []byte) error {
+	res, err := s.do("PUT", "/"+s.key(name), nil, data)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || 300 <= res.StatusCode {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("%s : s3 status error. status %d %s", s.Location(name), res.StatusCode, string(body))
+	}
+	return nil
+}
+
+// List は ListObjectsV2 で dir 以下のキーを1000件ずつ取得する。
+func (s *S3) List(dir string) ([]string, error) {
+	dirKey := s.key(dir)
+	query := url.Values{"list-type": {"2"}}
+	if dirKey != "" && dirKey != "." {
+		query.Set("prefix", dirKey+"/")
+	} else {
+		dirKey = ""
+	}
+
+	var names []string
+	for {
+		res, err := s.do("GET", "/", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode < 200 || 300 <= res.StatusCode {
+			return nil, fmt.Errorf("%s : s3 status error. status %d %s", s.Location(dir), res.StatusCode, string(body))
+		}
+
+		var result S3ListResult
+		err = xml.Unmarshal(body, &result)
+		if err != nil {
+			return nil, fmt.Errorf("%s : %s", s.Location(dir), err.Error())
+		}
+		for _, content := range result.Contents {
+			names = append(names, path.Join(dir, strings.TrimPrefix(strings.TrimPrefix(content.Key, dirKey), "/")))
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	return names, nil
+}
+
+func (s *S3) Location(name string) string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, s.key(name))
+}
+
+func (s *S3) key(name string) string {
+	return strings.TrimPrefix(path.Join(s.prefix, name), "/")
+}
+
+// do は objectPath (バケット内の / 始まりのパス) にリクエストする。
+func (s *S3) do(method, objectPath string, query url.Values, body []byte) (*http.Response, error) {
+	objectUrl := *s.endpoint
+	if s.pathStyle {
+		objectPath = "/" + s.bucket + objectPath
+	} else {
+		objectUrl.Host = s.bucket + "." + objectUrl.Host
+	}
+	objectUrl.Path = objectPath
+	objectUrl.RawPath = encodePath(objectPath)
+	objectUrl.RawQuery = encodeQuery(query)
+
+	req, err := http.NewRequest(method, objectUrl.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	return s.client.Do(req)
+}
+
+// sign はリクエストに Signature Version 4 の Authorization ヘッダを付ける。
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format(AMZ_DATE_LAYOUT)
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" + "x-amz-content-sha256:" + payloadHash + "\n" + "x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.region, S3_SERVICE, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signature := hex.EncodeToString(hmacSha256(signingKey(s.secretKey, date, s.region, S3_SERVICE), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKey, scope, signedHeaders, signature))
+}
+
+// signingKey は Signature Version 4 の日付・リージョン・サービス毎の署名鍵を導出する。
+func signingKey(secretKey, date, region, service string) []byte {
+	key := hmacSha256([]byte("AWS4"+secretKey), date)
+	key = hmacSha256(key, region)
+	key = hmacSha256(key, service)
+	return hmacSha256(key, "aws4_request")
+}
+
+// encodeQuery は署名の規則に従って、クエリをキーの順に並べ、空白を %20 にエンコードする。
+func encodeQuery(query url.Values) string {
+	return strings.Replace(query.Encode(), "+", "%20", -1)
+}
+
+// encodePath は S3 の署名の規則に従って、パスの区切り以外を URI エンコードする。
+func encodePath(objectPath string) string {
+	segments := strings.Split(objectPath, "/")
+	for i, segment := range segments {
+		segments[i] = strings.Replace(url.QueryEscape(segment), "+", "%20", -1)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"shopify-manager/pkg/config"
+
+	"github.com/tealeg/xlsx"
+)
+
+// Storage は入出力ファイルの読み書き先。name は作業ディレクトリ・バケットからの / 区切りの相対パス。
+// 存在しない name の Read は os.ErrNotExist をラップしたエラーを返す。
+// List は dir 以下の全ての name を返し、dir が無い場合は空にする。
+type Storage interface {
+	Read(name string) ([]byte, error)
+	Write(name string, data []byte) error
+	List(dir string) ([]string, error)
+	Location(name string) string
+}
+
+// Appender は末尾に追記できる保存先。
+type Appender interface {
+	Append(name string, data []byte) error
+}
+
+// Locker は name を複数のプロセスから排他して使うためのロックを取れる保存先。
+// 戻り値の関数でロックを解放する。
+type Locker interface {
+	Lock(name string) (func(), error)
+}
+
+var current Storage = NewLocal(config.DEFAULT_STORAGE_DIR)
+
+var appendMutex sync.Mutex
+
+// Locker でない保存先のロック。プロセス内の排他だけになる
+var nameLocks = map[string]*sync.Mutex{}
+var nameLocksMutex sync.Mutex
+
+// Configure は [Storage] の保存先を設定する。
+func Configure(value config.Storage) error {
+	switch value.Type {
+	case config.STORAGE_TYPE_LOCAL:
+		current = NewLocal(value.Dir)
+	case config.STORAGE_TYPE_S3:
+		current = NewS3(value)
+	case config.STORAGE_TYPE_MEMORY:
+		current = NewMemory()
+	default:
+		return fmt.Errorf("未対応の storage type です : %s", value.Type)
+	}
+	return nil
+}
+
+// Use は保存先を storage に差し替える。動作確認で Memory を使う場合など。
+func Use(storage Storage) {
+	current = storage
+}
+
+func ReadFile(name string) ([]byte, error) {
+	return current.Read(name)
+}
+
+func WriteFile(name string, data []byte) error {
+	return current.Write(name, data)
+}
+
+// AppendFile は name の末尾に data を追記する。name が無い場合は作る。
+// 追記できない保存先 (S3 など) では読み込んで書き直すため、同じ name に複数のプロセスから追記しないこと。
+func AppendFile(name string, data []byte) error {
+	appendMutex.Lock()
+	defer appendMutex.Unlock()
+
+	storage := current
+	if appender, ok := storage.(Appender); ok {
+		return appender.Append(name, data)
+	}
+
+	old, err := storage.Read(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return storage.Write(name, append(old, data...))
+}
+
+// Lock は name を読んでから追記するまでの間、他の書き手を待たせるためのロックを取る。
+// ローカルは別のプロセスとも排他するが、S3 などではプロセス内の排他だけになる。
+func Lock(name string) (func(), error) {
+	if locker, ok := current.(Locker); ok {
+		return locker.Lock(name)
+	}
+
+	nameLocksMutex.Lock()
+	mutex, ok := nameLocks[name]
+	if !ok {
+		mutex = &sync.Mutex{}
+		nameLocks[name] = mutex
+	}
+	nameLocksMutex.Unlock()
+
+	mutex.Lock()
+	return mutex.Unlock, nil
+}
+
+// ListFiles は dir 以下の name を並べて返す。
+func ListFiles(dir string) ([]string, error) {
+	return current.List(dir)
+}
+
+// IsLocal は保存先がローカルのディレクトリかどうか。
+func IsLocal() bool {
+	_, ok := current.(*Local)
+	return ok
+}
+
+// Location はログに出す name の保存先 (ローカルのパスや s3://bucket/key)。
+func Location(name string) string {
+	return current.Location(name)
+}
+
+// OpenXlsx は保存先のエクセルを開く。
+func OpenXlsx(name string) (*xlsx.File, error) {
+	data, err := ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return xlsx.OpenBinary(data)
+}
+
+// SaveXlsx はエクセルを保存先に書き出す。
+func SaveXlsx(file *xlsx.File, name string) error {
+	var buffer bytes.Buffer
+	err := file.Write(&buffer)
+	if err != nil {
+		return err
+	}
+
+	err = WriteFile(name, buffer.Bytes())
+	if err != nil {
+		return err
+	}
+	log.Printf("INFO : %s に保存しました\n", Location(name))
+	return nil
+}
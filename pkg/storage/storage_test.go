@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"shopify-manager/pkg/config"
+
+	"github.com/tealeg/xlsx"
+)
+
+func TestMemory(t *testing.T) {
+	tests := []struct {
+		name    string
+		writes  [][2]string
+		read    string
+		want    string
+		missing bool
+	}{
+		{name: "read written", writes: [][2]string{{"a.xlsx", "a"}}, read: "a.xlsx", want: "a"},
+		{name: "same file for ./", writes: [][2]string{{"./a.xlsx", "a"}}, read: "a.xlsx", want: "a"},
+		{name: "overwrite", writes: [][2]string{{"a.xlsx", "a"}, {"./a.xlsx", "b"}}, read: "a.xlsx", want: "b"},
+		{name: "not exist", writes: [][2]string{{"a.xlsx", "a"}}, read: "b.xlsx", missing: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := NewMemory()
+			for _, write := range tt.writes {
+				if err := memory.Write(write[0], []byte(write[1])); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			data, err := memory.Read(tt.read)
+			if tt.missing {
+				if !errors.Is(err, os.ErrNotExist) {
+					t.Fatalf("Read(%q) error = %v, want os.ErrNotExist", tt.read, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Read(%q) = %q, want %q", tt.read, data, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryReadReturnsCopy(t *testing.T) {
+	memory := NewMemory()
+	data := []byte("abc")
+	memory.Write("a", data)
+	data[0] = 'x'
+
+	read, _ := memory.Read("a")
+	read[1] = 'x'
+	if got, _ := memory.Read("a"); string(got) != "abc" {
+		t.Errorf("Read = %q, want %q", got, "abc")
+	}
+}
+
+func TestList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, storage := range []Storage{NewMemory(), NewLocal(dir)} {
+		for _, name := range []string{"snapshots/jp/1/1001.json", "snapshots/jp/2.tar.gz", "snapshots/us/1/1001.json", "audit.jsonl"} {
+			if err := storage.Write(name, []byte(name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tests := []struct {
+			dir  string
+			want []string
+		}{
+			{dir: "snapshots/jp", want: []string{"snapshots/jp/1/1001.json", "snapshots/jp/2.tar.gz"}},
+			{dir: "./snapshots/us", want: []string{"snapshots/us/1/1001.json"}},
+			{dir: "snapshots/none", want: nil},
+		}
+		for _, tt := range tests {
+			got, err := storage.List(tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%T List(%q) = %v, want %v", storage, tt.dir, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestAppendFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer Use(current)
+
+	// Local はファイルに直接追記し、Memory は読み込んで書き直す
+	for _, storage := range []Storage{NewMemory(), NewLocal(dir)} {
+		Use(storage)
+		for _, line := range []string{"1\n", "", "2\n"} {
+			if err := AppendFile("logs/audit.jsonl", []byte(line)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, err := ReadFile("logs/audit.jsonl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "1\n2\n" {
+			t.Errorf("%T AppendFile = %q, want %q", storage, data, "1\n2\n")
+		}
+	}
+}
+
+func TestXlsxRoundTrip(t *testing.T) {
+	memory := NewMemory()
+	defer Use(current)
+	Use(memory)
+
+	file := xlsx.NewFile()
+	sheet, err := file.AddSheet("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]string{{"OrderID", "Store"}, {"1001", "jp"}, {"1002", ""}}
+	for _, values := range rows {
+		row := sheet.AddRow()
+		for _, value := range values {
+			row.AddCell().SetString(value)
+		}
+	}
+
+	if err := SaveXlsx(file, "reports/result.xlsx"); err != nil {
+		t.Fatal(err)
+	}
+	if names := memory.Names(); !reflect.DeepEqual(names, []string{"reports/result.xlsx"}) {
+		t.Fatalf("Names = %v", names)
+	}
+
+	opened, err := OpenXlsx("./reports/result.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][]string
+	for _, row := range opened.Sheets[0].Rows {
+		var values []string
+		for _, cell := range row.Cells {
+			values = append(values, cell.String())
+		}
+		got = append(got, values)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("OpenXlsx rows = %v, want %v", got, rows)
+	}
+
+	if _, err := OpenXlsx("missing.xlsx"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenXlsx(missing) error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	memory := NewMemory()
+	Use(memory)
+	defer Use(NewLocal(config.DEFAULT_STORAGE_DIR))
+
+	// 前回の実行のログには触れない
+	memory.Write("logs/previous.log", []byte("previous\n"))
+
+	writer := NewLogWriter("logs")
+	first := writer.Name()
+	if !strings.HasPrefix(first, "logs/") || !strings.HasSuffix(first, ".log") {
+		t.Fatalf("Name = %q", first)
+	}
+	writer.Write([]byte("line 1\n"))
+	writer.Write([]byte("line 2\n"))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := memory.Read(first); string(data) != "line 1\nline 2\n" {
+		t.Errorf("%s = %q", first, data)
+	}
+	if data, _ := memory.Read("logs/previous.log"); string(data) != "previous\n" {
+		t.Errorf("previous.log = %q", data)
+	}
+
+	// LOG_PART_SIZE を超えたら次のファイルに書く
+	chunk := bytes.Repeat([]byte("x"), 1024*1024)
+	for i := 0; i < LOG_PART_SIZE/len(chunk); i++ {
+		writer.Write(chunk)
+	}
+	writer.Write([]byte("next\n"))
+	writer.Close()
+	second := writer.Name()
+	if second == first {
+		t.Fatalf("Name = %q after %d bytes", second, LOG_PART_SIZE)
+	}
+	if data, _ := memory.Read(first); len(data) < LOG_PART_SIZE {
+		t.Errorf("%s has %d bytes", first, len(data))
+	}
+	if data, _ := memory.Read(second); string(data) != "next\n" {
+		t.Errorf("%s = %q", second, data)
+	}
+}
@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"shopify-manager/pkg/api/shopify"
+	"shopify-manager/pkg/config"
+)
+
+const WEBHOOK_PATH = "/webhooks"
+
+const HEADER_HMAC = "X-Shopify-Hmac-Sha256"
+const HEADER_TOPIC = "X-Shopify-Topic"
+const HEADER_SHOP_DOMAIN = "X-Shopify-Shop-Domain"
+const HEADER_WEBHOOK_ID = "X-Shopify-Webhook-Id"
+
+const MAX_BODY_SIZE = 5 << 20
+
+// Shopify は 5 秒以内に応答しないと再送するため、受信したイベントはキューに入れて後で処理する。
+const QUEUE_SIZE = 1000
+
+// 再送された Webhook を重複して処理しないよう、直近の Webhook ID を覚えておく件数。
+const RECENT_WEBHOOK_ID_SIZE = 10000
+
+// Event は受信したオーダーの Webhook。
+type Event struct {
+	Topic      string
+	WebhookID  string
+	Store      *config.Store
+	Order      shopify.Order
+	ReceivedAt time.Time
+}
+
+// Action は Event に対して実行する処理。action 名 (config.WEBHOOK_ACTION_*) で登録する。
+type Action func(event *Event) error
+
+type topicAction struct {
+	name   string
+	action Action
+}
+
+// Handler は Webhook を受信して署名を検証し、トピックに設定された処理を順番に実行する。
+type Handler struct {
+	config   *config.Config
+	actions  map[string][]topicAction
+	queue    chan *Event
+	mutex    sync.Mutex
+	seen     map[string]bool
+	seenList []string
+}
+
+// NewHandler は [[Serve.Actions]] の設定に従い、トピック毎に actions の処理を割り当てる。
+func NewHandler(config *config.Config, actions map[string]Action) (*Handler, error) {
+	if len(config.Serve.Actions) == 0 {
+		return nil, fmt.Errorf("[[Serve.Actions]] が設定されていません")
+	}
+
+	topicActions := map[string][]topicAction{}
+	for _, serveAction := range config.Serve.Actions {
+		action, ok := actions[serveAction.Action]
+		if !ok {
+			return nil, fmt.Errorf("処理 '%s' は serve では使えません", serveAction.Action)
+		}
+		for _, topic := range serveAction.Topics {
+			topicActions[topic] = append(topicActions[topic], topicAction{name: serveAction.Action, action: action})
+		}
+	}
+
+	for _, name := range config.StoreNames() {
+		if config.Stores[name].WebhookSecret == "" {
+			log.Printf("WARN : [Stores.%s] webhookSecret が設定されていないため、このストアの Webhook は受け付けません\n", name)
+		}
+	}
+
+	return &Handler{
+		config:  config,
+		actions: topicActions,
+		queue:   make(chan *Event, QUEUE_SIZE),
+		seen:    map[string]bool{},
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MAX_BODY_SIZE))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	domain := r.Header.Get(HEADER_SHOP_DOMAIN)
+	store, err := h.config.GetStoreByDomain(domain)
+	if err != nil || store.WebhookSecret == "" {
+		log.Printf("WARN : Webhook from unknown shop '%s' rejected\n", domain)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !Verify(body, store.WebhookSecret, r.Header.Get(HEADER_HMAC)) {
+		log.Printf("WARN : Webhook from shop '%s' rejected due to invalid signature\n", domain)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	topic := r.Header.Get(HEADER_TOPIC)
+	webhookID := r.Header.Get(HEADER_WEBHOOK_ID)
+	if _, ok := h.actions[topic]; !ok {
+		// 処理を設定していないトピックも受信自体は成功として返す (Shopify の再送を防ぐ)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// 受信済みとして記録するのは受け付けられる Webhook だけにする。
+	// 記録した後に失敗を返すと、Shopify の再送が重複として捨てられてしまう。
+	event := &Event{Topic: topic, WebhookID: webhookID, Store: store, ReceivedAt: time.Now()}
+	err = json.Unmarshal(body, &event.Order)
+	if err != nil {
+		log.Printf("ERROR : Webhook '%s' (%s) has invalid order. %s\n", webhookID, topic, err.Error())
+		http.Error(w, "invalid order", http.StatusBadRequest)
+		return
+	}
+
+	if h.isDuplicate(webhookID) {
+		log.Printf("INFO : Webhook '%s' (%s) is already received\n", webhookID, topic)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	select {
+	case h.queue <- event:
+		log.Printf("INFO : Webhook '%s' %s store '%s' orderNumber '%d' received\n", webhookID, topic, store.Name, event.Order.OrderNumber)
+		w.WriteHeader(http.StatusOK)
+	default:
+		// キューが溢れた場合は 503 を返して Shopify に再送させる
+		h.forget(webhookID)
+		log.Printf("WARN : Webhook '%s' (%s) rejected due to queue is full\n", webhookID, topic)
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}
+}
+
+// Run はキューのイベントを受信順に処理する。サーバーの起動時に goroutine で実行する。
+func (h *Handler) Run() {
+	for event := range h.queue {
+		for _, action := range h.actions[event.Topic] {
+			err := action.action(event)
+			if err != nil {
+				log.Printf("ERROR : Webhook '%s' %s orderNumber '%d' action '%s' failed. %s\n", event.WebhookID, event.Topic, event.Order.OrderNumber, action.name, err.Error())
+			}
+		}
+	}
+}
+
+func (h *Handler) isDuplicate(webhookID string) bool {
+	if webhookID == "" {
+		return false
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.seen[webhookID] {
+		return true
+	}
+	h.seen[webhookID] = true
+	h.seenList = append(h.seenList, webhookID)
+	if len(h.seenList) > RECENT_WEBHOOK_ID_SIZE {
+		delete(h.seen, h.seenList[0])
+		h.seenList = h.seenList[1:]
+	}
+	return false
+}
+
+// forget は受信済みの記録を消し、再送を受け付けるようにする。
+func (h *Handler) forget(webhookID string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.seen, webhookID)
+	for i, seenID := range h.seenList {
+		if seenID == webhookID {
+			h.seenList = append(h.seenList[:i], h.seenList[i+1:]...)
+			break
+		}
+	}
+}
+
+// Verify は body の HMAC-SHA256 (base64) が signature と一致するかを返す。
+func Verify(body []byte, secret, signature string) bool {
+	expected, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"shopify-manager/pkg/config"
+)
+
+// Shopify と同じく secret "hush" で body の HMAC-SHA256 を base64 にした署名
+const TEST_SECRET = "hush"
+const TEST_BODY = `{"id":1,"order_number":1001}`
+const TEST_SIGNATURE = "qxdTWTuKOmL7SqWhTSIjFdsszZH7xNZtduLtcmh6clI="
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		secret    string
+		signature string
+		want      bool
+	}{
+		{name: "valid", body: TEST_BODY, secret: TEST_SECRET, signature: TEST_SIGNATURE, want: true},
+		{name: "tampered body", body: `{"id":1,"order_number":1002}`, secret: TEST_SECRET, signature: TEST_SIGNATURE},
+		{name: "wrong secret", body: TEST_BODY, secret: "other", signature: TEST_SIGNATURE},
+		{name: "empty signature", body: TEST_BODY, secret: TEST_SECRET, signature: ""},
+		{name: "not base64", body: TEST_BODY, secret: TEST_SECRET, signature: "%%%"},
+	}
+	for _, tt := range tests {
+		if got := Verify([]byte(tt.body), tt.secret, tt.signature); got != tt.want {
+			t.Errorf("%s : Verify = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func newTestHandler(t *testing.T) *Handler {
+	conf := &config.Config{
+		Stores: map[string]config.Store{
+			"jp": {Name: "jp", Domain: "jp.myshopify.com", WebhookSecret: TEST_SECRET},
+		},
+		Serve: config.Serve{Actions: []config.WebhookAction{
+			{Topics: []string{config.WEBHOOK_TOPIC_ORDERS_CREATE}, Action: config.WEBHOOK_ACTION_EVENT_LOG},
+		}},
+	}
+	handler, err := NewHandler(conf, map[string]Action{
+		config.WEBHOOK_ACTION_EVENT_LOG: func(event *Event) error { return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+func post(handler *Handler, domain, body, signature, webhookID string) int {
+	req := httptest.NewRequest(http.MethodPost, WEBHOOK_PATH, strings.NewReader(body))
+	req.Header.Set(HEADER_SHOP_DOMAIN, domain)
+	req.Header.Set(HEADER_HMAC, signature)
+	req.Header.Set(HEADER_TOPIC, config.WEBHOOK_TOPIC_ORDERS_CREATE)
+	req.Header.Set(HEADER_WEBHOOK_ID, webhookID)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestServeHTTP(t *testing.T) {
+	handler := newTestHandler(t)
+
+	tests := []struct {
+		name      string
+		domain    string
+		body      string
+		signature string
+		webhookID string
+		want      int
+		wantQueue int
+	}{
+		{name: "unknown shop", domain: "other.myshopify.com", body: TEST_BODY, signature: TEST_SIGNATURE, webhookID: "w1", want: http.StatusUnauthorized},
+		{name: "tampered body", domain: "jp.myshopify.com", body: `{"id":1,"order_number":1002}`, signature: TEST_SIGNATURE, webhookID: "w1", want: http.StatusUnauthorized},
+		{name: "missing signature", domain: "jp.myshopify.com", body: TEST_BODY, signature: "", webhookID: "w1", want: http.StatusUnauthorized},
+		{name: "valid", domain: "jp.myshopify.com", body: TEST_BODY, signature: TEST_SIGNATURE, webhookID: "w1", want: http.StatusOK, wantQueue: 1},
+		// 再送は成功を返すが、キューには入れない
+		{name: "replayed", domain: "jp.myshopify.com", body: TEST_BODY, signature: TEST_SIGNATURE, webhookID: "w1", want: http.StatusOK, wantQueue: 1},
+		{name: "another webhook", domain: "jp.myshopify.com", body: TEST_BODY, signature: TEST_SIGNATURE, webhookID: "w2", want: http.StatusOK, wantQueue: 2},
+	}
+	for _, tt := range tests {
+		if got := post(handler, tt.domain, tt.body, tt.signature, tt.webhookID); got != tt.want {
+			t.Errorf("%s : status = %d, want %d", tt.name, got, tt.want)
+		}
+		if got := len(handler.queue); got != tt.wantQueue {
+			t.Errorf("%s : queued = %d, want %d", tt.name, got, tt.wantQueue)
+		}
+	}
+
+	event := <-handler.queue
+	if event.WebhookID != "w1" || event.Store.Name != "jp" || event.Order.OrderNumber != 1001 {
+		t.Errorf("event = %+v", event)
+	}
+}